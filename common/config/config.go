@@ -0,0 +1,277 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Config is the configuration of the server and the commands. Every setting can be given in the config file,
+// as the environment variable of its env tag or as a flag named after its file path, database.host is -database-host
+type Config struct {
+	Server      Server      `yaml:"server"`
+	Database    Database    `yaml:"database"`
+	JWT         JWT         `yaml:"jwt"`
+	Admin       Admin       `yaml:"admin"`
+	Seed        Seed        `yaml:"seed"`
+	Trash       Trash       `yaml:"trash"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	Timeouts    Timeouts    `yaml:"timeouts"`
+}
+
+// Server is how the http server listens. On shutdown the readiness probe fails for DrainDelay before the listener
+// closes, so load balancers stop sending requests, then open requests get ShutdownTimeout to finish
+type Server struct {
+	Addr            string        `yaml:"addr" env:"PATIKA_SERVER_ADDR"`
+	ReadTimeout     time.Duration `yaml:"read_timeout" env:"PATIKA_SERVER_READ_TIMEOUT"`
+	WriteTimeout    time.Duration `yaml:"write_timeout" env:"PATIKA_SERVER_WRITE_TIMEOUT"`
+	IdleTimeout     time.Duration `yaml:"idle_timeout" env:"PATIKA_SERVER_IDLE_TIMEOUT"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"PATIKA_SERVER_SHUTDOWN_TIMEOUT"`
+	DrainDelay      time.Duration `yaml:"drain_delay" env:"PATIKA_SERVER_DRAIN_DELAY"`
+}
+
+// Drivers of the database
+const (
+	Postgres = "postgres"
+	SQLite   = "sqlite"
+)
+
+// Database is the postgres server or the sqlite file the data is kept in, Path is only used by sqlite and may be
+// :memory:. Migrate applies the pending migrations when the server starts.
+// The pool settings, the TLS settings and ConnectRetry, how long a postgres server that is still starting is waited
+// for, are only used by postgres; sqlite always works on a single connection
+type Database struct {
+	Driver          string        `yaml:"driver" env:"PATIKA_DB_DRIVER"`
+	Path            string        `yaml:"path" env:"PATIKA_DB_PATH"`
+	Host            string        `yaml:"host" env:"PATIKA_DB_HOST"`
+	Port            int           `yaml:"port" env:"PATIKA_DB_PORT"`
+	Username        string        `yaml:"username" env:"PATIKA_DB_USERNAME"`
+	Password        string        `yaml:"password" env:"PATIKA_DB_PASSWORD" secret:"true"`
+	Name            string        `yaml:"name" env:"PATIKA_DB_NAME"`
+	Migrate         bool          `yaml:"migrate" env:"PATIKA_DB_MIGRATE"`
+	SSLMode         string        `yaml:"ssl_mode" env:"PATIKA_DB_SSL_MODE"`
+	SSLRootCert     string        `yaml:"ssl_root_cert" env:"PATIKA_DB_SSL_ROOT_CERT"`
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"PATIKA_DB_MAX_OPEN_CONNS"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"PATIKA_DB_MAX_IDLE_CONNS"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"PATIKA_DB_CONN_MAX_LIFETIME"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"PATIKA_DB_CONN_MAX_IDLE_TIME"`
+	ConnectRetry    time.Duration `yaml:"connect_retry" env:"PATIKA_DB_CONNECT_RETRY"`
+}
+
+// SSLModes are the TLS modes of the postgres connection, from no TLS to a verified certificate and host name
+var SSLModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// JWT is how tokens are signed, HS256 with Secret or RS256 with the PEM files PrivateKey and PublicKey
+type JWT struct {
+	Algorithm  string        `yaml:"algorithm" env:"PATIKA_JWT_ALGORITHM"`
+	Secret     string        `yaml:"secret" env:"PATIKA_JWT_SECRET" secret:"true"`
+	PrivateKey string        `yaml:"private_key" env:"PATIKA_JWT_PRIVATE_KEY"`
+	PublicKey  string        `yaml:"public_key" env:"PATIKA_JWT_PUBLIC_KEY"`
+	Issuer     string        `yaml:"issuer" env:"PATIKA_JWT_ISSUER"`
+	Audience   string        `yaml:"audience" env:"PATIKA_JWT_AUDIENCE"`
+	AccessTTL  time.Duration `yaml:"access_ttl" env:"PATIKA_JWT_ACCESS_TTL"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" env:"PATIKA_JWT_REFRESH_TTL"`
+}
+
+// Admin is the admin account created at start when there is no user with Username
+type Admin struct {
+	Username string `yaml:"username" env:"PATIKA_AUTH_USERNAME"`
+	Password string `yaml:"password" env:"PATIKA_AUTH_PASSWORD" secret:"true"`
+}
+
+// Seed are the csv files loaded into an empty catalog at start, an empty path skips the file
+type Seed struct {
+	Books   string `yaml:"books" env:"PATIKA_SEED_BOOKS"`
+	Authors string `yaml:"authors" env:"PATIKA_SEED_AUTHORS"`
+}
+
+// Trash is how long deleted books are kept and how often they are purged
+type Trash struct {
+	Retention     time.Duration `yaml:"retention" env:"PATIKA_TRASH_RETENTION"`
+	PurgeInterval time.Duration `yaml:"purge_interval" env:"PATIKA_TRASH_PURGE_INTERVAL"`
+}
+
+// Idempotency is how long the responses of requests with an Idempotency-Key are replayed
+type Idempotency struct {
+	TTL time.Duration `yaml:"ttl" env:"PATIKA_IDEMPOTENCY_TTL"`
+}
+
+// Timeouts are how long the requests of each group of routes may take, their database queries are cancelled when the
+// time is up. Default is used by the routes of no group and by groups whose timeout is zero
+type Timeouts struct {
+	Default time.Duration `yaml:"default" env:"PATIKA_TIMEOUT_DEFAULT"`
+	Books   time.Duration `yaml:"books" env:"PATIKA_TIMEOUT_BOOKS"`
+	Authors time.Duration `yaml:"authors" env:"PATIKA_TIMEOUT_AUTHORS"`
+	Orders  time.Duration `yaml:"orders" env:"PATIKA_TIMEOUT_ORDERS"`
+	Search  time.Duration `yaml:"search" env:"PATIKA_TIMEOUT_SEARCH"`
+	Import  time.Duration `yaml:"import" env:"PATIKA_TIMEOUT_IMPORT"`
+	Export  time.Duration `yaml:"export" env:"PATIKA_TIMEOUT_EXPORT"`
+	Audit   time.Duration `yaml:"audit" env:"PATIKA_TIMEOUT_AUDIT"`
+}
+
+// Or returns d, or Default when d is zero
+func (t Timeouts) Or(d time.Duration) time.Duration {
+	if d == 0 {
+		return t.Default
+	}
+	return d
+}
+
+// Longest returns the longest timeout of any route
+func (t Timeouts) Longest() time.Duration {
+	longest := t.Default
+	for _, d := range []time.Duration{t.Books, t.Authors, t.Orders, t.Search, t.Import, t.Export, t.Audit} {
+		if d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+// Default returns the configuration used for everything that is not set
+func Default() Config {
+	return Config{
+		Server: Server{
+			Addr:            "localhost:8090",
+			ReadTimeout:     15 * time.Second,
+			WriteTimeout:    15 * time.Second,
+			IdleTimeout:     60 * time.Second,
+			ShutdownTimeout: 10 * time.Second,
+			DrainDelay:      5 * time.Second,
+		},
+		Database: Database{
+			Driver:          Postgres,
+			Path:            "patika.db",
+			Host:            "localhost",
+			Port:            5432,
+			Username:        "postgres",
+			Name:            "postgres",
+			Migrate:         true,
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+			ConnectRetry:    30 * time.Second,
+		},
+		JWT: JWT{
+			Algorithm:  "HS256",
+			Issuer:     "patika-bookstore",
+			Audience:   "patika-api",
+			AccessTTL:  15 * time.Minute,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		Seed: Seed{
+			Books:   "book.csv",
+			Authors: "author.csv",
+		},
+		Trash: Trash{
+			Retention:     30 * 24 * time.Hour,
+			PurgeInterval: time.Hour,
+		},
+		Idempotency: Idempotency{
+			TTL: 24 * time.Hour,
+		},
+		Timeouts: Timeouts{
+			Default: 5 * time.Second,
+			Search:  2 * time.Second,
+			Import:  5 * time.Minute,
+			Export:  10 * time.Minute,
+		},
+	}
+}
+
+// Validate reports every invalid setting at once
+func (c Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr is required")
+	for name, d := range map[string]time.Duration{
+		"server.read_timeout":     c.Server.ReadTimeout,
+		"server.write_timeout":    c.Server.WriteTimeout,
+		"server.idle_timeout":     c.Server.IdleTimeout,
+		"server.shutdown_timeout": c.Server.ShutdownTimeout,
+		"jwt.access_ttl":          c.JWT.AccessTTL,
+		"jwt.refresh_ttl":         c.JWT.RefreshTTL,
+		"trash.retention":         c.Trash.Retention,
+		"trash.purge_interval":    c.Trash.PurgeInterval,
+		"idempotency.ttl":         c.Idempotency.TTL,
+		"timeouts.default":        c.Timeouts.Default,
+	} {
+		check(d > 0, "%s must be a positive duration", name)
+	}
+	check(c.Server.DrainDelay >= 0, "server.drain_delay must not be negative")
+	// the server moves the connection deadlines of a route past its timeout, so it may exceed server.write_timeout
+	for name, d := range map[string]time.Duration{
+		"timeouts.books":   c.Timeouts.Books,
+		"timeouts.authors": c.Timeouts.Authors,
+		"timeouts.orders":  c.Timeouts.Orders,
+		"timeouts.search":  c.Timeouts.Search,
+		"timeouts.import":  c.Timeouts.Import,
+		"timeouts.export":  c.Timeouts.Export,
+		"timeouts.audit":   c.Timeouts.Audit,
+	} {
+		check(d >= 0, "%s must not be negative, 0 uses timeouts.default", name)
+	}
+
+	switch c.Database.Driver {
+	case Postgres:
+		check(c.Database.Host != "", "database.host is required")
+		check(c.Database.Port > 0 && c.Database.Port < 65536, "database.port must be between 1 and 65535")
+		check(c.Database.Username != "", "database.username is required")
+		check(c.Database.Name != "", "database.name is required")
+		check(contains(SSLModes, c.Database.SSLMode), "database.ssl_mode must be one of %s, not %q",
+			strings.Join(SSLModes, ", "), c.Database.SSLMode)
+		check(c.Database.SSLRootCert == "" || c.Database.SSLMode != "disable",
+			"database.ssl_root_cert needs a database.ssl_mode other than disable")
+		check(c.Database.MaxOpenConns >= 0, "database.max_open_conns must not be negative, 0 is unlimited")
+		check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns must not be negative")
+		check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+			"database.max_idle_conns must not be more than database.max_open_conns")
+		for name, d := range map[string]time.Duration{
+			"database.conn_max_lifetime":  c.Database.ConnMaxLifetime,
+			"database.conn_max_idle_time": c.Database.ConnMaxIdleTime,
+			"database.connect_retry":      c.Database.ConnectRetry,
+		} {
+			check(d >= 0, "%s must not be negative, 0 turns it off", name)
+		}
+	case SQLite:
+		check(c.Database.Path != "", "database.path is required for sqlite")
+	default:
+		check(false, "database.driver must be postgres or sqlite, not %q", c.Database.Driver)
+	}
+
+	switch c.JWT.Algorithm {
+	case "HS256":
+		check(c.JWT.Secret != "", "jwt.secret is required for HS256, set PATIKA_JWT_SECRET")
+		check(c.JWT.Secret == "" || len(c.JWT.Secret) >= 32, "jwt.secret must be at least 32 bytes for HS256")
+	case "RS256":
+		check(c.JWT.PrivateKey != "" || c.JWT.PublicKey != "", "jwt.private_key or jwt.public_key is required for RS256")
+	default:
+		check(false, "jwt.algorithm must be HS256 or RS256, not %q", c.JWT.Algorithm)
+	}
+	check(c.JWT.AccessTTL < c.JWT.RefreshTTL, "jwt.access_ttl must be shorter than jwt.refresh_ttl")
+
+	check(c.Admin.Username == "" || c.Admin.Password != "", "admin.password is required with admin.username")
+
+	if len(problems) == 0 {
+		return nil
+	}
+	sort.Strings(problems)
+	return fmt.Errorf("invalid configuration: %s", strings.Join(problems, "; "))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFile is the config file read when -config and PATIKA_CONFIG are not given, it may be missing
+const DefaultFile = "config.yaml"
+
+// redacted replaces the secrets in a dump
+const redacted = "[redacted]"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setting is a leaf of the configuration with its file path
+type setting struct {
+	path  string
+	field reflect.StructField
+	value reflect.Value
+}
+
+//Load builds the configuration from the defaults, the config file, the environment and .env, and the flags in args,
+//each overriding the ones before. The file is -config, PATIKA_CONFIG or config.yaml. The arguments after the flags
+//are returned, an invalid configuration is an error
+func Load(args []string) (*Config, []string, error) {
+	c := Default()
+	settings := collect(reflect.ValueOf(&c).Elem(), "")
+
+	flags := flag.NewFlagSet("patika", flag.ContinueOnError)
+	file := flags.String("config", "", "config file, yaml (default "+DefaultFile+" when it exists)")
+	var given []func() error
+	for _, s := range settings {
+		s := s
+		flags.Var(&flagValue{setting: s, given: &given}, flagName(s.path), "overrides "+s.field.Tag.Get("env"))
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	// variables already in the environment win over .env
+	if err := godotenv.Load(); err != nil && !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf(".env: %v", err)
+	}
+
+	path, required := *file, true
+	if path == "" {
+		path = os.Getenv("PATIKA_CONFIG")
+	}
+	if path == "" {
+		path, required = DefaultFile, false
+	}
+	if err := readFile(&c, path, required); err != nil {
+		return nil, nil, err
+	}
+
+	for _, s := range settings {
+		name := s.field.Tag.Get("env")
+		if v, ok := os.LookupEnv(name); ok && v != "" {
+			if err := set(s.value, v); err != nil {
+				return nil, nil, fmt.Errorf("%s: %v", name, err)
+			}
+		}
+	}
+	for _, apply := range given {
+		if err := apply(); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return &c, flags.Args(), nil
+}
+
+//Dump writes the configuration as yaml with the secrets redacted
+func (c Config) Dump(w io.Writer) error {
+	for _, s := range collect(reflect.ValueOf(&c).Elem(), "") {
+		if s.field.Tag.Get("secret") == "true" && s.value.String() != "" {
+			s.value.SetString(redacted)
+		}
+	}
+	encoder := yaml.NewEncoder(w)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(c); err != nil {
+		return err
+	}
+	return encoder.Close()
+}
+
+//readFile decodes a yaml config file over c, unknown keys are errors
+func readFile(c *Config, path string, required bool) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("config file: %v", err)
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("config file %s: %v", path, err)
+	}
+	return nil
+}
+
+//collect returns the leaves of a config struct
+func collect(v reflect.Value, prefix string) []setting {
+	var settings []setting
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		path := prefix + field.Tag.Get("yaml")
+		if field.Type.Kind() == reflect.Struct {
+			settings = append(settings, collect(v.Field(i), path+".")...)
+			continue
+		}
+		settings = append(settings, setting{path: path, field: field, value: v.Field(i)})
+	}
+	return settings
+}
+
+//set parses s into a string, int, bool or duration setting
+func set(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("%q is not an integer", s)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", s)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+//flagName is the flag of a setting, database.host is database-host
+func flagName(path string) string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(path)
+}
+
+//flagValue keeps a flag until the file and the environment are applied, flags override both
+type flagValue struct {
+	setting setting
+	given   *[]func() error
+}
+
+func (f *flagValue) String() string {
+	if f == nil || !f.setting.value.IsValid() {
+		return ""
+	}
+	if f.setting.value.Type() == durationType {
+		return time.Duration(f.setting.value.Int()).String()
+	}
+	return fmt.Sprint(f.setting.value.Interface())
+}
+
+func (f *flagValue) Set(s string) error {
+	if err := set(reflect.New(f.setting.value.Type()).Elem(), s); err != nil {
+		return err
+	}
+	*f.given = append(*f.given, func() error {
+		return set(f.setting.value, s)
+	})
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.setting.value.IsValid() && f.setting.value.Kind() == reflect.Bool
+}
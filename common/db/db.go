@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"gorm.io/gorm"
+)
+
+// Backoff of the connection attempts while the database is still starting
+const (
+	firstRetryDelay = 250 * time.Millisecond
+	maxRetryDelay   = 8 * time.Second
+)
+
+// Open connects to the database of the configured driver, every call opens a new connection pool so the caller
+// opens it once and shares the returned database
+func Open(c config.Database) (*gorm.DB, error) {
+	if c.Driver == config.SQLite {
+		return NewSQLiteDB(c)
+	}
+	return NewPsqlDB(c)
+}
+
+// ping pings the database until it answers, waiting twice as long after every failed attempt, and gives up with the
+// last error once retry has passed
+func ping(sqlDB *sql.DB, retry time.Duration) error {
+	deadline := time.Now().Add(retry)
+	delay := firstRetryDelay
+	for {
+		err := sqlDB.Ping()
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return err
+		}
+		log.Printf("Database is not ready, retrying in %s: %v", delay, err)
+		time.Sleep(delay)
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// initialSchema creates the tables as AutoMigrate used to create them,
+// databases that were already auto migrated keep their tables unchanged
+var initialSchema = Migration{
+	Version: 1,
+	Name:    "initial_schema",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE IF NOT EXISTS authors (
+				id bigserial PRIMARY KEY,
+				created_at timestamptz,
+				updated_at timestamptz,
+				deleted_at timestamptz,
+				author_name text,
+				author_id text
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_authors_deleted_at ON authors (deleted_at)`,
+			`CREATE TABLE IF NOT EXISTS books (
+				id text PRIMARY KEY,
+				created_at timestamptz,
+				updated_at timestamptz,
+				deleted_at timestamptz,
+				name text,
+				page text,
+				stock text,
+				cost text,
+				stock_code text,
+				isbn text,
+				author_id text
+			)`,
+			`CREATE INDEX IF NOT EXISTS idx_books_deleted_at ON books (deleted_at)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE IF EXISTS books`,
+			`DROP TABLE IF EXISTS authors`,
+		)
+	},
+	// SQLiteUp creates the tables with the types and constraints postgres only gets in typed_books
+	SQLiteUp: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE authors (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				created_at datetime,
+				updated_at datetime,
+				deleted_at datetime,
+				author_name text NOT NULL,
+				author_id integer NOT NULL
+			)`,
+			`CREATE INDEX idx_authors_deleted_at ON authors (deleted_at)`,
+			`CREATE UNIQUE INDEX idx_authors_author_id ON authors (author_id)`,
+			`CREATE TABLE books (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				created_at datetime,
+				updated_at datetime,
+				deleted_at datetime,
+				name text NOT NULL,
+				page integer NOT NULL CONSTRAINT chk_books_page CHECK (page > 0),
+				stock integer NOT NULL CONSTRAINT chk_books_stock CHECK (stock >= 0),
+				cost numeric NOT NULL CONSTRAINT chk_books_cost CHECK (cost >= 0),
+				stock_code text NOT NULL,
+				isbn text NOT NULL,
+				author_id integer NOT NULL CONSTRAINT fk_books_author REFERENCES authors (author_id)
+					ON UPDATE CASCADE ON DELETE RESTRICT
+			)`,
+			`CREATE INDEX idx_books_deleted_at ON books (deleted_at)`,
+			`CREATE UNIQUE INDEX idx_books_isbn ON books (isbn)`,
+			`CREATE UNIQUE INDEX idx_books_stock_code ON books (stock_code)`,
+			`CREATE INDEX idx_books_author_id ON books (author_id)`,
+		)
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE books`,
+			`DROP TABLE authors`,
+		)
+	},
+}
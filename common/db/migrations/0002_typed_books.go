@@ -0,0 +1,269 @@
+package migrations
+
+import (
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BatuhanSerin/postgresql/common/money"
+	"gorm.io/gorm"
+)
+
+const typedBooksVersion = 2
+
+// typedBooks converts the text columns of books and authors in place.
+// Rows that cannot be converted are moved to migration_rejects with the reason and logged,
+// so that the new constraints never have to accept bad data.
+var typedBooks = Migration{
+	Version: typedBooksVersion,
+	Name:    "typed_books",
+	Up: func(tx *gorm.DB) error {
+		if err := execAll(tx,
+			`CREATE TABLE IF NOT EXISTS migration_rejects (
+				id bigserial PRIMARY KEY,
+				version bigint NOT NULL,
+				table_name text NOT NULL,
+				row_id text NOT NULL,
+				data jsonb NOT NULL,
+				reason text NOT NULL,
+				rejected_at timestamptz NOT NULL DEFAULT now()
+			)`,
+			`UPDATE authors SET author_id = trim(author_id), author_name = trim(author_name)`,
+			`UPDATE books SET id = trim(id), name = trim(name), page = trim(page), stock = trim(stock),
+				cost = trim(cost), stock_code = trim(stock_code), isbn = trim(isbn), author_id = trim(author_id)`,
+		); err != nil {
+			return err
+		}
+
+		authorIDs, err := rejectLegacyAuthors(tx)
+		if err != nil {
+			return err
+		}
+		if err := execAll(tx,
+			`ALTER TABLE authors
+				ALTER COLUMN author_id TYPE bigint USING author_id::bigint,
+				ALTER COLUMN author_id SET NOT NULL,
+				ALTER COLUMN author_name SET NOT NULL`,
+			`CREATE UNIQUE INDEX idx_authors_author_id ON authors (author_id)`,
+		); err != nil {
+			return err
+		}
+
+		if err := rejectLegacyBooks(tx, authorIDs); err != nil {
+			return err
+		}
+		return execAll(tx,
+			`ALTER TABLE books
+				ALTER COLUMN id TYPE bigint USING id::bigint,
+				ALTER COLUMN page TYPE integer USING page::integer,
+				ALTER COLUMN stock TYPE integer USING stock::integer,
+				ALTER COLUMN cost TYPE numeric(12,2) USING cost::numeric(12,2),
+				ALTER COLUMN author_id TYPE bigint USING author_id::bigint,
+				ALTER COLUMN name SET NOT NULL,
+				ALTER COLUMN page SET NOT NULL,
+				ALTER COLUMN stock SET NOT NULL,
+				ALTER COLUMN cost SET NOT NULL,
+				ALTER COLUMN stock_code SET NOT NULL,
+				ALTER COLUMN isbn SET NOT NULL,
+				ALTER COLUMN author_id SET NOT NULL`,
+			`CREATE SEQUENCE books_id_seq OWNED BY books.id`,
+			`SELECT setval('books_id_seq', COALESCE((SELECT MAX(id) FROM books), 0) + 1, false)`,
+			`ALTER TABLE books ALTER COLUMN id SET DEFAULT nextval('books_id_seq')`,
+			`ALTER TABLE books
+				ADD CONSTRAINT chk_books_page CHECK (page > 0),
+				ADD CONSTRAINT chk_books_stock CHECK (stock >= 0),
+				ADD CONSTRAINT chk_books_cost CHECK (cost >= 0),
+				ADD CONSTRAINT fk_books_author FOREIGN KEY (author_id) REFERENCES authors (author_id)
+					ON UPDATE CASCADE ON DELETE RESTRICT`,
+			`CREATE UNIQUE INDEX idx_books_isbn ON books (isbn)`,
+			`CREATE UNIQUE INDEX idx_books_stock_code ON books (stock_code)`,
+			`CREATE INDEX idx_books_author_id ON books (author_id)`,
+		)
+	},
+	// Down converts the columns back to text and puts the rejected rows back in place
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP INDEX idx_books_author_id`,
+			`DROP INDEX idx_books_stock_code`,
+			`DROP INDEX idx_books_isbn`,
+			`ALTER TABLE books
+				DROP CONSTRAINT fk_books_author,
+				DROP CONSTRAINT chk_books_cost,
+				DROP CONSTRAINT chk_books_stock,
+				DROP CONSTRAINT chk_books_page,
+				ALTER COLUMN id DROP DEFAULT`,
+			`DROP SEQUENCE books_id_seq`,
+			`ALTER TABLE books
+				ALTER COLUMN id TYPE text USING id::text,
+				ALTER COLUMN page TYPE text USING page::text,
+				ALTER COLUMN stock TYPE text USING stock::text,
+				ALTER COLUMN cost TYPE text USING cost::text,
+				ALTER COLUMN author_id TYPE text USING author_id::text,
+				ALTER COLUMN name DROP NOT NULL,
+				ALTER COLUMN page DROP NOT NULL,
+				ALTER COLUMN stock DROP NOT NULL,
+				ALTER COLUMN cost DROP NOT NULL,
+				ALTER COLUMN stock_code DROP NOT NULL,
+				ALTER COLUMN isbn DROP NOT NULL,
+				ALTER COLUMN author_id DROP NOT NULL`,
+			`DROP INDEX idx_authors_author_id`,
+			`ALTER TABLE authors
+				ALTER COLUMN author_id TYPE text USING author_id::text,
+				ALTER COLUMN author_id DROP NOT NULL,
+				ALTER COLUMN author_name DROP NOT NULL`,
+			`INSERT INTO authors SELECT (jsonb_populate_record(NULL::authors, data)).*
+				FROM migration_rejects WHERE version = 2 AND table_name = 'authors'`,
+			`INSERT INTO books SELECT (jsonb_populate_record(NULL::books, data)).*
+				FROM migration_rejects WHERE version = 2 AND table_name = 'books'`,
+			`DROP TABLE migration_rejects`,
+		)
+	},
+	// SQLiteUp only adds migration_rejects, the sqlite tables are typed from the start
+	SQLiteUp: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE migration_rejects (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				version integer NOT NULL,
+				table_name text NOT NULL,
+				row_id text NOT NULL,
+				data text NOT NULL,
+				reason text NOT NULL,
+				rejected_at datetime NOT NULL DEFAULT CURRENT_TIMESTAMP
+			)`,
+		)
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE migration_rejects`,
+		)
+	},
+}
+
+type legacyAuthor struct {
+	ID         string
+	AuthorID   string
+	AuthorName string
+}
+
+type legacyBook struct {
+	ID        string
+	Name      string
+	Page      string
+	Stock     string
+	Cost      string
+	StockCode string
+	ISBN      string
+	AuthorID  string
+}
+
+// rejectLegacyAuthors rejects authors without a unique integer author id and returns the author ids that are kept
+func rejectLegacyAuthors(tx *gorm.DB) (map[string]bool, error) {
+	var authors []legacyAuthor
+	err := tx.Raw(`SELECT id::text AS id, COALESCE(author_id, '') AS author_id, COALESCE(author_name, '') AS author_name
+		FROM authors ORDER BY id`).Scan(&authors).Error
+	if err != nil {
+		return nil, err
+	}
+
+	kept := map[string]bool{}
+	for _, a := range authors {
+		var reasons []string
+		if id, err := strconv.ParseUint(a.AuthorID, 10, 64); err != nil || id == 0 {
+			reasons = append(reasons, "author_id is not a positive integer")
+		} else if kept[a.AuthorID] {
+			reasons = append(reasons, "author_id is duplicated")
+		}
+		if a.AuthorName == "" {
+			reasons = append(reasons, "author_name is empty")
+		}
+
+		if len(reasons) == 0 {
+			kept[a.AuthorID] = true
+			continue
+		}
+		if err := reject(tx, "authors", a.ID, `id = ?::bigint`, strings.Join(reasons, "; ")); err != nil {
+			return nil, err
+		}
+	}
+	return kept, nil
+}
+
+// rejectLegacyBooks rejects books whose columns cannot be converted or would break the new constraints
+func rejectLegacyBooks(tx *gorm.DB, authorIDs map[string]bool) error {
+	var books []legacyBook
+	err := tx.Raw(`SELECT COALESCE(id, '') AS id, COALESCE(name, '') AS name, COALESCE(page, '') AS page,
+		COALESCE(stock, '') AS stock, COALESCE(cost, '') AS cost, COALESCE(stock_code, '') AS stock_code,
+		COALESCE(isbn, '') AS isbn, COALESCE(author_id, '') AS author_id FROM books`).Scan(&books).Error
+	if err != nil {
+		return err
+	}
+
+	// the row with the lowest id keeps a duplicated isbn or stock code
+	sort.SliceStable(books, func(i, j int) bool {
+		a, errA := strconv.ParseUint(books[i].ID, 10, 64)
+		b, errB := strconv.ParseUint(books[j].ID, 10, 64)
+		if errA != nil || errB != nil {
+			return errA == nil
+		}
+		return a < b
+	})
+
+	isbns := map[string]bool{}
+	stockCodes := map[string]bool{}
+	for _, b := range books {
+		var reasons []string
+		if id, err := strconv.ParseUint(b.ID, 10, 64); err != nil || id == 0 {
+			reasons = append(reasons, "id is not a positive integer")
+		}
+		if b.Name == "" {
+			reasons = append(reasons, "name is empty")
+		}
+		if page, err := strconv.Atoi(b.Page); err != nil || page <= 0 {
+			reasons = append(reasons, "page is not a positive integer")
+		}
+		if stock, err := strconv.Atoi(b.Stock); err != nil || stock < 0 {
+			reasons = append(reasons, "stock is not a non-negative integer")
+		}
+		if cost, err := money.Parse(b.Cost); err != nil || cost < 0 {
+			reasons = append(reasons, "cost is not a non-negative decimal amount")
+		}
+		switch {
+		case b.StockCode == "":
+			reasons = append(reasons, "stock_code is empty")
+		case stockCodes[b.StockCode]:
+			reasons = append(reasons, "stock_code is duplicated")
+		}
+		switch {
+		case b.ISBN == "":
+			reasons = append(reasons, "isbn is empty")
+		case isbns[b.ISBN]:
+			reasons = append(reasons, "isbn is duplicated")
+		}
+		if !authorIDs[b.AuthorID] {
+			reasons = append(reasons, "author_id does not match an author")
+		}
+
+		if len(reasons) == 0 {
+			isbns[b.ISBN] = true
+			stockCodes[b.StockCode] = true
+			continue
+		}
+		if err := reject(tx, "books", b.ID, `id = ?`, strings.Join(reasons, "; ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reject moves a row to migration_rejects and reports it
+func reject(tx *gorm.DB, table, rowID, where, reason string) error {
+	log.Printf("Migration %d rejected %s row %q: %s", typedBooksVersion, table, rowID, reason)
+
+	if err := tx.Exec(`INSERT INTO migration_rejects (version, table_name, row_id, data, reason)
+		SELECT ?, ?, ?, to_jsonb(t), ? FROM `+table+` t WHERE `+where,
+		typedBooksVersion, table, rowID, reason, rowID).Error; err != nil {
+		return err
+	}
+	return tx.Exec(`DELETE FROM `+table+` WHERE `+where, rowID).Error
+}
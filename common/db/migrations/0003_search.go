@@ -0,0 +1,48 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// search adds tsvector columns and trigram indexes for the search endpoint.
+// search_normalize folds Turkish letters before lower casing, so that "İstanbul", "istanbul" and "ıstanbul"
+// end up as the same lexeme no matter the database locale.
+var search = Migration{
+	Version: 3,
+	Name:    "search",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+			`CREATE FUNCTION search_normalize(text) RETURNS text
+				LANGUAGE sql IMMUTABLE PARALLEL SAFE
+				AS $$ SELECT lower(translate($1, 'İIıŞşĞğÇçÖöÜü', 'iiissggccoouu')) $$`,
+			`ALTER TABLE books ADD COLUMN search_vector tsvector
+				GENERATED ALWAYS AS (to_tsvector('simple', search_normalize(name))) STORED`,
+			`ALTER TABLE authors ADD COLUMN search_vector tsvector
+				GENERATED ALWAYS AS (to_tsvector('simple', search_normalize(author_name))) STORED`,
+			`CREATE INDEX idx_books_search_vector ON books USING gin (search_vector)`,
+			`CREATE INDEX idx_authors_search_vector ON authors USING gin (search_vector)`,
+			`CREATE INDEX idx_books_name_trgm ON books USING gin (search_normalize(name) gin_trgm_ops)`,
+			`CREATE INDEX idx_books_isbn_trgm ON books USING gin (replace(isbn, '-', '') gin_trgm_ops)`,
+			`CREATE INDEX idx_authors_author_name_trgm ON authors USING gin (search_normalize(author_name) gin_trgm_ops)`,
+		)
+	},
+	// Down keeps the pg_trgm extension, other database objects may use it by now
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP INDEX idx_authors_author_name_trgm`,
+			`DROP INDEX idx_books_isbn_trgm`,
+			`DROP INDEX idx_books_name_trgm`,
+			`DROP INDEX idx_authors_search_vector`,
+			`DROP INDEX idx_books_search_vector`,
+			`ALTER TABLE authors DROP COLUMN search_vector`,
+			`ALTER TABLE books DROP COLUMN search_vector`,
+			`DROP FUNCTION search_normalize(text)`,
+		)
+	},
+	// sqlite has no full text vectors or trigrams, its search matches words with LIKE and needs nothing here
+	SQLiteUp: func(tx *gorm.DB) error {
+		return nil
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return nil
+	},
+}
@@ -0,0 +1,88 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// stockLedger adds the stock movement ledger and reservations.
+// books.stock stays the on hand quantity and is only changed together with a ledger row,
+// books.reserved is the quantity held by open reservations and can never exceed the stock.
+var stockLedger = Migration{
+	Version: 4,
+	Name:    "stock_ledger",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE books
+				ADD COLUMN reserved integer NOT NULL DEFAULT 0,
+				ADD CONSTRAINT chk_books_reserved CHECK (reserved >= 0 AND reserved <= stock)`,
+			`CREATE TABLE stock_movements (
+				id bigserial PRIMARY KEY,
+				book_id bigint NOT NULL REFERENCES books (id),
+				kind text NOT NULL CHECK (kind IN ('receipt', 'sale', 'adjustment', 'return', 'transfer')),
+				quantity integer NOT NULL CHECK (quantity <> 0),
+				stock_after integer NOT NULL CHECK (stock_after >= 0),
+				reason text NOT NULL,
+				created_by text NOT NULL,
+				reference text NOT NULL DEFAULT '',
+				created_at timestamptz NOT NULL
+			)`,
+			`CREATE INDEX idx_stock_movements_book_id ON stock_movements (book_id, id)`,
+			`INSERT INTO stock_movements (book_id, kind, quantity, stock_after, reason, created_by, created_at)
+				SELECT id, 'adjustment', stock, stock, 'opening balance', 'migration', now() FROM books WHERE stock <> 0`,
+			`CREATE TABLE stock_reservations (
+				id bigserial PRIMARY KEY,
+				book_id bigint NOT NULL REFERENCES books (id),
+				quantity integer NOT NULL CHECK (quantity > 0),
+				status text NOT NULL CHECK (status IN ('held', 'committed', 'released')),
+				reason text NOT NULL,
+				created_by text NOT NULL,
+				reference text NOT NULL DEFAULT '',
+				created_at timestamptz NOT NULL,
+				updated_at timestamptz NOT NULL
+			)`,
+			`CREATE INDEX idx_stock_reservations_held ON stock_reservations (book_id) WHERE status = 'held'`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE stock_reservations`,
+			`DROP TABLE stock_movements`,
+			`ALTER TABLE books DROP CONSTRAINT chk_books_reserved, DROP COLUMN reserved`,
+		)
+	},
+	SQLiteUp: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE books ADD COLUMN reserved integer NOT NULL DEFAULT 0
+				CONSTRAINT chk_books_reserved CHECK (reserved >= 0 AND reserved <= stock)`,
+			`CREATE TABLE stock_movements (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				book_id integer NOT NULL REFERENCES books (id),
+				kind text NOT NULL CHECK (kind IN ('receipt', 'sale', 'adjustment', 'return', 'transfer')),
+				quantity integer NOT NULL CHECK (quantity <> 0),
+				stock_after integer NOT NULL CHECK (stock_after >= 0),
+				reason text NOT NULL,
+				created_by text NOT NULL,
+				reference text NOT NULL DEFAULT '',
+				created_at datetime NOT NULL
+			)`,
+			`CREATE INDEX idx_stock_movements_book_id ON stock_movements (book_id, id)`,
+			`CREATE TABLE stock_reservations (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				book_id integer NOT NULL REFERENCES books (id),
+				quantity integer NOT NULL CHECK (quantity > 0),
+				status text NOT NULL CHECK (status IN ('held', 'committed', 'released')),
+				reason text NOT NULL,
+				created_by text NOT NULL,
+				reference text NOT NULL DEFAULT '',
+				created_at datetime NOT NULL,
+				updated_at datetime NOT NULL
+			)`,
+			`CREATE INDEX idx_stock_reservations_held ON stock_reservations (book_id) WHERE status = 'held'`,
+		)
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE stock_reservations`,
+			`DROP TABLE stock_movements`,
+			`ALTER TABLE books DROP COLUMN reserved`,
+		)
+	},
+}
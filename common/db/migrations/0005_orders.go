@@ -0,0 +1,87 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// orders adds orders with their priced items and the history of their status transitions
+var orders = Migration{
+	Version: 5,
+	Name:    "orders",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE orders (
+				id bigserial PRIMARY KEY,
+				customer_id text NOT NULL,
+				status text NOT NULL CHECK (status IN ('pending', 'paid', 'shipped', 'cancelled', 'returned')),
+				total numeric(12,2) NOT NULL CHECK (total >= 0),
+				created_at timestamptz NOT NULL,
+				updated_at timestamptz NOT NULL
+			)`,
+			`CREATE INDEX idx_orders_customer_id ON orders (customer_id, id)`,
+			`CREATE TABLE order_items (
+				id bigserial PRIMARY KEY,
+				order_id bigint NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+				book_id bigint NOT NULL REFERENCES books (id),
+				quantity integer NOT NULL CHECK (quantity > 0),
+				unit_price numeric(12,2) NOT NULL CHECK (unit_price >= 0),
+				line_total numeric(12,2) NOT NULL CHECK (line_total >= 0)
+			)`,
+			`CREATE INDEX idx_order_items_order_id ON order_items (order_id)`,
+			`CREATE TABLE order_transitions (
+				id bigserial PRIMARY KEY,
+				order_id bigint NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+				from_status text NOT NULL,
+				to_status text NOT NULL,
+				reason text NOT NULL DEFAULT '',
+				created_by text NOT NULL,
+				created_at timestamptz NOT NULL
+			)`,
+			`CREATE INDEX idx_order_transitions_order_id ON order_transitions (order_id, id)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE order_transitions`,
+			`DROP TABLE order_items`,
+			`DROP TABLE orders`,
+		)
+	},
+	SQLiteUp: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE orders (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				customer_id text NOT NULL,
+				status text NOT NULL CHECK (status IN ('pending', 'paid', 'shipped', 'cancelled', 'returned')),
+				total numeric NOT NULL CHECK (total >= 0),
+				created_at datetime NOT NULL,
+				updated_at datetime NOT NULL
+			)`,
+			`CREATE INDEX idx_orders_customer_id ON orders (customer_id, id)`,
+			`CREATE TABLE order_items (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				order_id integer NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+				book_id integer NOT NULL REFERENCES books (id),
+				quantity integer NOT NULL CHECK (quantity > 0),
+				unit_price numeric NOT NULL CHECK (unit_price >= 0),
+				line_total numeric NOT NULL CHECK (line_total >= 0)
+			)`,
+			`CREATE INDEX idx_order_items_order_id ON order_items (order_id)`,
+			`CREATE TABLE order_transitions (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				order_id integer NOT NULL REFERENCES orders (id) ON DELETE CASCADE,
+				from_status text NOT NULL,
+				to_status text NOT NULL,
+				reason text NOT NULL DEFAULT '',
+				created_by text NOT NULL,
+				created_at datetime NOT NULL
+			)`,
+			`CREATE INDEX idx_order_transitions_order_id ON order_transitions (order_id, id)`,
+		)
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE order_transitions`,
+			`DROP TABLE order_items`,
+			`DROP TABLE orders`,
+		)
+	},
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// refreshTokens records the issued refresh tokens so that each one is used once and can be revoked
+var refreshTokens = Migration{
+	Version: 6,
+	Name:    "refresh_tokens",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE refresh_tokens (
+				id text PRIMARY KEY,
+				subject text NOT NULL,
+				expires_at timestamptz NOT NULL,
+				revoked_at timestamptz,
+				created_at timestamptz NOT NULL
+			)`,
+			`CREATE INDEX idx_refresh_tokens_subject ON refresh_tokens (subject) WHERE revoked_at IS NULL`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE refresh_tokens`,
+		)
+	},
+	SQLiteUp: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE refresh_tokens (
+				id text PRIMARY KEY,
+				subject text NOT NULL,
+				expires_at datetime NOT NULL,
+				revoked_at datetime,
+				created_at datetime NOT NULL
+			)`,
+			`CREATE INDEX idx_refresh_tokens_subject ON refresh_tokens (subject) WHERE revoked_at IS NULL`,
+		)
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE refresh_tokens`,
+		)
+	},
+}
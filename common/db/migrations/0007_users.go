@@ -0,0 +1,45 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// users adds the user accounts with their bcrypt password hashes and roles
+var users = Migration{
+	Version: 7,
+	Name:    "users",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE users (
+				id bigserial PRIMARY KEY,
+				username text NOT NULL,
+				password_hash text NOT NULL,
+				role text NOT NULL CHECK (role IN ('admin', 'editor', 'clerk', 'reader')),
+				created_at timestamptz NOT NULL,
+				updated_at timestamptz NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX idx_users_username ON users (username)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE users`,
+		)
+	},
+	SQLiteUp: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE users (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				username text NOT NULL,
+				password_hash text NOT NULL,
+				role text NOT NULL CHECK (role IN ('admin', 'editor', 'clerk', 'reader')),
+				created_at datetime NOT NULL,
+				updated_at datetime NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX idx_users_username ON users (username)`,
+		)
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE users`,
+		)
+	},
+}
@@ -0,0 +1,55 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// apiKeys adds the scoped api keys of machine clients, only a sha256 hash of each key is stored
+var apiKeys = Migration{
+	Version: 8,
+	Name:    "api_keys",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE api_keys (
+				id bigserial PRIMARY KEY,
+				name text NOT NULL,
+				prefix text NOT NULL,
+				key_hash text NOT NULL,
+				scopes text NOT NULL,
+				expires_at timestamptz NOT NULL,
+				last_used_at timestamptz,
+				revoked_at timestamptz,
+				created_by text NOT NULL,
+				created_at timestamptz NOT NULL,
+				updated_at timestamptz NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX idx_api_keys_key_hash ON api_keys (key_hash)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE api_keys`,
+		)
+	},
+	SQLiteUp: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE api_keys (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				name text NOT NULL,
+				prefix text NOT NULL,
+				key_hash text NOT NULL,
+				scopes text NOT NULL,
+				expires_at datetime NOT NULL,
+				last_used_at datetime,
+				revoked_at datetime,
+				created_by text NOT NULL,
+				created_at datetime NOT NULL,
+				updated_at datetime NOT NULL
+			)`,
+			`CREATE UNIQUE INDEX idx_api_keys_key_hash ON api_keys (key_hash)`,
+		)
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE api_keys`,
+		)
+	},
+}
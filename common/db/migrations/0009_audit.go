@@ -0,0 +1,49 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// audit adds the audit trail of catalog changes with the changed fields of each change
+var audit = Migration{
+	Version: 9,
+	Name:    "audit",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE audit_entries (
+				id bigserial PRIMARY KEY,
+				actor text NOT NULL,
+				operation text NOT NULL,
+				entity text NOT NULL,
+				entity_id text NOT NULL,
+				changes jsonb NOT NULL,
+				created_at timestamptz NOT NULL
+			)`,
+			`CREATE INDEX idx_audit_entries_entity ON audit_entries (entity, entity_id, id)`,
+			`CREATE INDEX idx_audit_entries_actor ON audit_entries (actor, id)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE audit_entries`,
+		)
+	},
+	SQLiteUp: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE audit_entries (
+				id integer PRIMARY KEY AUTOINCREMENT,
+				actor text NOT NULL,
+				operation text NOT NULL,
+				entity text NOT NULL,
+				entity_id text NOT NULL,
+				changes text NOT NULL,
+				created_at datetime NOT NULL
+			)`,
+			`CREATE INDEX idx_audit_entries_entity ON audit_entries (entity, entity_id, id)`,
+			`CREATE INDEX idx_audit_entries_actor ON audit_entries (actor, id)`,
+		)
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE audit_entries`,
+		)
+	},
+}
@@ -0,0 +1,33 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// versions adds the version of books and authors that every change increments, for optimistic concurrency
+var versions = Migration{
+	Version: 10,
+	Name:    "versions",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE books ADD COLUMN version bigint NOT NULL DEFAULT 1`,
+			`ALTER TABLE authors ADD COLUMN version bigint NOT NULL DEFAULT 1`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE authors DROP COLUMN version`,
+			`ALTER TABLE books DROP COLUMN version`,
+		)
+	},
+	SQLiteUp: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE books ADD COLUMN version integer NOT NULL DEFAULT 1`,
+			`ALTER TABLE authors ADD COLUMN version integer NOT NULL DEFAULT 1`,
+		)
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`ALTER TABLE authors DROP COLUMN version`,
+			`ALTER TABLE books DROP COLUMN version`,
+		)
+	},
+}
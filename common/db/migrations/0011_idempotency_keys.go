@@ -0,0 +1,51 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// idempotencyKeys adds the stored responses of requests sent with an Idempotency-Key, keys are per principal
+var idempotencyKeys = Migration{
+	Version: 11,
+	Name:    "idempotency_keys",
+	Up: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE idempotency_keys (
+				principal text NOT NULL,
+				key text NOT NULL,
+				fingerprint text NOT NULL,
+				status integer NOT NULL DEFAULT 0,
+				headers jsonb NOT NULL DEFAULT '{}',
+				body bytea,
+				created_at timestamptz NOT NULL,
+				expires_at timestamptz NOT NULL,
+				PRIMARY KEY (principal, key)
+			)`,
+			`CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at)`,
+		)
+	},
+	Down: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE idempotency_keys`,
+		)
+	},
+	SQLiteUp: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`CREATE TABLE idempotency_keys (
+				principal text NOT NULL,
+				key text NOT NULL,
+				fingerprint text NOT NULL,
+				status integer NOT NULL DEFAULT 0,
+				headers text NOT NULL DEFAULT '{}',
+				body blob,
+				created_at datetime NOT NULL,
+				expires_at datetime NOT NULL,
+				PRIMARY KEY (principal, key)
+			)`,
+			`CREATE INDEX idx_idempotency_keys_expires_at ON idempotency_keys (expires_at)`,
+		)
+	},
+	SQLiteDown: func(tx *gorm.DB) error {
+		return execAll(tx,
+			`DROP TABLE idempotency_keys`,
+		)
+	},
+}
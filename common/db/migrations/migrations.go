@@ -0,0 +1,295 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// lockKey is the postgres advisory lock key that serializes migration runs across server instances
+const lockKey = 5_042_022
+
+var ErrUnknownVersion = errors.New("Unknown migration version")
+
+// ErrNotMigrated is returned by Applied when the database has no schema_migrations table
+var ErrNotMigrated = errors.New("schema_migrations does not exist, the database is not migrated")
+
+// Migration is a numbered schema change, versions are applied in ascending order and rolled back in descending order.
+// SQLiteUp and SQLiteDown are the same change for sqlite, whose databases start empty and so have no legacy rows
+type Migration struct {
+	Version    uint
+	Name       string
+	Up         func(tx *gorm.DB) error
+	Down       func(tx *gorm.DB) error
+	SQLiteUp   func(tx *gorm.DB) error
+	SQLiteDown func(tx *gorm.DB) error
+}
+
+// SchemaMigration is a row of the schema_migrations table
+type SchemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time
+}
+
+// Status is the state of a known migration
+type Status struct {
+	Version   uint
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// All returns every known migration in version order
+func All() []Migration {
+	return []Migration{
+		initialSchema,
+		typedBooks,
+		search,
+		stockLedger,
+		orders,
+		refreshTokens,
+		users,
+		apiKeys,
+		audit,
+		versions,
+		idempotencyKeys,
+	}
+}
+
+// Latest returns the version of the newest known migration
+func Latest() uint {
+	all := All()
+	return all[len(all)-1].Version
+}
+
+// Up applies every migration that is not recorded in schema_migrations yet
+func Up(db *gorm.DB) error {
+	return To(db, Latest())
+}
+
+// Down rolls back the newest applied migration
+func Down(db *gorm.DB) error {
+	return withLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+		all := All()
+		for i := len(all) - 1; i >= 0; i-- {
+			if applied[all[i].Version] {
+				return rollback(conn, all[i])
+			}
+		}
+		log.Println("No migration to roll back")
+		return nil
+	})
+}
+
+// To applies or rolls back migrations until version is the newest applied one, version 0 rolls back everything
+func To(db *gorm.DB, version uint) error {
+	if version != 0 && find(version) == nil {
+		return fmt.Errorf("%w: %d", ErrUnknownVersion, version)
+	}
+
+	return withLock(db, func(conn *gorm.DB) error {
+		applied, err := appliedVersions(conn)
+		if err != nil {
+			return err
+		}
+
+		all := All()
+		for i := len(all) - 1; i >= 0; i-- {
+			if all[i].Version > version && applied[all[i].Version] {
+				if err := rollback(conn, all[i]); err != nil {
+					return err
+				}
+			}
+		}
+		for _, m := range all {
+			if m.Version <= version && !applied[m.Version] {
+				if err := apply(conn, m); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// Current returns the newest applied version, 0 when nothing is applied
+func Current(db *gorm.DB) (uint, error) {
+	if err := ensureTable(db); err != nil {
+		return 0, err
+	}
+	var version uint
+	err := db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// Applied returns the newest applied version like Current but only reads, the schema_migrations table is not
+// created and ErrNotMigrated is returned when it does not exist
+func Applied(db *gorm.DB) (uint, error) {
+	if !db.Migrator().HasTable(&SchemaMigration{}) {
+		return 0, ErrNotMigrated
+	}
+	var version uint
+	err := db.Model(&SchemaMigration{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error
+	return version, err
+}
+
+// Statuses returns every known migration with its applied state
+func Statuses(db *gorm.DB) ([]Status, error) {
+	if err := ensureTable(db); err != nil {
+		return nil, err
+	}
+
+	var rows []SchemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	appliedAt := map[uint]time.Time{}
+	for _, row := range rows {
+		appliedAt[row.Version] = row.AppliedAt
+	}
+
+	var statuses []Status
+	for _, m := range All() {
+		at, ok := appliedAt[m.Version]
+		statuses = append(statuses, Status{Version: m.Version, Name: m.Name, Applied: ok, AppliedAt: at})
+	}
+	return statuses, nil
+}
+
+// apply runs a migration in its own transaction together with its schema_migrations row
+func apply(db *gorm.DB, m Migration) error {
+	up := m.Up
+	if isSQLite(db) {
+		up = m.SQLiteUp
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := up(tx); err != nil {
+			return err
+		}
+		return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migration %d %s failed: %w", m.Version, m.Name, err)
+	}
+	log.Printf("Migration %d %s applied", m.Version, m.Name)
+	return nil
+}
+
+// rollback reverts a migration in its own transaction together with its schema_migrations row
+func rollback(db *gorm.DB, m Migration) error {
+	down := m.Down
+	if isSQLite(db) {
+		down = m.SQLiteDown
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := down(tx); err != nil {
+			return err
+		}
+		return tx.Delete(&SchemaMigration{}, m.Version).Error
+	})
+	if err != nil {
+		return fmt.Errorf("rollback of migration %d %s failed: %w", m.Version, m.Name, err)
+	}
+	log.Printf("Migration %d %s rolled back", m.Version, m.Name)
+	return nil
+}
+
+// withLock runs fn on a single connection that holds the migration advisory lock,
+// a second instance blocks here until the first one has finished. Sqlite has no advisory locks,
+// its migrations run in transactions that hold the write lock of the whole database
+func withLock(db *gorm.DB, fn func(conn *gorm.DB) error) error {
+	if isSQLite(db) {
+		if err := ensureTable(db); err != nil {
+			return err
+		}
+		return fn(db)
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx := context.Background()
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("cannot take migration lock: %w", err)
+	}
+	defer conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", lockKey)
+
+	session, err := gorm.Open(postgres.New(postgres.Config{Conn: conn}), &gorm.Config{Logger: db.Logger})
+	if err != nil {
+		return err
+	}
+	if err := ensureTable(session); err != nil {
+		return err
+	}
+	return fn(session)
+}
+
+func ensureTable(db *gorm.DB) error {
+	timestamp := "timestamptz"
+	if isSQLite(db) {
+		timestamp = "datetime"
+	}
+	if err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version bigint PRIMARY KEY,
+		name text NOT NULL,
+		applied_at ` + timestamp + ` NOT NULL
+	)`).Error; err != nil {
+		return fmt.Errorf("cannot create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func appliedVersions(db *gorm.DB) (map[uint]bool, error) {
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := map[uint]bool{}
+	for _, row := range rows {
+		if find(row.Version) == nil {
+			return nil, fmt.Errorf("%w: %d is applied but not known to this build", ErrUnknownVersion, row.Version)
+		}
+		applied[row.Version] = true
+	}
+	return applied, nil
+}
+
+func find(version uint) *Migration {
+	for _, m := range All() {
+		if m.Version == version {
+			return &m
+		}
+	}
+	return nil
+}
+
+// isSQLite tells a sqlite database from postgres
+func isSQLite(db *gorm.DB) bool {
+	return db.Dialector.Name() == "sqlite"
+}
+
+func execAll(tx *gorm.DB, statements ...string) error {
+	for _, statement := range statements {
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
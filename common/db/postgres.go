@@ -2,21 +2,19 @@ package postgres
 
 import (
 	"fmt"
-	"os"
+	"net"
+	"net/url"
+	"strconv"
 
+	"github.com/BatuhanSerin/postgresql/common/config"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
-func NewPsqlDB() (*gorm.DB, error) {
-	dataSourceName := fmt.Sprintf("host=%s port=%s user=%s dbname=%s sslmode=disable password=%s",
-		os.Getenv("PATIKA_DB_HOST"),
-		os.Getenv("PATIKA_DB_PORT"),
-		os.Getenv("PATIKA_DB_USERNAME"),
-		os.Getenv("PATIKA_DB_NAME"),
-		os.Getenv("PATIKA_DB_PASSWORD"),
-	)
-	db, err := gorm.Open(postgres.Open(dataSourceName), &gorm.Config{})
+// NewPsqlDB opens a connection pool to the postgres server of c with the pool and TLS settings of c. A server that is
+// still starting is retried with exponential backoff for c.ConnectRetry
+func NewPsqlDB(c config.Database) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dataSourceName(c)), &gorm.Config{DisableAutomaticPing: true})
 	if err != nil {
 		return nil, fmt.Errorf("cannot open database : %v", err.Error())
 	}
@@ -24,8 +22,35 @@ func NewPsqlDB() (*gorm.DB, error) {
 	if err != nil {
 		return nil, err
 	}
-	if err := sqlDB.Ping(); err != nil {
-		return nil, err
+	sqlDB.SetMaxOpenConns(c.MaxOpenConns)
+	sqlDB.SetMaxIdleConns(c.MaxIdleConns)
+	sqlDB.SetConnMaxLifetime(c.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(c.ConnMaxIdleTime)
+	if err := ping(sqlDB, c.ConnectRetry); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("cannot connect to database %s : %v", postgresName(c), err)
 	}
 	return db, nil
 }
+
+// dataSourceName is the postgres URL of c, the credentials and the certificate path are escaped
+func dataSourceName(c config.Database) string {
+	query := url.Values{}
+	query.Set("sslmode", c.SSLMode)
+	if c.SSLRootCert != "" {
+		query.Set("sslrootcert", c.SSLRootCert)
+	}
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.Username, c.Password),
+		Host:     net.JoinHostPort(c.Host, strconv.Itoa(c.Port)),
+		Path:     "/" + c.Name,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// postgresName names the database of c without its credentials
+func postgresName(c config.Database) string {
+	return net.JoinHostPort(c.Host, strconv.Itoa(c.Port)) + "/" + c.Name
+}
@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"github.com/glebarez/go-sqlite"
+	gormsqlite "github.com/glebarez/sqlite"
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+// Sqlite result codes, https://www.sqlite.org/rescode.html
+const (
+	sqliteBusy                 = 5
+	sqliteLocked               = 6
+	sqliteConstraintCheck      = 275
+	sqliteConstraintForeignKey = 787
+	sqliteConstraintNotNull    = 1299
+	sqliteConstraintPrimaryKey = 1555
+	sqliteConstraintUnique     = 2067
+)
+
+// sqliteCodes are the postgres codes of the sqlite constraint violations
+var sqliteCodes = map[int]string{
+	sqliteConstraintUnique:     "23505",
+	sqliteConstraintPrimaryKey: "23505",
+	sqliteConstraintForeignKey: "23503",
+	sqliteConstraintCheck:      "23514",
+	sqliteConstraintNotNull:    "23502",
+}
+
+// sqliteDialector translates the errors of sqlite to the postgres errors of the same violation, so callers only
+// classify postgres errors
+type sqliteDialector struct {
+	*gormsqlite.Dialector
+}
+
+// NewSQLiteDB opens the sqlite database file of c with the pure Go driver, it is created when it does not exist.
+// Foreign keys are enforced and LIKE is case sensitive like in postgres. Transactions take the write lock when they
+// begin, which stands in for the row locks of postgres, and a single connection keeps a :memory: database shared
+func NewSQLiteDB(c config.Database) (*gorm.DB, error) {
+	params := url.Values{}
+	params.Add("_pragma", "foreign_keys(1)")
+	params.Add("_pragma", "case_sensitive_like(1)")
+	params.Add("_pragma", "busy_timeout(5000)")
+	params.Set("_txlock", "immediate")
+	if c.Path != ":memory:" {
+		params.Add("_pragma", "journal_mode(WAL)")
+	}
+
+	dialector := sqliteDialector{&gormsqlite.Dialector{DSN: "file:" + c.Path + "?" + params.Encode()}}
+	db, err := gorm.Open(dialector, &gorm.Config{TranslateError: true})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open database : %v", err.Error())
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	if err := sqlDB.Ping(); err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// Translate returns a sqlite constraint violation or lock timeout as the postgres error of the same code. Sqlite
+// names the failed columns as table.column in the message, a foreign key violation names neither table nor
+// constraint
+func (d sqliteDialector) Translate(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+	if primary := sqliteErr.Code() & 0xff; primary == sqliteBusy || primary == sqliteLocked {
+		return &pgconn.PgError{Code: "55P03", Message: sqliteErr.Error()}
+	}
+	code, ok := sqliteCodes[sqliteErr.Code()]
+	if !ok {
+		return err
+	}
+
+	pgErr := &pgconn.PgError{Code: code, Message: sqliteErr.Error()}
+	if code == "23503" {
+		return pgErr
+	}
+	// "constraint failed: UNIQUE constraint failed: books.isbn, books.stock_code (2067)"
+	failed := sqliteErr.Error()
+	if i := strings.LastIndex(failed, "constraint failed: "); i >= 0 {
+		failed = failed[i+len("constraint failed: "):]
+	}
+	if i := strings.LastIndex(failed, " ("); i >= 0 {
+		failed = failed[:i]
+	}
+	pgErr.ConstraintName = failed
+	var columns []string
+	for _, column := range strings.Split(failed, ", ") {
+		if dot := strings.IndexByte(column, '.'); dot >= 0 {
+			pgErr.TableName = column[:dot]
+			columns = append(columns, column[dot+1:])
+		}
+	}
+	pgErr.ColumnName = strings.Join(columns, ", ")
+	return pgErr
+}
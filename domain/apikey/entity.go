@@ -0,0 +1,111 @@
+package apikey
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/user"
+)
+
+// Prefix starts every api key, so that a key can be told apart from a JWT
+const Prefix = "bk_"
+
+// DefaultTTL is the lifetime of a key created without an expiry
+const DefaultTTL = 90 * 24 * time.Hour
+
+// adminScopes cannot be given to a key, keys never manage users or other keys
+var adminScopes = map[string]bool{
+	user.UsersManage:   true,
+	user.APIKeysManage: true,
+}
+
+// Scopes are the permissions of a key, stored space separated
+type Scopes []string
+
+// Value implements driver.Valuer
+func (s Scopes) Value() (driver.Value, error) {
+	return strings.Join(s, " "), nil
+}
+
+// Scan implements sql.Scanner
+func (s *Scopes) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case string:
+		*s = strings.Fields(v)
+	case []byte:
+		*s = strings.Fields(string(v))
+	default:
+		return fmt.Errorf("cannot scan %T into Scopes", src)
+	}
+	return nil
+}
+
+// APIKey is a key of a machine client, Prefix is the start of the key so that it can be recognized
+type APIKey struct {
+	ID         uint
+	Name       string
+	Prefix     string
+	KeyHash    string `json:"-"`
+	Scopes     Scopes `gorm:"type:text"`
+	ExpiresAt  time.Time
+	LastUsedAt *time.Time
+	RevokedAt  *time.Time
+	CreatedBy  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// CreatedKey is a new or rotated key with its secret, the secret is only shown this once
+type CreatedKey struct {
+	APIKey
+	Key string
+}
+
+// NewKey is the body of a new key, ExpiresAt defaults to DefaultTTL from now
+type NewKey struct {
+	Name      string
+	Scopes    []string
+	ExpiresAt *time.Time
+}
+
+// Validate checks the key fields and returns the rejected ones with their reasons
+func (k NewKey) Validate() map[string]string {
+	fields := map[string]string{}
+
+	if strings.TrimSpace(k.Name) == "" {
+		fields["Name"] = "is required"
+	}
+	if len(k.Scopes) == 0 {
+		fields["Scopes"] = "must contain at least one scope"
+	}
+	for _, scope := range k.Scopes {
+		if !user.ValidPermission(scope) || adminScopes[scope] {
+			fields["Scopes"] = fmt.Sprintf("%q is not a scope a key can have", scope)
+		}
+	}
+	if k.ExpiresAt != nil && !k.ExpiresAt.After(time.Now()) {
+		fields["ExpiresAt"] = "must be in the future"
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// HasScope reports whether the key grants scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Active reports whether the key is neither revoked nor expired at now
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && now.Before(k.ExpiresAt)
+}
@@ -0,0 +1,141 @@
+package apikey
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrInvalidKey = errors.New("Invalid API key")
+	ErrRevoked    = errors.New("API key is revoked")
+)
+
+// lastUsedPrecision limits how often the last use of a key is written
+const lastUsedPrecision = time.Minute
+
+//APIKeyRepository is a struct for APIKeyRepository
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+//NewAPIKeyRepository returns APIKey Repository
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+//WithContext returns a repository whose queries run with ctx
+func (a *APIKeyRepository) WithContext(ctx context.Context) *APIKeyRepository {
+	return &APIKeyRepository{db: a.db.WithContext(ctx)}
+}
+
+//Create generates a key with the given scopes, only its hash is stored
+func (a *APIKeyRepository) Create(k NewKey, createdBy string) (*CreatedKey, error) {
+	secret, prefix, hash := NewSecret()
+	key := APIKey{
+		Name:      strings.TrimSpace(k.Name),
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    k.Scopes,
+		ExpiresAt: time.Now().Add(DefaultTTL),
+		CreatedBy: createdBy,
+	}
+	if k.ExpiresAt != nil {
+		key.ExpiresAt = *k.ExpiresAt
+	}
+	if result := a.db.Create(&key); result.Error != nil {
+		return nil, result.Error
+	}
+	return &CreatedKey{APIKey: key, Key: secret}, nil
+}
+
+//FindAll returns every key ordered by ID
+func (a *APIKeyRepository) FindAll() ([]APIKey, error) {
+	keys := []APIKey{}
+	if result := a.db.Order("id").Find(&keys); result.Error != nil {
+		return nil, result.Error
+	}
+	return keys, nil
+}
+
+//Rotate replaces the secret of a key, the old secret stops working at once
+func (a *APIKeyRepository) Rotate(id uint) (*CreatedKey, error) {
+	var key APIKey
+	if result := a.db.First(&key, id); result.Error != nil {
+		return nil, result.Error
+	}
+	if key.RevokedAt != nil {
+		return nil, ErrRevoked
+	}
+
+	secret, prefix, hash := NewSecret()
+	key.Prefix = prefix
+	key.KeyHash = hash
+	result := a.db.Model(&key).Select("prefix", "key_hash").Updates(&key)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &CreatedKey{APIKey: key, Key: secret}, nil
+}
+
+//Revoke disables a key for good
+func (a *APIKeyRepository) Revoke(id uint) (*APIKey, error) {
+	var key APIKey
+	if result := a.db.First(&key, id); result.Error != nil {
+		return nil, result.Error
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		if result := a.db.Model(&key).Update("revoked_at", now); result.Error != nil {
+			return nil, result.Error
+		}
+	}
+	return &key, nil
+}
+
+//Authenticate returns the active key of secret and records its use
+func (a *APIKeyRepository) Authenticate(secret string) (*APIKey, error) {
+	var key APIKey
+	result := a.db.Where("key_hash = ?", HashSecret(secret)).First(&key)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, ErrInvalidKey
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	now := time.Now()
+	if !key.Active(now) {
+		return nil, ErrInvalidKey
+	}
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedPrecision {
+		key.LastUsedAt = &now
+		if result := a.db.Model(&key).UpdateColumn("last_used_at", now); result.Error != nil {
+			return nil, result.Error
+		}
+	}
+	return &key, nil
+}
+
+//NewSecret returns a new secret, the start of it that recognizes the key and its hash
+func NewSecret() (secret, prefix, hash string) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	secret = Prefix + hex.EncodeToString(b)
+	return secret, secret[:len(Prefix)+8], HashSecret(secret)
+}
+
+//HashSecret hashes a key with sha256, keys are random so they need no salt or slow hash
+func HashSecret(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,43 @@
+package apikey
+
+import "context"
+
+//APIKeyStore keeps the api keys, every call runs with the context of its request.
+//APIKeyRepository keeps them in the database through Store
+type APIKeyStore interface {
+	Create(ctx context.Context, k NewKey, createdBy string) (*CreatedKey, error)
+	FindAll(ctx context.Context) ([]APIKey, error)
+	Rotate(ctx context.Context, id uint) (*CreatedKey, error)
+	Revoke(ctx context.Context, id uint) (*APIKey, error)
+	Authenticate(ctx context.Context, secret string) (*APIKey, error)
+}
+
+//Store returns the repository as an APIKeyStore
+func (a *APIKeyRepository) Store() APIKeyStore {
+	return repoStore{repo: a}
+}
+
+//repoStore runs every call of an APIKeyStore on the repository with the context of the call
+type repoStore struct {
+	repo *APIKeyRepository
+}
+
+func (s repoStore) Create(ctx context.Context, k NewKey, createdBy string) (*CreatedKey, error) {
+	return s.repo.WithContext(ctx).Create(k, createdBy)
+}
+
+func (s repoStore) FindAll(ctx context.Context) ([]APIKey, error) {
+	return s.repo.WithContext(ctx).FindAll()
+}
+
+func (s repoStore) Rotate(ctx context.Context, id uint) (*CreatedKey, error) {
+	return s.repo.WithContext(ctx).Rotate(id)
+}
+
+func (s repoStore) Revoke(ctx context.Context, id uint) (*APIKey, error) {
+	return s.repo.WithContext(ctx).Revoke(id)
+}
+
+func (s repoStore) Authenticate(ctx context.Context, secret string) (*APIKey, error) {
+	return s.repo.WithContext(ctx).Authenticate(secret)
+}
@@ -0,0 +1,102 @@
+package audit
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Operations of an entry
+const (
+	Create  = "create"
+	Update  = "update"
+	Delete  = "delete"
+	Restore = "restore"
+	// Purge removes a deleted or live entity for good
+	Purge = "purge"
+)
+
+// Entities of an entry
+const (
+	Book   = "book"
+	Author = "author"
+)
+
+// actorKey is the context key of the actor
+type actorKey struct{}
+
+// Entry is a recorded change of an entity
+type Entry struct {
+	ID        uint
+	Actor     string
+	Operation string
+	Entity    string
+	EntityID  string
+	Changes   Changes `gorm:"type:jsonb"`
+	CreatedAt time.Time
+}
+
+// TableName keeps the entries apart from other tables called entries
+func (Entry) TableName() string {
+	return "audit_entries"
+}
+
+// FieldChange is the old and new value of a field, Old is null for a create and New for a delete
+type FieldChange struct {
+	Old interface{}
+	New interface{}
+}
+
+// Changes are the changed fields of an entry by field name, stored as jsonb
+type Changes map[string]FieldChange
+
+// Value implements driver.Valuer
+func (c Changes) Value() (driver.Value, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner
+func (c *Changes) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	}
+	return fmt.Errorf("cannot scan %T into Changes", src)
+}
+
+// Filter selects entries, zero fields match everything; BeforeID pages back from an entry
+type Filter struct {
+	Entity   string
+	EntityID string
+	Actor    string
+	BeforeID uint
+	Limit    int
+}
+
+// WithActor returns a context whose writes are recorded as made by actor
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFrom returns the actor of ctx, empty when there is none
+func ActorFrom(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	actor, _ := ctx.Value(actorKey{}).(string)
+	return actor
+}
+
+// ID formats the numeric id of an entity as the EntityID of an entry
+func ID(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
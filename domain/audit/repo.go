@@ -0,0 +1,150 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"reflect"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// DefaultLimit and MaxLimit bound the entries of a query
+const (
+	DefaultLimit = 50
+	MaxLimit     = 500
+)
+
+// ignoredFields change on every write and are left out of the diff
+var ignoredFields = map[string]bool{
+	"CreatedAt": true,
+	"UpdatedAt": true,
+	"Books":     true,
+	"Version":   true,
+}
+
+//Change is a write to record, Actor is used when the context of the transaction has no actor
+type Change struct {
+	Operation string
+	Entity    string
+	EntityID  string
+	Before    interface{}
+	After     interface{}
+	Actor     string
+}
+
+//AuditRepository is a struct for AuditRepository
+type AuditRepository struct {
+	db *gorm.DB
+}
+
+//NewAuditRepository returns Audit Repository
+func NewAuditRepository(db *gorm.DB) *AuditRepository {
+	return &AuditRepository{db: db}
+}
+
+//WithContext returns a repository whose queries run with ctx
+func (a *AuditRepository) WithContext(ctx context.Context) *AuditRepository {
+	return &AuditRepository{db: a.db.WithContext(ctx)}
+}
+
+//Find returns the entries of the filter, newest first
+func (a *AuditRepository) Find(f Filter) ([]Entry, error) {
+	query := a.db.Order("id desc")
+	if f.Entity != "" {
+		query = query.Where("entity = ?", f.Entity)
+	}
+	if f.EntityID != "" {
+		query = query.Where("entity_id = ?", f.EntityID)
+	}
+	if f.Actor != "" {
+		query = query.Where("actor = ?", f.Actor)
+	}
+	if f.BeforeID != 0 {
+		query = query.Where("id < ?", f.BeforeID)
+	}
+	limit := f.Limit
+	if limit <= 0 || limit > MaxLimit {
+		limit = DefaultLimit
+	}
+
+	entries := []Entry{}
+	if result := query.Limit(limit).Find(&entries); result.Error != nil {
+		return nil, result.Error
+	}
+	return entries, nil
+}
+
+//Record writes an entry with the changed fields in tx, so that the change and its entry are committed together.
+//An update that changes nothing is not recorded
+func Record(tx *gorm.DB, c Change) error {
+	changes, err := diff(c.Before, c.After)
+	if err != nil {
+		return err
+	}
+	if len(changes) == 0 && c.Operation == Update {
+		return nil
+	}
+
+	actor := ActorFrom(tx.Statement.Context)
+	if actor == "" {
+		actor = c.Actor
+	}
+	if actor == "" {
+		actor = "system"
+	}
+
+	entry := Entry{
+		Actor:     actor,
+		Operation: c.Operation,
+		Entity:    c.Entity,
+		EntityID:  c.EntityID,
+		Changes:   changes,
+		CreatedAt: time.Now(),
+	}
+	return tx.Create(&entry).Error
+}
+
+//diff compares the JSON fields of before and after, either may be nil
+func diff(before, after interface{}) (Changes, error) {
+	old, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	new, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	// a field missing on one side is null there, fields that are null on both sides are left out
+	changes := Changes{}
+	for name, value := range old {
+		if !ignoredFields[name] && !reflect.DeepEqual(value, new[name]) {
+			changes[name] = FieldChange{Old: value, New: new[name]}
+		}
+	}
+	for name, value := range new {
+		if _, ok := old[name]; !ok && !ignoredFields[name] && value != nil {
+			changes[name] = FieldChange{New: value}
+		}
+	}
+	return changes, nil
+}
+
+func fields(v interface{}) (map[string]interface{}, error) {
+	if v == nil || (reflect.ValueOf(v).Kind() == reflect.Ptr && reflect.ValueOf(v).IsNil()) {
+		return map[string]interface{}{}, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	m := map[string]interface{}{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,23 @@
+package audit
+
+import "context"
+
+//AuditStore reads the audit trail, every call runs with the context of its request.
+//AuditRepository reads it from the database through Store
+type AuditStore interface {
+	Find(ctx context.Context, f Filter) ([]Entry, error)
+}
+
+//Store returns the repository as an AuditStore
+func (a *AuditRepository) Store() AuditStore {
+	return repoStore{repo: a}
+}
+
+//repoStore runs every call of an AuditStore on the repository with the context of the call
+type repoStore struct {
+	repo *AuditRepository
+}
+
+func (s repoStore) Find(ctx context.Context, f Filter) ([]Entry, error) {
+	return s.repo.WithContext(ctx).Find(f)
+}
@@ -0,0 +1,69 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+//Config tells how tokens are signed and checked
+type Config struct {
+	// Algorithm is HS256 with Secret or RS256 with PrivateKey and PublicKey
+	Algorithm  string
+	Secret     []byte
+	PrivateKey *rsa.PrivateKey
+	PublicKey  *rsa.PublicKey
+	Issuer     string
+	Audience   string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+}
+
+//NewConfig builds the token configuration from the jwt settings, RS256 keys are read from PEM files
+func NewConfig(settings config.JWT) (Config, error) {
+	c := Config{
+		Algorithm:  settings.Algorithm,
+		Issuer:     settings.Issuer,
+		Audience:   settings.Audience,
+		AccessTTL:  settings.AccessTTL,
+		RefreshTTL: settings.RefreshTTL,
+	}
+
+	switch c.Algorithm {
+	case "HS256":
+		c.Secret = []byte(settings.Secret)
+		if len(c.Secret) < 32 {
+			return c, fmt.Errorf("jwt.secret must be at least 32 bytes for HS256")
+		}
+	case "RS256":
+		if path := settings.PrivateKey; path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return c, fmt.Errorf("jwt.private_key: %v", err)
+			}
+			if c.PrivateKey, err = jwt.ParseRSAPrivateKeyFromPEM(data); err != nil {
+				return c, fmt.Errorf("jwt.private_key: %v", err)
+			}
+			c.PublicKey = &c.PrivateKey.PublicKey
+		}
+		if path := settings.PublicKey; path != "" {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return c, fmt.Errorf("jwt.public_key: %v", err)
+			}
+			if c.PublicKey, err = jwt.ParseRSAPublicKeyFromPEM(data); err != nil {
+				return c, fmt.Errorf("jwt.public_key: %v", err)
+			}
+		}
+		if c.PublicKey == nil {
+			return c, fmt.Errorf("RS256 needs jwt.private_key or jwt.public_key")
+		}
+	default:
+		return c, fmt.Errorf("jwt.algorithm must be HS256 or RS256, not %q", c.Algorithm)
+	}
+	return c, nil
+}
@@ -0,0 +1,48 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// Token types, a refresh token is not accepted where an access token is expected and the other way round
+const (
+	Access  = "access"
+	Refresh = "refresh"
+)
+
+//Claims are the claims of an access or refresh token, the subject is the user ID
+type Claims struct {
+	jwt.RegisteredClaims
+	Type string `json:"typ"`
+	Role string `json:"role,omitempty"`
+}
+
+//Tokens is the answer to a login or refresh, ExpiresIn is the lifetime of the access token in seconds
+type Tokens struct {
+	AccessToken  string
+	RefreshToken string
+	TokenType    string
+	ExpiresIn    int
+}
+
+//Credentials is the body of a login
+type Credentials struct {
+	Username string
+	Password string
+}
+
+//RefreshToken records an issued refresh token by its jti
+type RefreshToken struct {
+	ID        string
+	Subject   string
+	ExpiresAt time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+//RefreshRequest is the body of a refresh or logout
+type RefreshRequest struct {
+	RefreshToken string
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/user"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// AuthRepository is a struct for AuthRepository
+type AuthRepository struct {
+	db     *gorm.DB
+	config Config
+}
+
+// NewAuthRepository returns Auth Repository
+func NewAuthRepository(db *gorm.DB, config Config) *AuthRepository {
+	return &AuthRepository{db: db, config: config}
+}
+
+// WithContext returns a repository whose queries run with ctx
+func (a *AuthRepository) WithContext(ctx context.Context) *AuthRepository {
+	return &AuthRepository{db: a.db.WithContext(ctx), config: a.config}
+}
+
+// Login checks the credentials and issues an access and a refresh token carrying the role of the user
+func (a *AuthRepository) Login(c Credentials) (*Tokens, error) {
+	u, err := user.NewUserRepository(a.db).Authenticate(c.Username, c.Password)
+	if err != nil {
+		return nil, err
+	}
+	return a.issue(a.db, u)
+}
+
+// Refresh trades a refresh token for new tokens, each refresh token is used once.
+// Presenting a refresh token again revokes every refresh token of its subject, as it may have been stolen
+func (a *AuthRepository) Refresh(token string) (*Tokens, error) {
+	claims, err := a.Parse(token, Refresh)
+	if err != nil {
+		return nil, err
+	}
+
+	var tokens *Tokens
+	reused := false
+	err = a.db.Transaction(func(tx *gorm.DB) error {
+		var stored RefreshToken
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", claims.ID).First(&stored)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: unknown refresh token", ErrInvalidClaims)
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		if stored.RevokedAt != nil {
+			reused = true
+			return nil
+		}
+
+		// the role is read again so that a role change takes effect on the next refresh
+		id, err := strconv.ParseUint(stored.Subject, 10, 64)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidClaims, err)
+		}
+		u, err := user.NewUserRepository(tx).GetByID(uint(id))
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("%w: user no longer exists", ErrInvalidClaims)
+		}
+		if err != nil {
+			return err
+		}
+
+		if result := tx.Model(&stored).Update("revoked_at", time.Now()); result.Error != nil {
+			return result.Error
+		}
+		tokens, err = a.issue(tx, u)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		if err := a.revokeSubject(claims.Subject); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: refresh token was already used", ErrInvalidClaims)
+	}
+	return tokens, nil
+}
+
+// Logout revokes a refresh token, the access tokens issued with it stay valid until they expire
+func (a *AuthRepository) Logout(token string) error {
+	claims, err := a.Parse(token, Refresh)
+	if err != nil {
+		return err
+	}
+	result := a.db.Model(&RefreshToken{}).Where("id = ? AND revoked_at IS NULL", claims.ID).Update("revoked_at", time.Now())
+	return result.Error
+}
+
+// Parse checks the signature, expiry, issuer, audience and type of a token
+func (a *AuthRepository) Parse(token, tokenType string) (*Claims, error) {
+	return a.config.Parse(token, tokenType)
+}
+
+// issue signs an access and a refresh token for u and records the refresh token
+func (a *AuthRepository) issue(tx *gorm.DB, u *user.User) (*Tokens, error) {
+	now := time.Now()
+	tokens, refresh, err := a.config.Sign(strconv.FormatUint(uint64(u.ID), 10), u.Role, now)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := RefreshToken{
+		ID:        refresh.ID,
+		Subject:   refresh.Subject,
+		ExpiresAt: refresh.ExpiresAt.Time,
+		CreatedAt: now,
+	}
+	if result := tx.Create(&stored); result.Error != nil {
+		return nil, result.Error
+	}
+	return tokens, nil
+}
+
+func (a *AuthRepository) revokeSubject(subject string) error {
+	result := a.db.Model(&RefreshToken{}).Where("subject = ? AND revoked_at IS NULL", subject).Update("revoked_at", time.Now())
+	return result.Error
+}
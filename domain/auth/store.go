@@ -0,0 +1,38 @@
+package auth
+
+import "context"
+
+//AuthStore issues, refreshes and revokes the tokens, every call that reaches the database runs with the context of
+//its request. Parse only checks a token. AuthRepository keeps the refresh tokens in the database through Store
+type AuthStore interface {
+	Login(ctx context.Context, c Credentials) (*Tokens, error)
+	Refresh(ctx context.Context, token string) (*Tokens, error)
+	Logout(ctx context.Context, token string) error
+	Parse(token, tokenType string) (*Claims, error)
+}
+
+//Store returns the repository as an AuthStore
+func (a *AuthRepository) Store() AuthStore {
+	return repoStore{repo: a}
+}
+
+//repoStore runs every call of an AuthStore on the repository with the context of the call
+type repoStore struct {
+	repo *AuthRepository
+}
+
+func (s repoStore) Login(ctx context.Context, c Credentials) (*Tokens, error) {
+	return s.repo.WithContext(ctx).Login(c)
+}
+
+func (s repoStore) Refresh(ctx context.Context, token string) (*Tokens, error) {
+	return s.repo.WithContext(ctx).Refresh(token)
+}
+
+func (s repoStore) Logout(ctx context.Context, token string) error {
+	return s.repo.WithContext(ctx).Logout(token)
+}
+
+func (s repoStore) Parse(token, tokenType string) (*Claims, error) {
+	return s.repo.Parse(token, tokenType)
+}
@@ -0,0 +1,111 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+var (
+	ErrInvalidToken  = errors.New("Invalid token")
+	ErrInvalidClaims = errors.New("Invalid token claims")
+	ErrNoSigningKey  = errors.New("No key to sign tokens")
+)
+
+// Parse checks the signature, expiry, issuer, audience and type of a token
+func (c Config) Parse(token, tokenType string) (*Claims, error) {
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(token, claims, c.verifyKey, jwt.WithValidMethods([]string{c.Algorithm}))
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired), errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return nil, fmt.Errorf("%w: %v", ErrInvalidClaims, err)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+
+	switch {
+	case claims.ExpiresAt == nil:
+		return nil, fmt.Errorf("%w: token has no expiry", ErrInvalidClaims)
+	case !claims.VerifyIssuer(c.Issuer, true):
+		return nil, fmt.Errorf("%w: wrong issuer", ErrInvalidClaims)
+	case !claims.VerifyAudience(c.Audience, true):
+		return nil, fmt.Errorf("%w: wrong audience", ErrInvalidClaims)
+	case claims.Type != tokenType:
+		return nil, fmt.Errorf("%w: not an %s token", ErrInvalidClaims, tokenType)
+	case claims.Subject == "":
+		return nil, fmt.Errorf("%w: token has no subject", ErrInvalidClaims)
+	}
+	return claims, nil
+}
+
+// Sign signs an access token carrying role and a refresh token for subject. The claims of the refresh token are
+// returned as well, the store records them to use every refresh token once
+func (c Config) Sign(subject, role string, now time.Time) (*Tokens, *Claims, error) {
+	accessClaims := c.claims(subject, Access, now, c.AccessTTL)
+	accessClaims.Role = role
+	access, err := c.sign(accessClaims)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshClaims := c.claims(subject, Refresh, now, c.RefreshTTL)
+	refresh, err := c.sign(refreshClaims)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &Tokens{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(c.AccessTTL.Seconds()),
+	}, &refreshClaims, nil
+}
+
+func (c Config) claims(subject, tokenType string, now time.Time, ttl time.Duration) Claims {
+	return Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        newTokenID(),
+			Issuer:    c.Issuer,
+			Subject:   subject,
+			Audience:  jwt.ClaimStrings{c.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
+		},
+		Type: tokenType,
+	}
+}
+
+func (c Config) sign(claims Claims) (string, error) {
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(c.Algorithm), claims)
+	switch c.Algorithm {
+	case "HS256":
+		return token.SignedString(c.Secret)
+	case "RS256":
+		if c.PrivateKey == nil {
+			return "", ErrNoSigningKey
+		}
+		return token.SignedString(c.PrivateKey)
+	}
+	return "", ErrNoSigningKey
+}
+
+func (c Config) verifyKey(_ *jwt.Token) (interface{}, error) {
+	if c.Algorithm == "RS256" {
+		return c.PublicKey, nil
+	}
+	return c.Secret, nil
+}
+
+// newTokenID returns a random jti
+func newTokenID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
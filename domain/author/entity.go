@@ -2,6 +2,7 @@ package author
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/BatuhanSerin/postgresql/domain/book"
 	"gorm.io/gorm"
@@ -9,13 +10,31 @@ import (
 
 type Author struct {
 	gorm.Model
-	AuthorName string
-	AuthorID   string
-	Books      []book.Book `gorm:"foreignkey:AuthorID;references:AuthorID"`
+	AuthorName string      `gorm:"not null"`
+	AuthorID   uint        `gorm:"uniqueIndex;not null"`
+	Version    uint        `gorm:"not null;default:1"`
+	Books      []book.Book `gorm:"foreignkey:AuthorID;references:AuthorID;constraint:OnUpdate:CASCADE,OnDelete:RESTRICT"`
 }
 type authorSlice []Author
 
 // ToString returns author information
 func (a Author) ToString() string {
-	return fmt.Sprintf("\nAuthor id: %s\nAuthor name: %s", a.AuthorID, a.AuthorName)
+	return fmt.Sprintf("\nAuthor id: %d\nAuthor name: %s", a.AuthorID, a.AuthorName)
+}
+
+// Validate checks the author fields and returns the rejected ones with their reasons
+func (a Author) Validate() map[string]string {
+	fields := map[string]string{}
+
+	if a.AuthorID == 0 {
+		fields["AuthorID"] = "is required"
+	}
+	if strings.TrimSpace(a.AuthorName) == "" {
+		fields["AuthorName"] = "is required"
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
 }
@@ -1,18 +1,37 @@
 package author
 
 import (
+	"context"
 	"encoding/csv"
+	"errors"
 	"fmt"
-	"log"
 	"os"
+	"strconv"
 	"strings"
 
+	"github.com/BatuhanSerin/postgresql/domain/audit"
+	"github.com/BatuhanSerin/postgresql/domain/book"
 	"gorm.io/gorm"
 )
 
-//AuthorRepository is a struct for AuthorRepository
+var (
+	ErrVersionConflict        = errors.New("Author has been changed by someone else, fetch it again and retry")
+	ErrAuthorExists           = errors.New("Author with given id already exists")
+	ErrAuthorHasBooks         = errors.New("Author still has books, delete them with cascade or reassign them to another author")
+	ErrReassignTargetNotFound = errors.New("Author to reassign the books to does not exist")
+	ErrConflictingOptions     = errors.New("Cascade and reassign cannot be used together")
+)
+
+//DeleteOptions tells Delete what to do with the books of the author
+type DeleteOptions struct {
+	Cascade    bool
+	ReassignTo uint
+}
+
+//AuthorRepository is a struct for AuthorRepository, a quiet repository does not print the authors it returns
 type AuthorRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	quiet bool
 }
 
 //NewAuthorRepository returns Author Repository
@@ -20,91 +39,217 @@ func NewAuthorRepository(db *gorm.DB) *AuthorRepository {
 	return &AuthorRepository{db: db}
 }
 
+//WithContext returns a repository whose queries run with ctx, the actor of ctx is recorded in the audit trail
+func (a *AuthorRepository) WithContext(ctx context.Context) *AuthorRepository {
+	return &AuthorRepository{db: a.db.WithContext(ctx), quiet: a.quiet}
+}
+
 //GetAllAuthorsWithBookInformation returns all authors with book information
-func (a *AuthorRepository) GetAllAuthorsWithBookInformation() authorSlice {
-	var authors authorSlice
+func (a *AuthorRepository) GetAllAuthorsWithBookInformation() (authorSlice, error) {
+	authors := authorSlice{}
 	result := a.db.Preload("Books").Find(&authors)
 	if result.Error != nil {
-		fmt.Println(result.Error)
-		return nil
+		return nil, result.Error
 	}
 
 	for _, author := range authors {
-		fmt.Println(author.ToString())
-		fmt.Println("Books: ")
-		if len(author.Books) > 0 {
-			for _, book := range author.Books {
-				fmt.Println(book.ToString())
-				fmt.Println("=============================")
-			}
-		}
+		a.printAuthor(author)
+	}
+	return authors, nil
+}
+
+//printAuthor prints author with its books
+func (a *AuthorRepository) printAuthor(author Author) {
+	if a.quiet {
+		return
+	}
+	fmt.Println(author.ToString())
+	fmt.Println("Books: ")
+	for _, book := range author.Books {
+		fmt.Println(book.ToString())
+		fmt.Println("=============================")
 	}
-	return authors
 }
 
 //GetAuthorWithName returns author by its name
-func (a *AuthorRepository) GetAuthorWithName(name string) *Author {
+func (a *AuthorRepository) GetAuthorWithName(name string) (*Author, error) {
 	var authors *Author
 	Name := strings.Title(strings.ToLower(name))
-	result := a.db.Where(Author{AuthorName: Name}).Preload("Books").Find(&authors)
+	result := a.db.Where(Author{AuthorName: Name}).Preload("Books").First(&authors)
 	if result.Error != nil {
-		fmt.Println(result.Error)
-		return nil
+		return nil, result.Error
 	}
 
-	fmt.Println(authors.ToString())
-	fmt.Println("Books: ")
-	if len(authors.Books) > 0 {
-		for _, book := range authors.Books {
-			fmt.Println(book.ToString())
-			fmt.Println("=============================")
-		}
-	}
+	a.printAuthor(*authors)
 
-	return authors
+	return authors, nil
 }
 
-//**********************************______________________********************
-//Migrations Auto Migrates for authors
-func (a *AuthorRepository) Migrations() {
-	a.db.AutoMigrate(&Author{})
+//GetByAuthorID returns author by its author id with book information
+func (a *AuthorRepository) GetByAuthorID(authorID uint) (*Author, error) {
+	var author Author
+	result := a.db.Where("author_id = ?", authorID).Preload("Books").First(&author)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &author, nil
 }
 
-//InsertData inserts data from csv file to database with ReadCsvAuthor function
-func (a *AuthorRepository) InsertData() {
+//Create creates author in database if the author id is not taken
+func (a *AuthorRepository) Create(author *Author) error {
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		if result := tx.Unscoped().Model(&Author{}).Where("author_id = ?", author.AuthorID).Count(&count); result.Error != nil {
+			return result.Error
+		}
+		if count > 0 {
+			return ErrAuthorExists
+		}
 
-	err := a.ReadCsvAuthor()
-	if err != nil {
-		log.Fatal(err)
+		if result := tx.Omit("Books").Create(author); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, audit.Change{Operation: audit.Create, Entity: audit.Author, EntityID: audit.ID(author.AuthorID), After: author})
+	})
+}
+
+//Update updates author in database without touching its books. Version is the version the change is based on,
+//ErrVersionConflict is returned when the author has been changed since then
+func (a *AuthorRepository) Update(author *Author) error {
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		var before Author
+		if result := tx.Unscoped().First(&before, author.ID); result.Error != nil {
+			return result.Error
+		}
+		if before.Version != author.Version {
+			return ErrVersionConflict
+		}
+
+		saved := *author
+		saved.Version++
+		result := tx.Unscoped().Model(&saved).Where("version = ?", before.Version).
+			Select("*").Omit("Books", "CreatedAt").Updates(&saved)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+
+		err := audit.Record(tx, audit.Change{Operation: audit.Update, Entity: audit.Author, EntityID: audit.ID(author.AuthorID), Before: before, After: saved})
+		if err != nil {
+			return err
+		}
+		author.Version = saved.Version
+		author.UpdatedAt = saved.UpdatedAt
+		return nil
+	})
+}
+
+//Delete deletes author by its author id, books of the author are deleted or reassigned according to options
+func (a *AuthorRepository) Delete(authorID uint, options DeleteOptions) error {
+	if options.Cascade && options.ReassignTo != 0 {
+		return ErrConflictingOptions
 	}
 
+	return a.db.Transaction(func(tx *gorm.DB) error {
+		var author Author
+		if result := tx.Where("author_id = ?", authorID).First(&author); result.Error != nil {
+			return result.Error
+		}
+
+		var books []book.Book
+		if result := tx.Where("author_id = ?", authorID).Find(&books); result.Error != nil {
+			return result.Error
+		}
+
+		if len(books) > 0 {
+			switch {
+			case options.Cascade:
+				repo := book.NewBookRepository(tx)
+				for i := range books {
+					if err := repo.Delete(&books[i]); err != nil {
+						return err
+					}
+				}
+			case options.ReassignTo != 0:
+				var target Author
+				result := tx.Where("author_id = ?", options.ReassignTo).First(&target)
+				if errors.Is(result.Error, gorm.ErrRecordNotFound) || target.AuthorID == authorID {
+					return ErrReassignTargetNotFound
+				}
+				if result.Error != nil {
+					return result.Error
+				}
+				result = tx.Model(&book.Book{}).Where("author_id = ?", authorID).
+					Updates(map[string]interface{}{"author_id": target.AuthorID, "version": gorm.Expr("version + 1")})
+				if result.Error != nil {
+					return result.Error
+				}
+				for _, b := range books {
+					err := audit.Record(tx, audit.Change{
+						Operation: audit.Update,
+						Entity:    audit.Book,
+						EntityID:  audit.ID(b.ID),
+						Before:    map[string]uint{"AuthorID": authorID},
+						After:     map[string]uint{"AuthorID": target.AuthorID},
+					})
+					if err != nil {
+						return err
+					}
+				}
+			default:
+				return ErrAuthorHasBooks
+			}
+		}
+
+		before := author
+		if result := tx.Delete(&author); result.Error != nil {
+			return result.Error
+		}
+		return audit.Record(tx, audit.Change{Operation: audit.Delete, Entity: audit.Author, EntityID: audit.ID(author.AuthorID), Before: before})
+	})
+}
+
+//**********************************______________________********************
+//InsertData inserts data from csv file to database with ReadCsvAuthor function
+func (a *AuthorRepository) InsertData(path string) error {
+	return a.ReadCsvAuthor(path)
 }
 
 //ReadCsvAuthor reads datas from csv file
-func (a *AuthorRepository) ReadCsvAuthor() error {
-	f, err := os.Open("author.csv")
+func (a *AuthorRepository) ReadCsvAuthor(path string) error {
+	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
 	csvReader := csv.NewReader(f)
+	csvReader.TrimLeadingSpace = true
 	records, err := csvReader.ReadAll()
 	if err != nil {
 		return err
 	}
 
 	var authors = authorSlice{}
-	for _, line := range records[1:] {
+	for i, line := range records[1:] {
+		authorID, err := strconv.ParseUint(strings.TrimSpace(line[0]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("%s line %d: invalid author id: %v", path, i+2, err)
+		}
 		authors = append(authors, Author{
-			AuthorID:   line[0],
-			AuthorName: line[1],
+			AuthorID:   uint(authorID),
+			AuthorName: strings.TrimSpace(line[1]),
 		})
 	}
 	for _, author := range authors {
-		a.db.Where(Author{AuthorName: author.AuthorName}).
+		result := a.db.Unscoped().Where(Author{AuthorID: author.AuthorID}).
 			Attrs(Author{AuthorID: author.AuthorID, AuthorName: author.AuthorName}).
 			FirstOrCreate(&author)
+		if result.Error != nil {
+			return result.Error
+		}
 	}
 	return nil
 }
@@ -0,0 +1,48 @@
+package author
+
+import "context"
+
+//AuthorStore keeps the authors, every call runs with the context of its request.
+//AuthorRepository keeps them in postgres through Store, the memory package keeps them in memory
+type AuthorStore interface {
+	GetAllAuthorsWithBookInformation(ctx context.Context) ([]Author, error)
+	GetAuthorWithName(ctx context.Context, name string) (*Author, error)
+	GetByAuthorID(ctx context.Context, authorID uint) (*Author, error)
+	Create(ctx context.Context, author *Author) error
+	Update(ctx context.Context, author *Author) error
+	Delete(ctx context.Context, authorID uint, options DeleteOptions) error
+}
+
+//Store returns the repository as an AuthorStore, it does not print the authors it returns
+func (a *AuthorRepository) Store() AuthorStore {
+	return repoStore{repo: &AuthorRepository{db: a.db, quiet: true}}
+}
+
+//repoStore runs every call of an AuthorStore on the repository with the context of the call
+type repoStore struct {
+	repo *AuthorRepository
+}
+
+func (s repoStore) GetAllAuthorsWithBookInformation(ctx context.Context) ([]Author, error) {
+	return s.repo.WithContext(ctx).GetAllAuthorsWithBookInformation()
+}
+
+func (s repoStore) GetAuthorWithName(ctx context.Context, name string) (*Author, error) {
+	return s.repo.WithContext(ctx).GetAuthorWithName(name)
+}
+
+func (s repoStore) GetByAuthorID(ctx context.Context, authorID uint) (*Author, error) {
+	return s.repo.WithContext(ctx).GetByAuthorID(authorID)
+}
+
+func (s repoStore) Create(ctx context.Context, author *Author) error {
+	return s.repo.WithContext(ctx).Create(author)
+}
+
+func (s repoStore) Update(ctx context.Context, author *Author) error {
+	return s.repo.WithContext(ctx).Update(author)
+}
+
+func (s repoStore) Delete(ctx context.Context, authorID uint, options DeleteOptions) error {
+	return s.repo.WithContext(ctx).Delete(authorID, options)
+}
@@ -2,26 +2,102 @@ package book
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 
+	"github.com/BatuhanSerin/postgresql/common/money"
 	"gorm.io/gorm"
 )
 
+// isbnPattern is an ISBN with optional hyphens between the digits, the check digit of an ISBN-10 may be X
+var isbnPattern = regexp.MustCompile(`^[0-9]+(-[0-9]+)*(-?[Xx])?$`)
+
 type Book struct {
 	gorm.Model
-	ID        string
-	Name      string
-	Page      string
-	Stock     string
-	Cost      string
-	StockCode string
-	ISBN      string
-	AuthorID  string
+	Name      string      `gorm:"not null"`
+	Page      int         `gorm:"not null"`
+	Stock     int         `gorm:"not null"`
+	Reserved  int         `gorm:"not null;default:0"`
+	Cost      money.Money `gorm:"type:numeric(12,2);not null"`
+	StockCode string      `gorm:"uniqueIndex;not null"`
+	ISBN      string      `gorm:"uniqueIndex;not null"`
+	AuthorID  uint        `gorm:"index;not null"`
+	Version   uint        `gorm:"not null;default:1"`
 }
 
 type bookSlice []Book
 
 // ToString returns book information
 func (book Book) ToString() string {
-	return fmt.Sprintf("id: %s\nName: %s\nPage: %s\nStock: %s\nCost: %s\nStockCode: %s\nISBN: %s",
+	return fmt.Sprintf("id: %d\nName: %s\nPage: %d\nStock: %d\nCost: %s\nStockCode: %s\nISBN: %s",
 		book.ID, book.Name, book.Page, book.Stock, book.Cost, book.StockCode, book.ISBN)
 }
+
+// Validate checks the book fields and returns the rejected ones with their reasons
+func (book Book) Validate() map[string]string {
+	fields := map[string]string{}
+
+	if strings.TrimSpace(book.Name) == "" {
+		fields["Name"] = "is required"
+	}
+	if strings.TrimSpace(book.StockCode) == "" {
+		fields["StockCode"] = "is required"
+	}
+	if book.AuthorID == 0 {
+		fields["AuthorID"] = "is required"
+	}
+
+	isbn := strings.TrimSpace(book.ISBN)
+	if isbn == "" {
+		fields["ISBN"] = "is required"
+	} else if !isbnPattern.MatchString(isbn) || !validISBN(strings.ReplaceAll(isbn, "-", "")) {
+		fields["ISBN"] = "must be an ISBN-10 or ISBN-13 with a valid check digit, optionally separated by hyphens"
+	}
+
+	if book.Page <= 0 {
+		fields["Page"] = "must be a positive integer"
+	}
+	if book.Stock < 0 {
+		fields["Stock"] = "must be a non-negative integer"
+	}
+	if book.Cost < 0 {
+		fields["Cost"] = "must be a non-negative amount"
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// validISBN checks the length and the check digit of an ISBN-10 or ISBN-13 without hyphens
+func validISBN(isbn string) bool {
+	sum := 0
+	switch len(isbn) {
+	case 10:
+		for i, c := range isbn {
+			digit := int(c - '0')
+			if c == 'X' || c == 'x' {
+				if i != 9 {
+					return false
+				}
+				digit = 10
+			}
+			sum += (10 - i) * digit
+		}
+		return sum%11 == 0
+	case 13:
+		for i, c := range isbn {
+			if c == 'X' || c == 'x' {
+				return false
+			}
+			weight := 1
+			if i%2 == 1 {
+				weight = 3
+			}
+			sum += weight * int(c-'0')
+		}
+		return sum%10 == 0
+	}
+	return false
+}
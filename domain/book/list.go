@@ -0,0 +1,449 @@
+package book
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/money"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 20
+	MaxLimit     = 100
+)
+
+var (
+	ErrInvalidSort   = errors.New("Invalid sort field")
+	ErrInvalidCursor = errors.New("Invalid cursor")
+)
+
+// sortColumns is the allowlist of sort fields and the columns they order by,
+// nothing else ever reaches an Order clause
+var sortColumns = map[string]string{
+	"id":         "id",
+	"name":       "name",
+	"page":       "page",
+	"stock":      "stock",
+	"cost":       "cost",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+//SortField is a column to order by
+type SortField struct {
+	Column string
+	Desc   bool
+}
+
+//Filter narrows the listed books, zero values are ignored
+type Filter struct {
+	AuthorID uint
+	MinStock *int
+	MaxCost  *money.Money
+	ISBN     string
+}
+
+//ListQuery describes a page of books, either by Offset or by Cursor
+type ListQuery struct {
+	Filter Filter
+	Sort   []SortField
+	Limit  int
+	Offset int
+	Cursor string
+}
+
+//ListResult is a page of books with the total count of books matching the filter
+type ListResult struct {
+	Books      bookSlice
+	Total      int64
+	NextCursor string
+	PrevCursor string
+}
+
+type cursor struct {
+	Sort     string   `json:"s"`
+	Values   []string `json:"v"`
+	Backward bool     `json:"b,omitempty"`
+	args     []interface{}
+}
+
+//ParseSort parses a sort parameter such as "name,-cost", a leading "-" orders descending
+func ParseSort(s string) ([]SortField, error) {
+	var fields []SortField
+	seen := map[string]bool{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		desc := strings.HasPrefix(part, "-")
+		name := strings.TrimPrefix(part, "-")
+		column, ok := sortColumns[name]
+		if !ok || seen[column] {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidSort, name)
+		}
+		seen[column] = true
+		fields = append(fields, SortField{Column: column, Desc: desc})
+	}
+	return fields, nil
+}
+
+//List returns a page of books matching the query
+func (b *BookRepository) List(q ListQuery) (*ListResult, error) {
+	q.Limit = pageLimit(q.Limit)
+	order := withTieBreaker(q.Sort)
+
+	result := &ListResult{}
+	if err := q.Filter.apply(b.db.Model(&Book{})).Count(&result.Total).Error; err != nil {
+		return nil, err
+	}
+
+	query := q.Filter.apply(b.db)
+	if q.Cursor == "" {
+		query = orderBy(query, order, false).Offset(q.Offset).Limit(q.Limit)
+		if err := query.Find(&result.Books).Error; err != nil {
+			return nil, err
+		}
+		offsetPage(result, q.Offset, order)
+		return result, nil
+	}
+
+	c, err := decodeCursor(q.Cursor, order)
+	if err != nil {
+		return nil, err
+	}
+	query = keyset(query, order, c.args, c.Backward)
+	query = orderBy(query, order, c.Backward).Limit(q.Limit + 1)
+	if err := query.Find(&result.Books).Error; err != nil {
+		return nil, err
+	}
+	keysetPage(result, q.Limit, order, c.Backward)
+	return result, nil
+}
+
+//Page returns the page of books a query selects from books the way List does in the database,
+//for stores that keep their books in memory
+func Page(books []Book, q ListQuery) (*ListResult, error) {
+	q.Limit = pageLimit(q.Limit)
+	order := withTieBreaker(q.Sort)
+
+	matching := bookSlice{}
+	for _, book := range books {
+		if q.Filter.matches(book) {
+			matching = append(matching, book)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		return compareBooks(matching[i], matching[j], order) < 0
+	})
+	result := &ListResult{Total: int64(len(matching))}
+
+	if q.Cursor == "" {
+		start, end := len(matching), len(matching)
+		if q.Offset < start {
+			start = q.Offset
+		}
+		if start+q.Limit < end {
+			end = start + q.Limit
+		}
+		result.Books = matching[start:end]
+		offsetPage(result, start, order)
+		return result, nil
+	}
+
+	c, err := decodeCursor(q.Cursor, order)
+	if err != nil {
+		return nil, err
+	}
+	at := cursorBook(order, c.args)
+	result.Books = bookSlice{}
+	for i := range matching {
+		book := matching[i]
+		if c.Backward {
+			book = matching[len(matching)-1-i]
+		}
+		if cmp := compareBooks(book, at, order); (cmp > 0 && !c.Backward) || (cmp < 0 && c.Backward) {
+			result.Books = append(result.Books, book)
+		}
+		if len(result.Books) > q.Limit {
+			break
+		}
+	}
+	keysetPage(result, q.Limit, order, c.Backward)
+	return result, nil
+}
+
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+	if limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
+
+//offsetPage sets the cursor of the page after a page read at offset
+func offsetPage(result *ListResult, offset int, order []SortField) {
+	if len(result.Books) > 0 && offset+len(result.Books) < int(result.Total) {
+		result.NextCursor = encodeCursor(order, result.Books[len(result.Books)-1], false)
+	}
+}
+
+//keysetPage trims a page read after a cursor with one extra row to limit, puts it back in sort order
+//and sets the cursors of the pages around it
+func keysetPage(result *ListResult, limit int, order []SortField, backward bool) {
+	more := len(result.Books) > limit
+	if more {
+		result.Books = result.Books[:limit]
+	}
+	if backward {
+		for i, j := 0, len(result.Books)-1; i < j; i, j = i+1, j-1 {
+			result.Books[i], result.Books[j] = result.Books[j], result.Books[i]
+		}
+	}
+	if len(result.Books) == 0 {
+		return
+	}
+
+	first, last := result.Books[0], result.Books[len(result.Books)-1]
+	if backward {
+		result.NextCursor = encodeCursor(order, last, false)
+		if more {
+			result.PrevCursor = encodeCursor(order, first, true)
+		}
+	} else {
+		result.PrevCursor = encodeCursor(order, first, true)
+		if more {
+			result.NextCursor = encodeCursor(order, last, false)
+		}
+	}
+}
+
+func (f Filter) apply(db *gorm.DB) *gorm.DB {
+	if f.AuthorID != 0 {
+		db = db.Where("author_id = ?", f.AuthorID)
+	}
+	if f.MinStock != nil {
+		db = db.Where("stock >= ?", *f.MinStock)
+	}
+	if f.MaxCost != nil {
+		db = db.Where("cost <= ?", *f.MaxCost)
+	}
+	if f.ISBN != "" {
+		db = db.Where("isbn = ?", f.ISBN)
+	}
+	return db
+}
+
+func (f Filter) matches(book Book) bool {
+	return (f.AuthorID == 0 || book.AuthorID == f.AuthorID) &&
+		(f.MinStock == nil || book.Stock >= *f.MinStock) &&
+		(f.MaxCost == nil || book.Cost <= *f.MaxCost) &&
+		(f.ISBN == "" || book.ISBN == f.ISBN)
+}
+
+//withTieBreaker appends id to the sort fields so that every row has a unique position
+func withTieBreaker(sort []SortField) []SortField {
+	order := append([]SortField{}, sort...)
+	for _, field := range order {
+		if field.Column == "id" {
+			return order
+		}
+	}
+	return append(order, SortField{Column: "id"})
+}
+
+func orderBy(db *gorm.DB, order []SortField, reverse bool) *gorm.DB {
+	for _, field := range order {
+		direction := "ASC"
+		if field.Desc != reverse {
+			direction = "DESC"
+		}
+		db = db.Order(sortExpr(db, field.Column) + " " + direction)
+	}
+	return db
+}
+
+//sortExpr is the expression a sort column is ordered and compared by. Names are compared byte by byte like Page
+//does, not in the collation of the database, so a cursor selects the same page on every store. Sqlite compares
+//bytes already and has no "C" collation
+func sortExpr(db *gorm.DB, column string) string {
+	if column == "name" && db.Dialector.Name() != "sqlite" {
+		return `name COLLATE "C"`
+	}
+	return column
+}
+
+//keyset keeps the rows after the cursor values in the sort order, or before them when backward
+func keyset(db *gorm.DB, order []SortField, values []interface{}, backward bool) *gorm.DB {
+	var clauses []string
+	var args []interface{}
+	for i, field := range order {
+		var parts []string
+		for _, previous := range order[:i] {
+			parts = append(parts, sortExpr(db, previous.Column)+" = ?")
+		}
+		operator := ">"
+		if field.Desc != backward {
+			operator = "<"
+		}
+		parts = append(parts, sortExpr(db, field.Column)+" "+operator+" ?")
+		clauses = append(clauses, "("+strings.Join(parts, " AND ")+")")
+		args = append(args, values[:i+1]...)
+	}
+	return db.Where(strings.Join(clauses, " OR "), args...)
+}
+
+func sortKey(order []SortField) string {
+	var parts []string
+	for _, field := range order {
+		if field.Desc {
+			parts = append(parts, "-"+field.Column)
+		} else {
+			parts = append(parts, field.Column)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func encodeCursor(order []SortField, book Book, backward bool) string {
+	c := cursor{Sort: sortKey(order), Backward: backward}
+	for _, field := range order {
+		c.Values = append(c.Values, sortValue(book, field.Column))
+	}
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, order []SortField) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	if c.Sort != sortKey(order) || len(c.Values) != len(order) {
+		return c, fmt.Errorf("%w: it was issued for another sort", ErrInvalidCursor)
+	}
+	for i, field := range order {
+		arg, err := sortArg(field.Column, c.Values[i])
+		if err != nil {
+			return c, ErrInvalidCursor
+		}
+		c.args = append(c.args, arg)
+	}
+	return c, nil
+}
+
+func sortValue(book Book, column string) string {
+	switch column {
+	case "name":
+		return book.Name
+	case "page":
+		return strconv.Itoa(book.Page)
+	case "stock":
+		return strconv.Itoa(book.Stock)
+	case "cost":
+		return book.Cost.String()
+	case "created_at":
+		return book.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		return book.UpdatedAt.Format(time.RFC3339Nano)
+	}
+	return strconv.FormatUint(uint64(book.ID), 10)
+}
+
+//sortArg converts a cursor value back to the type of its column
+func sortArg(column, value string) (interface{}, error) {
+	switch column {
+	case "name":
+		return value, nil
+	case "page", "stock":
+		return strconv.Atoi(value)
+	case "cost":
+		return money.Parse(value)
+	case "created_at", "updated_at":
+		return time.Parse(time.RFC3339Nano, value)
+	}
+	return strconv.ParseUint(value, 10, 64)
+}
+
+//compareBooks orders two books by the sort fields, the way orderBy orders their rows
+func compareBooks(a, b Book, order []SortField) int {
+	for _, field := range order {
+		cmp := compareColumn(a, b, field.Column)
+		if field.Desc {
+			cmp = -cmp
+		}
+		if cmp != 0 {
+			return cmp
+		}
+	}
+	return 0
+}
+
+func compareColumn(a, b Book, column string) int {
+	var less, greater bool
+	switch column {
+	case "name":
+		// byte order, the databases sort names with the same order
+		return strings.Compare(a.Name, b.Name)
+	case "page":
+		less, greater = a.Page < b.Page, a.Page > b.Page
+	case "stock":
+		less, greater = a.Stock < b.Stock, a.Stock > b.Stock
+	case "cost":
+		less, greater = a.Cost < b.Cost, a.Cost > b.Cost
+	case "created_at":
+		less, greater = a.CreatedAt.Before(b.CreatedAt), a.CreatedAt.After(b.CreatedAt)
+	case "updated_at":
+		less, greater = a.UpdatedAt.Before(b.UpdatedAt), a.UpdatedAt.After(b.UpdatedAt)
+	default:
+		less, greater = a.ID < b.ID, a.ID > b.ID
+	}
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
+	}
+	return 0
+}
+
+//cursorBook is a book with the sort values of a cursor, the rows of a page are compared with it
+func cursorBook(order []SortField, args []interface{}) Book {
+	var book Book
+	for i, field := range order {
+		switch v := args[i].(type) {
+		case string:
+			book.Name = v
+		case money.Money:
+			book.Cost = v
+		case uint64:
+			book.ID = uint(v)
+		case int:
+			if field.Column == "page" {
+				book.Page = v
+			} else {
+				book.Stock = v
+			}
+		case time.Time:
+			if field.Column == "created_at" {
+				book.CreatedAt = v
+			} else {
+				book.UpdatedAt = v
+			}
+		}
+	}
+	return book
+}
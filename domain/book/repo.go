@@ -1,20 +1,25 @@
 package book
 
 import (
+	"context"
 	"encoding/csv"
 	"errors"
 	"fmt"
-	"log"
 	"os"
 	"strconv"
 	"strings"
 
+	"github.com/BatuhanSerin/postgresql/common/money"
+	"github.com/BatuhanSerin/postgresql/domain/audit"
 	"gorm.io/gorm"
 )
 
-//BookRepository is a struct for BookRepository
+var ErrVersionConflict = errors.New("Book has been changed by someone else, fetch it again and retry")
+
+//BookRepository is a struct for BookRepository, a quiet repository does not print the books it returns
 type BookRepository struct {
-	db *gorm.DB
+	db    *gorm.DB
+	quiet bool
 }
 
 //NewBookRepository returns Book Repository
@@ -22,212 +27,290 @@ func NewBookRepository(db *gorm.DB) *BookRepository {
 	return &BookRepository{db: db}
 }
 
-// FindAll returns all informations of books
-func (b *BookRepository) FinAll() bookSlice {
-	var books bookSlice
-	b.db.Find(&books)
-	fmt.Println("Books: ")
-	if len(books) > 0 {
-		for _, book := range books {
-			fmt.Println(book.ToString())
-			fmt.Println("=============================")
-		}
+//WithContext returns a repository whose queries run with ctx, the actor of ctx is recorded in the audit trail
+func (b *BookRepository) WithContext(ctx context.Context) *BookRepository {
+	return &BookRepository{db: b.db.WithContext(ctx), quiet: b.quiet}
+}
 
+// FindAll returns all informations of books
+func (b *BookRepository) FinAll() (bookSlice, error) {
+	books := bookSlice{}
+	if result := b.db.Find(&books); result.Error != nil {
+		return nil, result.Error
 	}
-	return books
+	b.printBooks("Books: ", books...)
+	return books, nil
 }
 
 //FindBookById returns book by its ID
-func (b *BookRepository) FindBookById(id int) bookSlice {
-	var books bookSlice
-	strID := strconv.Itoa(id)
-	//b.db.Where("id = ?", strID).Order("id desc , name").Find(&books)
-	b.db.Where(&Book{ID: strID}).Order("id desc , name").Find(&books)
-	fmt.Println("Books: ")
-	if len(books) > 0 {
-		for _, book := range books {
-			fmt.Println(book.ToString())
-			fmt.Println("=============================")
-		}
+func (b *BookRepository) FindBookById(id int) (bookSlice, error) {
+	books := bookSlice{}
+	if result := b.db.Where("id = ?", id).Order("id desc , name").Find(&books); result.Error != nil {
+		return nil, result.Error
 	}
-	return books
+	b.printBooks("Books: ", books...)
+	return books, nil
 }
 
 //FindByAuthorOrBookId returns book by its author id or book id
-func (b *BookRepository) FindByAuthorOrBookId(id int) bookSlice {
-	var books bookSlice
-	strID := strconv.Itoa(id)
-
-	b.db.Where("id = ?", strID).Or("author_id = ?", strID).Find(&books)
-	fmt.Println("Books: ")
-	if len(books) > 0 {
-		for _, book := range books {
-			fmt.Println(book.ToString())
-			fmt.Println("=============================")
-		}
+func (b *BookRepository) FindByAuthorOrBookId(id int) (bookSlice, error) {
+	books := bookSlice{}
+
+	if result := b.db.Where("id = ?", id).Or("author_id = ?", id).Find(&books); result.Error != nil {
+		return nil, result.Error
 	}
-	return books
+	b.printBooks("Books: ", books...)
+	return books, nil
 
 }
 
-//FindByName returns book by its name
-func (b *BookRepository) FindByName(name string) bookSlice {
-	var books bookSlice
-	Name := strings.Title(strings.ToLower(name))
-	b.db.Where("name LIKE ? ", "%"+Name+"%").Find(&books)
-	fmt.Println("Books: ")
-	if len(books) > 0 {
-		for _, book := range books {
-			fmt.Println(book.ToString())
-			fmt.Println("=============================")
-		}
+//FindByName returns the books whose name contains name, whatever the case of either
+func (b *BookRepository) FindByName(name string) (bookSlice, error) {
+	books := bookSlice{}
+	if result := b.db.Where("lower(name) LIKE lower(?)", "%"+name+"%").Find(&books); result.Error != nil {
+		return nil, result.Error
 	}
-	return books
+	b.printBooks("Books: ", books...)
+	return books, nil
 }
 
-//FindByNameWithRawSql returns book by its name with raw sql
-func (b *BookRepository) FindByNameWithRawSql(name string) bookSlice {
-	var books bookSlice
-	b.db.Raw("SELECT * FROM books WHERE name LIKE ? ", "%"+name+"%").Scan(&books)
-
-	fmt.Println("Books: ")
-	if len(books) > 0 {
-		for _, book := range books {
-			fmt.Println(book.ToString())
-			fmt.Println("=============================")
-		}
+//FindByNameWithRawSql returns book by its name with raw sql, the LIKE is case sensitive on sqlite too because
+//its connections turn case_sensitive_like on
+func (b *BookRepository) FindByNameWithRawSql(name string) (bookSlice, error) {
+	books := bookSlice{}
+	if result := b.db.Raw("SELECT * FROM books WHERE name LIKE ? ", "%"+name+"%").Scan(&books); result.Error != nil {
+		return nil, result.Error
 	}
 
-	return books
+	b.printBooks("Books: ", books...)
+
+	return books, nil
+}
+
+//printBooks prints books under title
+func (b *BookRepository) printBooks(title string, books ...Book) {
+	if b.quiet {
+		return
+	}
+	fmt.Println(title)
+	for _, book := range books {
+		fmt.Println(book.ToString())
+		fmt.Println("=============================")
+	}
 }
 
 //GetByID returns book by its ID
 func (b *BookRepository) GetByID(id int) (*Book, error) {
 
 	var book Book
-	strID := strconv.Itoa(id)
-
-	result := b.db.First(&book, strID)
-	fmt.Println("Book: ")
-
-	fmt.Println(book.ToString())
-	fmt.Println("=============================")
 
-	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+	result := b.db.First(&book, id)
+	if result.Error != nil {
 		return nil, result.Error
 	}
+	b.printBooks("Book: ", book)
+
 	return &book, nil
 }
 
-//Create creates book in database
+//Create creates book in database, its initial stock is recorded as a receipt in the stock ledger
 func (b *BookRepository) Create(book *Book) error {
-	result := b.db.Create(book)
-	if result.Error != nil {
-		return result.Error
-	}
-	return nil
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		stock := book.Stock
+		book.Stock = 0
+		book.Reserved = 0
+		if result := tx.Create(book); result.Error != nil {
+			return result.Error
+		}
 
+		if stock != 0 {
+			_, err := moveStock(tx, &StockMovement{
+				BookID:   book.ID,
+				Kind:     Receipt,
+				Quantity: stock,
+				Reason:   "initial stock",
+				User:     "system",
+			})
+			if err != nil {
+				return err
+			}
+			// the receipt changed the stock and the version of the created row
+			if result := tx.First(book, book.ID); result.Error != nil {
+				return result.Error
+			}
+		}
+		return audit.Record(tx, audit.Change{Operation: audit.Create, Entity: audit.Book, EntityID: audit.ID(book.ID), After: book})
+	})
 }
 
-//Update updates book in database
+//Update updates book in database, stock only changes through the stock ledger. Version is the version the change
+//is based on, ErrVersionConflict is returned when the book has been changed since then
 func (b *BookRepository) Update(book *Book) error {
-	result := b.db.Save(book)
-	if result.Error != nil {
-		return result.Error
-	}
-	return nil
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		var before Book
+		if result := tx.Unscoped().First(&before, book.ID); result.Error != nil {
+			return result.Error
+		}
+		if before.Version != book.Version {
+			return ErrVersionConflict
+		}
+
+		saved := *book
+		saved.Stock = before.Stock
+		saved.Reserved = before.Reserved
+		saved.Version++
+		// the version condition catches a change committed after before was read
+		result := tx.Unscoped().Model(&saved).Where("version = ?", before.Version).
+			Select("*").Omit("Stock", "Reserved", "CreatedAt").Updates(&saved)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+
+		err := audit.Record(tx, audit.Change{Operation: audit.Update, Entity: audit.Book, EntityID: audit.ID(book.ID), Before: before, After: saved})
+		if err != nil {
+			return err
+		}
+		book.Version = saved.Version
+		book.UpdatedAt = saved.UpdatedAt
+		return nil
+	})
 }
 
 //Delete deletes book from database
 func (b *BookRepository) Delete(book *Book) error {
-	result := b.db.Delete(book)
-	if result.Error != nil {
-		return result.Error
-	}
-	return nil
+	return b.DeleteById(int(book.ID))
 }
 
 //DeleteById deletes book by its ID from database without checking the book is deleted or not
 func (b *BookRepository) DeleteById(id int) error {
-	strID := strconv.Itoa(id)
-	book := Book{ID: strID}
-	result := b.db.Delete(&book)
-
-	if result.Error != nil {
-		return result.Error
-	}
-	return nil
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		var book Book
+		result := tx.First(&book, id)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if result.Error != nil {
+			return result.Error
+		}
+		return deleteBook(tx, &book)
+	})
 }
 
 //BeforeDelete deletes book from database after checking the book is deleted or not
 func (b *BookRepository) BeforeDelete(id int) (err error) {
-	strID := strconv.Itoa(id)
 	var book Book
-	result := b.db.First(&book, strID)
-	if result.Error != nil {
-		return result.Error
-	}
-
-	if book.DeletedAt.Time.IsZero() {
-		fmt.Println("Deleted Book: ")
-
-		fmt.Println(book.ToString())
-		fmt.Println("=============================")
-
-		result := b.db.Delete(&book)
+	err = b.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Unscoped().First(&book, id)
 		if result.Error != nil {
-			return errors.New("This book has already been deleted")
+			return result.Error
 		}
-		return nil
+
+		if book.DeletedAt.Valid {
+			return ErrAlreadyDeleted
+		}
+		return deleteBook(tx, &book)
+	})
+	if err != nil {
+		return err
 	}
+
+	b.printBooks("Deleted Book: ", book)
 	return nil
 }
 
-//********************************************_____________________________*************************************
-//Migrations Auto Migrates for books
-func (b *BookRepository) Migrations() {
-	b.db.AutoMigrate(&Book{})
+//deleteBook soft deletes a live book and records the deletion
+func deleteBook(tx *gorm.DB, book *Book) error {
+	before := *book
+	if result := tx.Delete(book); result.Error != nil {
+		return result.Error
+	}
+	return audit.Record(tx, audit.Change{Operation: audit.Delete, Entity: audit.Book, EntityID: audit.ID(book.ID), Before: before})
 }
 
+//********************************************_____________________________*************************************
 //InsertData inserts data from csv file to database with ReadCsvBook function
-func (b *BookRepository) InsertData() {
-	err := b.ReadCsvBook()
-	if err != nil {
-		log.Fatal(err)
-	}
+func (b *BookRepository) InsertData(path string) error {
+	return b.ReadCsvBook(path)
 }
 
 //ReadCsvBook reads datas from csv file
-func (b *BookRepository) ReadCsvBook() error {
-	f, err := os.Open("book.csv")
+func (b *BookRepository) ReadCsvBook(path string) error {
+	f, err := os.Open(path)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
 
 	csvReader := csv.NewReader(f)
+	csvReader.TrimLeadingSpace = true
 	records, err := csvReader.ReadAll()
 	if err != nil {
 		return err
 	}
 
 	var books = bookSlice{}
-	for _, line := range records[1:] {
-		books = append(books, Book{
-			ID:        line[0],
-			Name:      line[1],
-			Page:      line[2],
-			Stock:     line[3],
-			Cost:      line[4],
-			StockCode: line[5],
-			ISBN:      line[6],
-			AuthorID:  line[7],
-		})
+	for i, line := range records[1:] {
+		book, err := parseCsvBook(line)
+		if err != nil {
+			return fmt.Errorf("%s line %d: %v", path, i+2, err)
+		}
+		books = append(books, book)
 	}
 	for _, book := range books {
-		b.db.Where(Book{Name: book.Name}).
-			Attrs(Book{ID: book.ID, Name: book.Name}).
-			FirstOrCreate(&book)
+		var count int64
+		if result := b.db.Unscoped().Model(&Book{}).Where(Book{Name: book.Name}).Count(&count); result.Error != nil {
+			return result.Error
+		}
+		if count > 0 {
+			continue
+		}
+		if err := b.Create(&book); err != nil {
+			return err
+		}
 	}
-	return nil
+
+	// the csv ids are inserted explicitly, so the id sequence has to skip them. Sqlite moves its sequence by itself
+	if b.db.Dialector.Name() == "sqlite" {
+		return nil
+	}
+	return b.db.Exec("SELECT setval('books_id_seq', (SELECT COALESCE(MAX(id), 0) + 1 FROM books), false)").Error
+}
+
+//parseCsvBook converts a book.csv record to a book
+func parseCsvBook(line []string) (Book, error) {
+	id, err := strconv.ParseUint(strings.TrimSpace(line[0]), 10, 64)
+	if err != nil {
+		return Book{}, fmt.Errorf("invalid id: %v", err)
+	}
+	page, err := strconv.Atoi(strings.TrimSpace(line[2]))
+	if err != nil {
+		return Book{}, fmt.Errorf("invalid page: %v", err)
+	}
+	stock, err := strconv.Atoi(strings.TrimSpace(line[3]))
+	if err != nil {
+		return Book{}, fmt.Errorf("invalid stock: %v", err)
+	}
+	cost, err := money.Parse(line[4])
+	if err != nil {
+		return Book{}, fmt.Errorf("invalid cost: %v", err)
+	}
+	authorID, err := strconv.ParseUint(strings.TrimSpace(line[7]), 10, 64)
+	if err != nil {
+		return Book{}, fmt.Errorf("invalid author id: %v", err)
+	}
+
+	book := Book{
+		Name:      strings.TrimSpace(line[1]),
+		Page:      page,
+		Stock:     stock,
+		Cost:      cost,
+		StockCode: strings.TrimSpace(line[5]),
+		ISBN:      strings.TrimSpace(line[6]),
+		AuthorID:  uint(authorID),
+	}
+	book.ID = uint(id)
+	return book, nil
 }
@@ -0,0 +1,319 @@
+package book
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/audit"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Kinds of stock movements
+const (
+	Receipt    = "receipt"
+	Sale       = "sale"
+	Adjustment = "adjustment"
+	Return     = "return"
+	Transfer   = "transfer"
+)
+
+// Statuses of stock reservations
+const (
+	Held      = "held"
+	Committed = "committed"
+	Released  = "released"
+)
+
+var (
+	ErrInsufficientStock  = errors.New("Not enough stock")
+	ErrReservationNotHeld = errors.New("Reservation is not held anymore")
+)
+
+//StockMovement is a row of the stock ledger, Quantity is positive for incoming and negative for outgoing stock
+type StockMovement struct {
+	ID         uint
+	BookID     uint
+	Kind       string
+	Quantity   int
+	StockAfter int
+	Reason     string
+	User       string `gorm:"column:created_by"`
+	Reference  string
+	CreatedAt  time.Time
+}
+
+//StockReservation holds stock for a later sale, it is committed into a sale movement or released
+type StockReservation struct {
+	ID        uint
+	BookID    uint
+	Quantity  int
+	Status    string
+	Reason    string
+	User      string `gorm:"column:created_by"`
+	Reference string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// Validate checks the movement fields and returns the rejected ones with their reasons
+func (m StockMovement) Validate() map[string]string {
+	fields := map[string]string{}
+
+	switch m.Kind {
+	case Receipt, Return:
+		if m.Quantity <= 0 {
+			fields["Quantity"] = "must be positive for a " + m.Kind
+		}
+	case Sale:
+		if m.Quantity >= 0 {
+			fields["Quantity"] = "must be negative for a sale"
+		}
+	case Adjustment, Transfer:
+		if m.Quantity == 0 {
+			fields["Quantity"] = "must not be zero"
+		}
+	default:
+		fields["Kind"] = "must be one of receipt, sale, adjustment, return, transfer"
+	}
+	if strings.TrimSpace(m.Reason) == "" {
+		fields["Reason"] = "is required"
+	}
+	if strings.TrimSpace(m.User) == "" {
+		fields["User"] = "is required"
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+// Validate checks the reservation fields and returns the rejected ones with their reasons
+func (r StockReservation) Validate() map[string]string {
+	fields := map[string]string{}
+
+	if r.Quantity <= 0 {
+		fields["Quantity"] = "must be a positive integer"
+	}
+	if strings.TrimSpace(r.Reason) == "" {
+		fields["Reason"] = "is required"
+	}
+	if strings.TrimSpace(r.User) == "" {
+		fields["User"] = "is required"
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+//RecordMovement adds a movement to the ledger and applies it to the stock of the book,
+//the stock can never drop below zero or below the reserved quantity
+func (b *BookRepository) RecordMovement(m *StockMovement) error {
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		return recordMovement(tx, m)
+	})
+}
+
+//Movements returns the ledger of a book, newest first
+func (b *BookRepository) Movements(bookID uint) ([]StockMovement, error) {
+	if _, err := b.findBook(b.db, bookID); err != nil {
+		return nil, err
+	}
+
+	movements := []StockMovement{}
+	result := b.db.Where("book_id = ?", bookID).Order("id desc").Find(&movements)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return movements, nil
+}
+
+//Reserve holds stock of a book when enough of it is neither sold nor reserved
+func (b *BookRepository) Reserve(r *StockReservation) error {
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		return reserve(tx, r)
+	})
+}
+
+//GetReservation returns reservation by its ID
+func (b *BookRepository) GetReservation(id uint) (*StockReservation, error) {
+	var r StockReservation
+	if result := b.db.First(&r, id); result.Error != nil {
+		return nil, result.Error
+	}
+	return &r, nil
+}
+
+//CommitReservation turns a held reservation into a sale movement
+func (b *BookRepository) CommitReservation(id uint, user string) (*StockReservation, error) {
+	var r StockReservation
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		return commitReservation(tx, id, user, &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+//ReleaseReservation gives the stock of a held reservation back
+func (b *BookRepository) ReleaseReservation(id uint) (*StockReservation, error) {
+	var r StockReservation
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		return releaseReservation(tx, id, &r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+func (b *BookRepository) findBook(db *gorm.DB, id uint) (*Book, error) {
+	var book Book
+	if result := db.First(&book, id); result.Error != nil {
+		return nil, result.Error
+	}
+	return &book, nil
+}
+
+//ReturnStock puts stock back like a return movement of RecordMovement, also when the book has been moved to the
+//trash since, so that cancelled and returned orders always get their stock back
+func (b *BookRepository) ReturnStock(m *StockMovement) error {
+	m.Kind = Return
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		// every statement of the session finds the book in the trash, the lock and the stock update included
+		return recordMovement(tx.Unscoped().Session(&gorm.Session{}), m)
+	})
+}
+
+//lockBook reads a book with a row lock that is held until the transaction ends
+func lockBook(tx *gorm.DB, id uint) (*Book, error) {
+	var book Book
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&book, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &book, nil
+}
+
+func lockReservation(tx *gorm.DB, id uint, r *StockReservation) error {
+	result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(r, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if r.Status != Held {
+		return fmt.Errorf("%w: it is %s", ErrReservationNotHeld, r.Status)
+	}
+	return nil
+}
+
+//recordMovement applies a movement and records the changed stock in the audit trail,
+//the user of the movement is the actor when the context has none
+func recordMovement(tx *gorm.DB, m *StockMovement) error {
+	before, err := moveStock(tx, m)
+	if err != nil {
+		return err
+	}
+	return audit.Record(tx, audit.Change{
+		Operation: audit.Update,
+		Entity:    audit.Book,
+		EntityID:  audit.ID(m.BookID),
+		Before:    map[string]int{"Stock": before},
+		After:     map[string]int{"Stock": m.StockAfter},
+		Actor:     m.User,
+	})
+}
+
+//moveStock adds a movement to the ledger and updates the stock of the book, it returns the stock before the movement
+func moveStock(tx *gorm.DB, m *StockMovement) (int, error) {
+	book, err := lockBook(tx, m.BookID)
+	if err != nil {
+		return 0, err
+	}
+
+	stock := book.Stock + m.Quantity
+	if stock < book.Reserved {
+		return 0, fmt.Errorf("%w: %d in stock, %d reserved", ErrInsufficientStock, book.Stock, book.Reserved)
+	}
+	m.StockAfter = stock
+	m.CreatedAt = time.Now()
+
+	if result := tx.Create(m); result.Error != nil {
+		return 0, result.Error
+	}
+	return book.Stock, setQuantity(tx, book.ID, "stock", stock)
+}
+
+//setQuantity writes the stock or the reserved quantity of a book, the version changes with it
+func setQuantity(tx *gorm.DB, id uint, column string, quantity int) error {
+	return tx.Model(&Book{}).Where("id = ?", id).Updates(map[string]interface{}{
+		column:    quantity,
+		"version": gorm.Expr("version + 1"),
+	}).Error
+}
+
+func reserve(tx *gorm.DB, r *StockReservation) error {
+	book, err := lockBook(tx, r.BookID)
+	if err != nil {
+		return err
+	}
+
+	if available := book.Stock - book.Reserved; r.Quantity > available {
+		return fmt.Errorf("%w: %d available", ErrInsufficientStock, available)
+	}
+	r.Status = Held
+	if result := tx.Create(r); result.Error != nil {
+		return result.Error
+	}
+	return setQuantity(tx, book.ID, "reserved", book.Reserved+r.Quantity)
+}
+
+func commitReservation(tx *gorm.DB, id uint, user string, r *StockReservation) error {
+	if err := lockReservation(tx, id, r); err != nil {
+		return err
+	}
+	book, err := lockBook(tx, r.BookID)
+	if err != nil {
+		return err
+	}
+
+	// the reserved quantity leaves the reservation before the sale is recorded, so the sale may use it
+	if err := setQuantity(tx, book.ID, "reserved", book.Reserved-r.Quantity); err != nil {
+		return err
+	}
+	err = recordMovement(tx, &StockMovement{
+		BookID:    r.BookID,
+		Kind:      Sale,
+		Quantity:  -r.Quantity,
+		Reason:    r.Reason,
+		User:      user,
+		Reference: fmt.Sprintf("reservation:%d", r.ID),
+	})
+	if err != nil {
+		return err
+	}
+
+	r.Status = Committed
+	return tx.Save(r).Error
+}
+
+func releaseReservation(tx *gorm.DB, id uint, r *StockReservation) error {
+	if err := lockReservation(tx, id, r); err != nil {
+		return err
+	}
+	book, err := lockBook(tx, r.BookID)
+	if err != nil {
+		return err
+	}
+
+	if err := setQuantity(tx, book.ID, "reserved", book.Reserved-r.Quantity); err != nil {
+		return err
+	}
+	r.Status = Released
+	return tx.Save(r).Error
+}
@@ -0,0 +1,109 @@
+package book
+
+import (
+	"context"
+	"time"
+)
+
+//BookStore keeps the books and their stock ledger, every call runs with the context of its request.
+//BookRepository keeps them in postgres through Store, the memory package keeps them in memory
+type BookStore interface {
+	List(ctx context.Context, q ListQuery) (*ListResult, error)
+	GetByID(ctx context.Context, id uint) (*Book, error)
+	FindByAuthorOrBookId(ctx context.Context, id uint) ([]Book, error)
+	FindByName(ctx context.Context, name string) ([]Book, error)
+	Create(ctx context.Context, book *Book) error
+	Update(ctx context.Context, book *Book) error
+	//Delete soft deletes a book, ErrAlreadyDeleted when it is deleted already
+	Delete(ctx context.Context, id uint) error
+
+	Trash(ctx context.Context) ([]Book, error)
+	Restore(ctx context.Context, id uint) (*Book, error)
+	HardDelete(ctx context.Context, id uint) error
+	Purge(ctx context.Context, cutoff time.Time) (int, error)
+
+	RecordMovement(ctx context.Context, m *StockMovement) error
+	Movements(ctx context.Context, bookID uint) ([]StockMovement, error)
+	Reserve(ctx context.Context, r *StockReservation) error
+	GetReservation(ctx context.Context, id uint) (*StockReservation, error)
+	CommitReservation(ctx context.Context, id uint, user string) (*StockReservation, error)
+	ReleaseReservation(ctx context.Context, id uint) (*StockReservation, error)
+}
+
+//Store returns the repository as a BookStore, it does not print the books it returns
+func (b *BookRepository) Store() BookStore {
+	return repoStore{repo: &BookRepository{db: b.db, quiet: true}}
+}
+
+//repoStore runs every call of a BookStore on the repository with the context of the call
+type repoStore struct {
+	repo *BookRepository
+}
+
+func (s repoStore) List(ctx context.Context, q ListQuery) (*ListResult, error) {
+	return s.repo.WithContext(ctx).List(q)
+}
+
+func (s repoStore) GetByID(ctx context.Context, id uint) (*Book, error) {
+	return s.repo.WithContext(ctx).GetByID(int(id))
+}
+
+func (s repoStore) FindByAuthorOrBookId(ctx context.Context, id uint) ([]Book, error) {
+	return s.repo.WithContext(ctx).FindByAuthorOrBookId(int(id))
+}
+
+func (s repoStore) FindByName(ctx context.Context, name string) ([]Book, error) {
+	return s.repo.WithContext(ctx).FindByName(name)
+}
+
+func (s repoStore) Create(ctx context.Context, book *Book) error {
+	return s.repo.WithContext(ctx).Create(book)
+}
+
+func (s repoStore) Update(ctx context.Context, book *Book) error {
+	return s.repo.WithContext(ctx).Update(book)
+}
+
+func (s repoStore) Delete(ctx context.Context, id uint) error {
+	return s.repo.WithContext(ctx).BeforeDelete(int(id))
+}
+
+func (s repoStore) Trash(ctx context.Context) ([]Book, error) {
+	return s.repo.WithContext(ctx).Trash()
+}
+
+func (s repoStore) Restore(ctx context.Context, id uint) (*Book, error) {
+	return s.repo.WithContext(ctx).Restore(id)
+}
+
+func (s repoStore) HardDelete(ctx context.Context, id uint) error {
+	return s.repo.WithContext(ctx).HardDelete(id)
+}
+
+func (s repoStore) Purge(ctx context.Context, cutoff time.Time) (int, error) {
+	return s.repo.WithContext(ctx).Purge(cutoff)
+}
+
+func (s repoStore) RecordMovement(ctx context.Context, m *StockMovement) error {
+	return s.repo.WithContext(ctx).RecordMovement(m)
+}
+
+func (s repoStore) Movements(ctx context.Context, bookID uint) ([]StockMovement, error) {
+	return s.repo.WithContext(ctx).Movements(bookID)
+}
+
+func (s repoStore) Reserve(ctx context.Context, r *StockReservation) error {
+	return s.repo.WithContext(ctx).Reserve(r)
+}
+
+func (s repoStore) GetReservation(ctx context.Context, id uint) (*StockReservation, error) {
+	return s.repo.WithContext(ctx).GetReservation(id)
+}
+
+func (s repoStore) CommitReservation(ctx context.Context, id uint, user string) (*StockReservation, error) {
+	return s.repo.WithContext(ctx).CommitReservation(id, user)
+}
+
+func (s repoStore) ReleaseReservation(ctx context.Context, id uint) (*StockReservation, error) {
+	return s.repo.WithContext(ctx).ReleaseReservation(id)
+}
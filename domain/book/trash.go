@@ -0,0 +1,168 @@
+package book
+
+import (
+	"errors"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/audit"
+	"gorm.io/gorm"
+)
+
+// purgeLockKey is the postgres advisory lock key that keeps server instances from purging at the same time
+const purgeLockKey = 5_042_023
+
+// purgeBatchSize is how many books a purge removes per transaction
+const purgeBatchSize = 500
+
+var (
+	ErrAlreadyDeleted = errors.New("This book has already been deleted")
+	ErrNotDeleted     = errors.New("This book is not deleted")
+	ErrAuthorDeleted  = errors.New("The author of this book is deleted, restore the author first")
+	ErrBookHasOrders  = errors.New("This book is on orders and cannot be removed permanently")
+)
+
+//Trash returns the soft deleted books, most recently deleted first
+func (b *BookRepository) Trash() ([]Book, error) {
+	books := []Book{}
+	result := b.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at desc, id").Find(&books)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return books, nil
+}
+
+//Restore undeletes a soft deleted book, the book of a deleted author stays deleted
+func (b *BookRepository) Restore(id uint) (*Book, error) {
+	var book Book
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Unscoped().First(&book, id); result.Error != nil {
+			return result.Error
+		}
+		if !book.DeletedAt.Valid {
+			return ErrNotDeleted
+		}
+
+		var authors int64
+		result := tx.Table("authors").Where("author_id = ? AND deleted_at IS NULL", book.AuthorID).Count(&authors)
+		if result.Error != nil {
+			return result.Error
+		}
+		if authors == 0 {
+			return ErrAuthorDeleted
+		}
+
+		before := book
+		result = tx.Unscoped().Model(&book).Updates(map[string]interface{}{"deleted_at": nil, "version": gorm.Expr("version + 1")})
+		if result.Error != nil {
+			return result.Error
+		}
+		book.DeletedAt = gorm.DeletedAt{}
+		book.Version++
+		return audit.Record(tx, audit.Change{Operation: audit.Restore, Entity: audit.Book, EntityID: audit.ID(book.ID), Before: before, After: book})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &book, nil
+}
+
+//HardDelete removes a book and its stock ledger for good, deleted or not. Books on orders are kept for the order history
+func (b *BookRepository) HardDelete(id uint) error {
+	return b.db.Transaction(func(tx *gorm.DB) error {
+		var book Book
+		if result := tx.Unscoped().First(&book, id); result.Error != nil {
+			return result.Error
+		}
+		return hardDelete(tx, []Book{book})
+	})
+}
+
+//Purge removes the books that have been soft deleted before cutoff for good and returns how many were removed,
+//books on orders are skipped. Books are removed purgeBatchSize at a time, each batch in its own transaction so the
+//locks are held briefly. When another server instance is purging nothing more is done
+func (b *BookRepository) Purge(cutoff time.Time) (int, error) {
+	purged := 0
+	for {
+		n, err := b.purgeBatch(cutoff)
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+		if n < purgeBatchSize {
+			return purged, nil
+		}
+		if err := b.db.Statement.Context.Err(); err != nil {
+			return purged, err
+		}
+	}
+}
+
+//purgeBatch removes at most purgeBatchSize of the books Purge removes, the oldest deleted first
+func (b *BookRepository) purgeBatch(cutoff time.Time) (int, error) {
+	purged := 0
+	err := b.db.Transaction(func(tx *gorm.DB) error {
+		// sqlite has no advisory locks, the transaction holds the write lock of the whole database instead
+		if tx.Dialector.Name() != "sqlite" {
+			var locked bool
+			if result := tx.Raw("SELECT pg_try_advisory_xact_lock(?)", purgeLockKey).Scan(&locked); result.Error != nil {
+				return result.Error
+			}
+			if !locked {
+				return nil
+			}
+		}
+
+		var books []Book
+		result := tx.Unscoped().
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Where("NOT EXISTS (SELECT 1 FROM order_items WHERE order_items.book_id = books.id)").
+			Order("deleted_at, id").
+			Limit(purgeBatchSize).
+			Find(&books)
+		if result.Error != nil {
+			return result.Error
+		}
+		if len(books) == 0 {
+			return nil
+		}
+		purged = len(books)
+		return hardDelete(tx, books)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return purged, nil
+}
+
+//hardDelete removes books with their stock movements and reservations, each removal is recorded as a purge
+func hardDelete(tx *gorm.DB, books []Book) error {
+	ids := make([]uint, len(books))
+	for i, book := range books {
+		ids[i] = book.ID
+	}
+
+	var orders int64
+	if result := tx.Table("order_items").Where("book_id IN ?", ids).Count(&orders); result.Error != nil {
+		return result.Error
+	}
+	if orders > 0 {
+		return ErrBookHasOrders
+	}
+
+	if result := tx.Where("book_id IN ?", ids).Delete(&StockReservation{}); result.Error != nil {
+		return result.Error
+	}
+	if result := tx.Where("book_id IN ?", ids).Delete(&StockMovement{}); result.Error != nil {
+		return result.Error
+	}
+	if result := tx.Unscoped().Where("id IN ?", ids).Delete(&Book{}); result.Error != nil {
+		return result.Error
+	}
+	for i := range books {
+		err := audit.Record(tx, audit.Change{Operation: audit.Purge, Entity: audit.Book, EntityID: audit.ID(books[i].ID), Before: &books[i]})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package exporter
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/author"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"gorm.io/gorm"
+)
+
+// Formats of an export
+const (
+	CSV    = "csv"
+	JSON   = "json"
+	NDJSON = "ndjson"
+	XLSX   = "xlsx"
+)
+
+var ErrUnknownFormat = errors.New("Unknown export format, use csv, json, ndjson or xlsx")
+
+var (
+	bookColumns   = []string{"ID", "Name", "Page", "Stock", "Reserved", "Cost", "StockCode", "ISBN", "AuthorID", "CreatedAt", "UpdatedAt"}
+	authorColumns = []string{"ID", "AuthorID", "AuthorName", "CreatedAt", "UpdatedAt"}
+)
+
+//Exporter is a struct for Exporter
+type Exporter struct {
+	db *gorm.DB
+}
+
+//NewExporter returns Exporter
+func NewExporter(db *gorm.DB) *Exporter {
+	return &Exporter{db: db}
+}
+
+//WithContext returns an exporter whose queries run with ctx
+func (e *Exporter) WithContext(ctx context.Context) *Exporter {
+	return &Exporter{db: e.db.WithContext(ctx)}
+}
+
+//ValidFormat reports whether format can be exported
+func ValidFormat(format string) bool {
+	switch format {
+	case CSV, JSON, NDJSON, XLSX:
+		return true
+	}
+	return false
+}
+
+//ExportBooks writes every book ordered by id, the csv and xlsx columns are the ones the import reads
+func (e *Exporter) ExportBooks(w io.Writer, format string) error {
+	return e.export(w, format, "Books", bookColumns, e.db.Model(&book.Book{}).Order("id"), func(rows *sql.Rows) (interface{}, []interface{}, error) {
+		var b book.Book
+		if err := e.db.ScanRows(rows, &b); err != nil {
+			return nil, nil, err
+		}
+		return b, bookValues(b), nil
+	})
+}
+
+//ExportAuthors writes every author ordered by AuthorID
+func (e *Exporter) ExportAuthors(w io.Writer, format string) error {
+	return e.export(w, format, "Authors", authorColumns, e.db.Model(&author.Author{}).Order("author_id"), func(rows *sql.Rows) (interface{}, []interface{}, error) {
+		var a author.Author
+		if err := e.db.ScanRows(rows, &a); err != nil {
+			return nil, nil, err
+		}
+		return a, authorValues(a), nil
+	})
+}
+
+//WriteBooks writes books like ExportBooks, for stores that hold their books in memory
+func WriteBooks(w io.Writer, format string, books []book.Book) error {
+	if !ValidFormat(format) {
+		return ErrUnknownFormat
+	}
+	i := 0
+	return encode(w, format, "Books", bookColumns, func() (interface{}, []interface{}, bool, error) {
+		if i == len(books) {
+			return nil, nil, false, nil
+		}
+		i++
+		return books[i-1], bookValues(books[i-1]), true, nil
+	})
+}
+
+//WriteAuthors writes authors like ExportAuthors, for stores that hold their authors in memory
+func WriteAuthors(w io.Writer, format string, authors []author.Author) error {
+	if !ValidFormat(format) {
+		return ErrUnknownFormat
+	}
+	i := 0
+	return encode(w, format, "Authors", authorColumns, func() (interface{}, []interface{}, bool, error) {
+		if i == len(authors) {
+			return nil, nil, false, nil
+		}
+		i++
+		return authors[i-1], authorValues(authors[i-1]), true, nil
+	})
+}
+
+func bookValues(b book.Book) []interface{} {
+	return []interface{}{b.ID, b.Name, b.Page, b.Stock, b.Reserved, b.Cost, b.StockCode, b.ISBN, b.AuthorID, b.CreatedAt, b.UpdatedAt}
+}
+
+func authorValues(a author.Author) []interface{} {
+	return []interface{}{a.ID, a.AuthorID, a.AuthorName, a.CreatedAt, a.UpdatedAt}
+}
+
+//export reads the query row by row through a database cursor, so only the current row is held in memory.
+//Nothing is written to w before the query has started, a failing query leaves w untouched
+func (e *Exporter) export(w io.Writer, format, sheet string, columns []string, query *gorm.DB,
+	scan func(rows *sql.Rows) (record interface{}, values []interface{}, err error)) error {
+
+	if !ValidFormat(format) {
+		return ErrUnknownFormat
+	}
+	rows, err := query.Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return encode(w, format, sheet, columns, func() (interface{}, []interface{}, bool, error) {
+		if !rows.Next() {
+			return nil, nil, false, rows.Err()
+		}
+		record, values, err := scan(rows)
+		return record, values, true, err
+	})
+}
+
+//encode writes the header and then the records next returns until it has no more
+func encode(w io.Writer, format, sheet string, columns []string,
+	next func() (record interface{}, values []interface{}, ok bool, err error)) error {
+
+	enc := newEncoder(w, format, sheet)
+	if err := enc.header(columns); err != nil {
+		return err
+	}
+	for {
+		record, values, ok, err := next()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return enc.close()
+		}
+		if err := enc.row(record, values); err != nil {
+			return err
+		}
+	}
+}
+
+//encoder writes the records of one export, csv and xlsx use the column values and json and ndjson the records
+type encoder interface {
+	header(columns []string) error
+	row(record interface{}, values []interface{}) error
+	close() error
+}
+
+func newEncoder(w io.Writer, format, sheet string) encoder {
+	switch format {
+	case JSON:
+		return &jsonEncoder{w: w}
+	case NDJSON:
+		return &ndjsonEncoder{enc: json.NewEncoder(w)}
+	case XLSX:
+		return newXlsxEncoder(w, sheet)
+	}
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+//formatValue returns the text of a column value, times in RFC 3339
+func formatValue(v interface{}) string {
+	if t, ok := v.(time.Time); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprint(v)
+}
+
+type csvEncoder struct {
+	w *csv.Writer
+}
+
+func (c *csvEncoder) header(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvEncoder) row(_ interface{}, values []interface{}) error {
+	record := make([]string, len(values))
+	for i, v := range values {
+		record[i] = formatValue(v)
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvEncoder) close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+//jsonEncoder writes one json array, element by element
+type jsonEncoder struct {
+	w    io.Writer
+	rows int
+}
+
+func (j *jsonEncoder) header(_ []string) error {
+	_, err := io.WriteString(j.w, "[")
+	return err
+}
+
+func (j *jsonEncoder) row(record interface{}, _ []interface{}) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if j.rows > 0 {
+		if _, err := io.WriteString(j.w, ",\n"); err != nil {
+			return err
+		}
+	}
+	j.rows++
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *jsonEncoder) close() error {
+	_, err := io.WriteString(j.w, "]\n")
+	return err
+}
+
+type ndjsonEncoder struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonEncoder) header(_ []string) error {
+	return nil
+}
+
+func (n *ndjsonEncoder) row(record interface{}, _ []interface{}) error {
+	return n.enc.Encode(record)
+}
+
+func (n *ndjsonEncoder) close() error {
+	return nil
+}
@@ -0,0 +1,31 @@
+package exporter
+
+import (
+	"context"
+	"io"
+)
+
+//ExportStore writes the books and authors as export files, every call runs with the context of its request.
+//Exporter reads them from the database through Store
+type ExportStore interface {
+	ExportBooks(ctx context.Context, w io.Writer, format string) error
+	ExportAuthors(ctx context.Context, w io.Writer, format string) error
+}
+
+//Store returns the exporter as an ExportStore
+func (e *Exporter) Store() ExportStore {
+	return repoStore{exporter: e}
+}
+
+//repoStore runs every call of an ExportStore on the exporter with the context of the call
+type repoStore struct {
+	exporter *Exporter
+}
+
+func (s repoStore) ExportBooks(ctx context.Context, w io.Writer, format string) error {
+	return s.exporter.WithContext(ctx).ExportBooks(w, format)
+}
+
+func (s repoStore) ExportAuthors(ctx context.Context, w io.Writer, format string) error {
+	return s.exporter.WithContext(ctx).ExportAuthors(w, format)
+}
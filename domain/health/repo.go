@@ -0,0 +1,38 @@
+package health
+
+import (
+	"context"
+
+	"github.com/BatuhanSerin/postgresql/common/db/migrations"
+	"gorm.io/gorm"
+)
+
+//HealthRepository checks the database the server depends on
+type HealthRepository struct {
+	db *gorm.DB
+}
+
+//NewHealthRepository returns Health Repository
+func NewHealthRepository(db *gorm.DB) *HealthRepository {
+	return &HealthRepository{db: db}
+}
+
+//WithContext returns a repository whose queries run with ctx
+func (h *HealthRepository) WithContext(ctx context.Context) *HealthRepository {
+	return &HealthRepository{db: h.db.WithContext(ctx)}
+}
+
+//Ping checks that the database answers
+func (h *HealthRepository) Ping() error {
+	sqlDB, err := h.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(h.db.Statement.Context)
+}
+
+//MigrationVersion returns the newest applied migration without changing the schema, it fails with
+//migrations.ErrNotMigrated when the database was never migrated
+func (h *HealthRepository) MigrationVersion() (uint, error) {
+	return migrations.Applied(h.db)
+}
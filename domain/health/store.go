@@ -0,0 +1,28 @@
+package health
+
+import "context"
+
+//HealthStore checks the database the server depends on, every call runs with the context of its request.
+//HealthRepository checks the database through Store
+type HealthStore interface {
+	Ping(ctx context.Context) error
+	MigrationVersion(ctx context.Context) (uint, error)
+}
+
+//Store returns the repository as a HealthStore
+func (h *HealthRepository) Store() HealthStore {
+	return repoStore{repo: h}
+}
+
+//repoStore runs every call of a HealthStore on the repository with the context of the call
+type repoStore struct {
+	repo *HealthRepository
+}
+
+func (s repoStore) Ping(ctx context.Context) error {
+	return s.repo.WithContext(ctx).Ping()
+}
+
+func (s repoStore) MigrationVersion(ctx context.Context) (uint, error) {
+	return s.repo.WithContext(ctx).MigrationVersion()
+}
@@ -0,0 +1,54 @@
+package idempotency
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MaxKeyLength bounds the length of an Idempotency-Key
+const MaxKeyLength = 255
+
+// Record is the stored response of the first request sent with a key, Status is 0 while that request is in progress
+type Record struct {
+	Principal   string `gorm:"primaryKey"`
+	Key         string `gorm:"primaryKey"`
+	Fingerprint string
+	Status      int
+	Headers     Headers `gorm:"type:jsonb"`
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
+
+// TableName is idempotency_keys, the default would be records
+func (Record) TableName() string {
+	return "idempotency_keys"
+}
+
+// Headers are the response headers that are replayed with the body, stored as jsonb
+type Headers map[string]string
+
+// Value implements driver.Valuer
+func (h Headers) Value() (driver.Value, error) {
+	if h == nil {
+		return "{}", nil
+	}
+	data, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return string(data), nil
+}
+
+// Scan implements sql.Scanner
+func (h *Headers) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case []byte:
+		return json.Unmarshal(v, h)
+	case string:
+		return json.Unmarshal([]byte(v), h)
+	}
+	return fmt.Errorf("cannot scan %T into Headers", src)
+}
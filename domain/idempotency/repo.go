@@ -0,0 +1,95 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrKeyReused  = errors.New("Idempotency-Key has already been used for a different request")
+	ErrInProgress = errors.New("A request with this Idempotency-Key is still in progress")
+)
+
+//IdempotencyRepository is a struct for IdempotencyRepository
+type IdempotencyRepository struct {
+	db *gorm.DB
+}
+
+//NewIdempotencyRepository returns Idempotency Repository
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{db: db}
+}
+
+//WithContext returns a repository whose queries run with ctx
+func (i *IdempotencyRepository) WithContext(ctx context.Context) *IdempotencyRepository {
+	return &IdempotencyRepository{db: i.db.WithContext(ctx)}
+}
+
+//Begin claims key for a request with the given fingerprint. It returns nil when the request is new and has to run,
+//the stored record when it has already completed, ErrInProgress while it runs and ErrKeyReused for another request.
+//An expired key is claimed again, and so is a key whose request never completed, because the server stopped, once it
+//is older than abandonAfter. abandonAfter has to outlast every request or a retry runs a request that is still running
+func (i *IdempotencyRepository) Begin(principal, key, fingerprint string, ttl, abandonAfter time.Duration) (*Record, error) {
+	now := time.Now()
+	claim := Record{
+		Principal:   principal,
+		Key:         key,
+		Fingerprint: fingerprint,
+		Headers:     Headers{},
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	result := i.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "principal"}, {Name: "key"}},
+		DoUpdates: clause.AssignmentColumns([]string{"fingerprint", "status", "headers", "body", "created_at", "expires_at"}),
+		Where: clause.Where{Exprs: []clause.Expression{clause.Or(
+			clause.Lt{Column: "idempotency_keys.expires_at", Value: now},
+			clause.And(
+				clause.Eq{Column: "idempotency_keys.status", Value: 0},
+				clause.Lt{Column: "idempotency_keys.created_at", Value: now.Add(-abandonAfter)},
+			),
+		)}},
+	}).Create(&claim)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return nil, nil
+	}
+
+	var stored Record
+	if result := i.db.Where("principal = ? AND key = ?", principal, key).First(&stored); result.Error != nil {
+		return nil, result.Error
+	}
+	switch {
+	case stored.Fingerprint != fingerprint:
+		return nil, ErrKeyReused
+	case stored.Status == 0:
+		return nil, ErrInProgress
+	}
+	return &stored, nil
+}
+
+//Complete stores the response of the request that claimed key
+func (i *IdempotencyRepository) Complete(principal, key string, status int, headers Headers, body []byte) error {
+	return i.db.Model(&Record{}).Where("principal = ? AND key = ? AND status = 0", principal, key).
+		Updates(map[string]interface{}{"status": status, "headers": headers, "body": body}).Error
+}
+
+//Release gives up the claim of a request that failed, so that a retry runs it again
+func (i *IdempotencyRepository) Release(principal, key string) error {
+	return i.db.Where("principal = ? AND key = ? AND status = 0", principal, key).Delete(&Record{}).Error
+}
+
+//Purge deletes the keys that expired before now and returns how many were deleted
+func (i *IdempotencyRepository) Purge(now time.Time) (int, error) {
+	result := i.db.Where("expires_at < ?", now).Delete(&Record{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,41 @@
+package idempotency
+
+import (
+	"context"
+	"time"
+)
+
+//IdempotencyStore keeps the idempotency keys and their responses, every call runs with the context it is given.
+//IdempotencyRepository keeps them in the database through Store
+type IdempotencyStore interface {
+	Begin(ctx context.Context, principal, key, fingerprint string, ttl, abandonAfter time.Duration) (*Record, error)
+	Complete(ctx context.Context, principal, key string, status int, headers Headers, body []byte) error
+	Release(ctx context.Context, principal, key string) error
+	Purge(ctx context.Context, now time.Time) (int, error)
+}
+
+//Store returns the repository as an IdempotencyStore
+func (i *IdempotencyRepository) Store() IdempotencyStore {
+	return repoStore{repo: i}
+}
+
+//repoStore runs every call of an IdempotencyStore on the repository with the context of the call
+type repoStore struct {
+	repo *IdempotencyRepository
+}
+
+func (s repoStore) Begin(ctx context.Context, principal, key, fingerprint string, ttl, abandonAfter time.Duration) (*Record, error) {
+	return s.repo.WithContext(ctx).Begin(principal, key, fingerprint, ttl, abandonAfter)
+}
+
+func (s repoStore) Complete(ctx context.Context, principal, key string, status int, headers Headers, body []byte) error {
+	return s.repo.WithContext(ctx).Complete(principal, key, status, headers, body)
+}
+
+func (s repoStore) Release(ctx context.Context, principal, key string) error {
+	return s.repo.WithContext(ctx).Release(principal, key)
+}
+
+func (s repoStore) Purge(ctx context.Context, now time.Time) (int, error) {
+	return s.repo.WithContext(ctx).Purge(now)
+}
@@ -0,0 +1,151 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/BatuhanSerin/postgresql/domain/author"
+	"gorm.io/gorm"
+)
+
+//ImportAuthors imports authors keyed by AuthorID, replace fails for authors that still have books
+func (i *Importer) ImportAuthors(r io.Reader, options Options) (*Report, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	rows, err := ReadAuthors(r, options.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := options.NewReport(len(rows))
+	err = i.run(options, report, func(tx *gorm.DB) error {
+		seen := map[uint]bool{}
+
+		for _, row := range rows {
+			key := strconv.FormatUint(uint64(row.Author.AuthorID), 10)
+			if row.Fields != nil {
+				report.Errors = append(report.Errors, RowError{Row: row.Row, Key: key, Fields: row.Fields})
+				continue
+			}
+			seen[row.Author.AuthorID] = true
+
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				return importAuthor(tx, row.Author, options, report)
+			})
+			if err != nil {
+				report.Errors = append(report.Errors, RowError{Row: row.Row, Key: key, Error: err.Error()})
+			}
+		}
+
+		if options.Mode != Replace || len(report.Errors) > 0 {
+			return nil
+		}
+		var stale []author.Author
+		if result := tx.Find(&stale); result.Error != nil {
+			return result.Error
+		}
+		authors := author.NewAuthorRepository(tx)
+		for _, a := range stale {
+			if seen[a.AuthorID] {
+				continue
+			}
+			if err := authors.Delete(a.AuthorID, author.DeleteOptions{}); err != nil {
+				report.Errors = append(report.Errors, RowError{Key: strconv.FormatUint(uint64(a.AuthorID), 10), Error: err.Error()})
+				continue
+			}
+			report.Deleted++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrRowsRejected) {
+		return nil, err
+	}
+	return report, err
+}
+
+func importAuthor(tx *gorm.DB, a author.Author, options Options, report *Report) error {
+	authors := author.NewAuthorRepository(tx)
+	var existing author.Author
+	result := tx.Unscoped().Where("author_id = ?", a.AuthorID).First(&existing)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		if err := authors.Create(&a); err != nil {
+			return err
+		}
+		report.Created++
+		return nil
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if options.Mode == Insert || (!existing.DeletedAt.Valid && existing.AuthorName == a.AuthorName) {
+		report.Skipped++
+		return nil
+	}
+	a.Model = existing.Model
+	a.DeletedAt = gorm.DeletedAt{}
+	a.Version = existing.Version
+	if err := authors.Update(&a); err != nil {
+		return err
+	}
+	report.Updated++
+	return nil
+}
+
+//AuthorRow is an author read from a row of an import file, Fields are the reasons the row is rejected
+type AuthorRow struct {
+	Row    int
+	Author author.Author
+	Fields map[string]string
+}
+
+//ReadAuthors reads the authors of an import file and validates them, a row repeating the AuthorID of an earlier row
+//is rejected
+func ReadAuthors(r io.Reader, format string) ([]AuthorRow, error) {
+	rows, err := readRows(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	authors := make([]AuthorRow, len(rows))
+	seen := map[uint]int{}
+	for i, row := range rows {
+		a, fields := authorFromRow(row)
+		if fields == nil {
+			fields = a.Validate()
+		}
+		if previous, ok := seen[a.AuthorID]; ok && fields == nil {
+			fields = map[string]string{"AuthorID": "is repeated, first seen in row " + strconv.Itoa(previous)}
+		}
+		if fields == nil {
+			seen[a.AuthorID] = row.Number
+		}
+		authors[i] = AuthorRow{Row: row.Number, Author: a, Fields: fields}
+	}
+	return authors, nil
+}
+
+//authorFromRow reads an author from the columns AuthorID and AuthorName
+func authorFromRow(r row) (author.Author, map[string]string) {
+	var a author.Author
+
+	if r.Values == nil {
+		if err := decodeJSON(r.JSON, &a); err != nil {
+			return a, map[string]string{"row": err.Error()}
+		}
+		a.Model = gorm.Model{}
+		a.Version = 0
+		a.Books = nil
+		return a, nil
+	}
+
+	a.AuthorName = r.Values["authorname"]
+	authorID, err := strconv.ParseUint(r.Values["authorid"], 10, 64)
+	if err != nil {
+		return a, map[string]string{"AuthorID": "must be a positive integer"}
+	}
+	a.AuthorID = uint(authorID)
+	return a, nil
+}
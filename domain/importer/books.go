@@ -0,0 +1,198 @@
+package importer
+
+import (
+	"errors"
+	"io"
+	"strconv"
+
+	"github.com/BatuhanSerin/postgresql/common/money"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"gorm.io/gorm"
+)
+
+//ImportBooks imports books keyed by ISBN, a changed stock is recorded as an adjustment in the stock ledger
+func (i *Importer) ImportBooks(r io.Reader, options Options) (*Report, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	rows, err := ReadBooks(r, options.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := options.NewReport(len(rows))
+	err = i.run(options, report, func(tx *gorm.DB) error {
+		seen := map[string]bool{}
+
+		for _, row := range rows {
+			if row.Fields != nil {
+				report.Errors = append(report.Errors, RowError{Row: row.Row, Key: row.Book.ISBN, Fields: row.Fields})
+				continue
+			}
+			seen[row.Book.ISBN] = true
+
+			err := tx.Transaction(func(tx *gorm.DB) error {
+				return importBook(tx, row.Book, options, report)
+			})
+			if err != nil {
+				report.Errors = append(report.Errors, RowError{Row: row.Row, Key: row.Book.ISBN, Error: err.Error()})
+			}
+		}
+
+		if options.Mode != Replace || len(report.Errors) > 0 {
+			return nil
+		}
+		var stale []book.Book
+		if result := tx.Find(&stale); result.Error != nil {
+			return result.Error
+		}
+		books := book.NewBookRepository(tx)
+		for i := range stale {
+			if seen[stale[i].ISBN] {
+				continue
+			}
+			if err := books.Delete(&stale[i]); err != nil {
+				return err
+			}
+			report.Deleted++
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, ErrRowsRejected) {
+		return nil, err
+	}
+	return report, err
+}
+
+func importBook(tx *gorm.DB, b book.Book, options Options, report *Report) error {
+	books := book.NewBookRepository(tx)
+	var existing book.Book
+	result := tx.Unscoped().Where("isbn = ?", b.ISBN).First(&existing)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		if err := books.Create(&b); err != nil {
+			return err
+		}
+		report.Created++
+		return nil
+	}
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if options.Mode == Insert {
+		report.Skipped++
+		return nil
+	}
+
+	stock := b.Stock
+	if SameBook(b, existing) && stock == existing.Stock {
+		report.Skipped++
+		return nil
+	}
+	b.Model = existing.Model
+	b.DeletedAt = gorm.DeletedAt{}
+	b.Version = existing.Version
+	b.Stock = existing.Stock
+	b.Reserved = existing.Reserved
+	if err := books.Update(&b); err != nil {
+		return err
+	}
+	if stock != existing.Stock {
+		err := books.RecordMovement(&book.StockMovement{
+			BookID:   existing.ID,
+			Kind:     book.Adjustment,
+			Quantity: stock - existing.Stock,
+			Reason:   "import",
+			User:     options.User,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	report.Updated++
+	return nil
+}
+
+//BookRow is a book read from a row of an import file, Fields are the reasons the row is rejected
+type BookRow struct {
+	Row    int
+	Book   book.Book
+	Fields map[string]string
+}
+
+//ReadBooks reads the books of an import file and validates them, a row repeating the ISBN of an earlier row is rejected
+func ReadBooks(r io.Reader, format string) ([]BookRow, error) {
+	rows, err := readRows(r, format)
+	if err != nil {
+		return nil, err
+	}
+
+	books := make([]BookRow, len(rows))
+	seen := map[string]int{}
+	for i, row := range rows {
+		b, fields := bookFromRow(row)
+		if fields == nil {
+			fields = b.Validate()
+		}
+		if previous, ok := seen[b.ISBN]; ok && fields == nil {
+			fields = map[string]string{"ISBN": "is repeated, first seen in row " + strconv.Itoa(previous)}
+		}
+		if fields == nil {
+			seen[b.ISBN] = row.Number
+		}
+		books[i] = BookRow{Row: row.Number, Book: b, Fields: fields}
+	}
+	return books, nil
+}
+
+//SameBook reports whether an import row leaves a live book unchanged, stock aside
+func SameBook(b, existing book.Book) bool {
+	return !existing.DeletedAt.Valid &&
+		b.Name == existing.Name &&
+		b.Page == existing.Page &&
+		b.Cost == existing.Cost &&
+		b.StockCode == existing.StockCode &&
+		b.AuthorID == existing.AuthorID
+}
+
+//bookFromRow reads a book from the columns Name, Page, Stock, Cost, StockCode, ISBN and AuthorID,
+//an ID column is ignored because books are matched by ISBN
+func bookFromRow(r row) (book.Book, map[string]string) {
+	var b book.Book
+	fields := map[string]string{}
+
+	if r.Values == nil {
+		if err := decodeJSON(r.JSON, &b); err != nil {
+			return b, map[string]string{"row": err.Error()}
+		}
+		b.Model = gorm.Model{}
+		b.Version = 0
+		b.Reserved = 0
+		return b, nil
+	}
+
+	v := r.Values
+	b.Name = v["name"]
+	b.StockCode = v["stockcode"]
+	b.ISBN = v["isbn"]
+	var err error
+	if b.Page, err = strconv.Atoi(v["page"]); err != nil {
+		fields["Page"] = "must be an integer"
+	}
+	if b.Stock, err = strconv.Atoi(v["stock"]); err != nil {
+		fields["Stock"] = "must be an integer"
+	}
+	if b.Cost, err = money.Parse(v["cost"]); err != nil {
+		fields["Cost"] = "must be a decimal amount"
+	}
+	authorID, err := strconv.ParseUint(v["authorid"], 10, 64)
+	if err != nil {
+		fields["AuthorID"] = "must be a positive integer"
+	}
+	b.AuthorID = uint(authorID)
+
+	if len(fields) > 0 {
+		return b, fields
+	}
+	return b, nil
+}
@@ -0,0 +1,216 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/BatuhanSerin/postgresql/domain/audit"
+	"gorm.io/gorm"
+)
+
+// Formats of an import file
+const (
+	CSV    = "csv"
+	JSON   = "json"
+	NDJSON = "ndjson"
+)
+
+// Modes of an import
+const (
+	// Insert creates new rows and skips rows that already exist
+	Insert = "insert"
+	// Upsert creates new rows and updates existing ones
+	Upsert = "upsert"
+	// Replace upserts every row and deletes the rows that are not in the file
+	Replace = "replace"
+)
+
+var (
+	ErrUnknownFormat = errors.New("Unknown import format, use csv, json or ndjson")
+	ErrUnknownMode   = errors.New("Unknown import mode, use insert, upsert or replace")
+	ErrRowsRejected  = errors.New("Some rows were rejected, nothing was imported")
+	ErrUnreadable    = errors.New("Import file cannot be read")
+
+	// errDryRun rolls back the transaction of a dry run
+	errDryRun = errors.New("dry run")
+)
+
+//RowError reports why a row of the file was rejected, Row is 0 for rows that are not in the file
+type RowError struct {
+	Row    int
+	Key    string            `json:",omitempty"`
+	Error  string            `json:",omitempty"`
+	Fields map[string]string `json:",omitempty"`
+}
+
+//Report summarizes an import
+type Report struct {
+	Format  string
+	Mode    string
+	DryRun  bool
+	Rows    int
+	Created int
+	Updated int
+	Skipped int
+	Deleted int
+	Errors  []RowError
+}
+
+//Options tells an import how to read and apply the file
+type Options struct {
+	Format string
+	Mode   string
+	DryRun bool
+	// User is recorded on the stock movements of the import and, without an actor in the context, in the audit trail
+	User string
+}
+
+//Importer is a struct for Importer
+type Importer struct {
+	db *gorm.DB
+}
+
+//NewImporter returns Importer
+func NewImporter(db *gorm.DB) *Importer {
+	return &Importer{db: db}
+}
+
+//WithContext returns an importer whose queries run with ctx, the actor of ctx is recorded in the audit trail
+func (i *Importer) WithContext(ctx context.Context) *Importer {
+	return &Importer{db: i.db.WithContext(ctx)}
+}
+
+//row is a record of the import file, Values for csv and JSON for json and ndjson
+type row struct {
+	Number int
+	Values map[string]string
+	JSON   json.RawMessage
+}
+
+//Validate checks the format and the mode
+func (o Options) Validate() error {
+	switch o.Format {
+	case CSV, JSON, NDJSON:
+	default:
+		return ErrUnknownFormat
+	}
+	switch o.Mode {
+	case Insert, Upsert, Replace:
+	default:
+		return ErrUnknownMode
+	}
+	return nil
+}
+
+//NewReport returns the empty report of an import of rows rows with options
+func (o Options) NewReport(rows int) *Report {
+	return &Report{Format: o.Format, Mode: o.Mode, DryRun: o.DryRun, Rows: rows, Errors: []RowError{}}
+}
+
+//run applies every row in one transaction, each row in its own savepoint so that a failing row
+//does not abort the others; the transaction is rolled back on a dry run or when any row failed
+func (i *Importer) run(options Options, report *Report, apply func(tx *gorm.DB) error) error {
+	db := i.db
+	if ctx := db.Statement.Context; audit.ActorFrom(ctx) == "" && options.User != "" {
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		db = db.WithContext(audit.WithActor(ctx, options.User))
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := apply(tx); err != nil {
+			return err
+		}
+		if len(report.Errors) > 0 {
+			return ErrRowsRejected
+		}
+		if options.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if errors.Is(err, errDryRun) {
+		return nil
+	}
+	return err
+}
+
+//readRows reads the records of the file, csv files need a header row
+func readRows(r io.Reader, format string) ([]row, error) {
+	rows, err := parseRows(r, format)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnreadable, err)
+	}
+	return rows, nil
+}
+
+func parseRows(r io.Reader, format string) ([]row, error) {
+	switch format {
+	case CSV:
+		reader := csv.NewReader(r)
+		reader.TrimLeadingSpace = true
+		records, err := reader.ReadAll()
+		if err != nil {
+			return nil, err
+		}
+		if len(records) == 0 {
+			return nil, nil
+		}
+		header := records[0]
+		for i := range header {
+			header[i] = strings.ToLower(strings.TrimSpace(header[i]))
+		}
+
+		var rows []row
+		for i, record := range records[1:] {
+			values := map[string]string{}
+			for j, value := range record {
+				if j < len(header) {
+					values[header[j]] = strings.TrimSpace(value)
+				}
+			}
+			rows = append(rows, row{Number: i + 2, Values: values})
+		}
+		return rows, nil
+
+	case JSON:
+		var objects []json.RawMessage
+		if err := json.NewDecoder(r).Decode(&objects); err != nil {
+			return nil, fmt.Errorf("json import must be an array of objects: %w", err)
+		}
+		rows := make([]row, len(objects))
+		for i, object := range objects {
+			rows[i] = row{Number: i + 1, JSON: object}
+		}
+		return rows, nil
+
+	case NDJSON:
+		var rows []row
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			data := bytes.TrimSpace(scanner.Bytes())
+			if len(data) == 0 {
+				continue
+			}
+			rows = append(rows, row{Number: line, JSON: append(json.RawMessage{}, data...)})
+		}
+		return rows, scanner.Err()
+	}
+	return nil, ErrUnknownFormat
+}
+
+//decodeJSON decodes a json row and rejects unknown fields
+func decodeJSON(data json.RawMessage, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
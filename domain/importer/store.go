@@ -0,0 +1,31 @@
+package importer
+
+import (
+	"context"
+	"io"
+)
+
+//ImportStore imports books and authors from files, every call runs with the context of its request.
+//Importer writes them to the database through Store
+type ImportStore interface {
+	ImportBooks(ctx context.Context, r io.Reader, options Options) (*Report, error)
+	ImportAuthors(ctx context.Context, r io.Reader, options Options) (*Report, error)
+}
+
+//Store returns the importer as an ImportStore
+func (i *Importer) Store() ImportStore {
+	return repoStore{importer: i}
+}
+
+//repoStore runs every call of an ImportStore on the importer with the context of the call
+type repoStore struct {
+	importer *Importer
+}
+
+func (s repoStore) ImportBooks(ctx context.Context, r io.Reader, options Options) (*Report, error) {
+	return s.importer.WithContext(ctx).ImportBooks(r, options)
+}
+
+func (s repoStore) ImportAuthors(ctx context.Context, r io.Reader, options Options) (*Report, error) {
+	return s.importer.WithContext(ctx).ImportAuthors(r, options)
+}
@@ -0,0 +1,155 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/apikey"
+	"github.com/BatuhanSerin/postgresql/domain/auth"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+	"gorm.io/gorm"
+)
+
+//Accounts keeps users, their refresh tokens and api keys in memory, it is safe for concurrent use.
+//Tokens are signed and checked with the auth configuration like the database stores do, passwords are bcrypt hashed
+//and only the hashes of api keys are kept
+type Accounts struct {
+	mu      sync.Mutex
+	config  auth.Config
+	users   map[uint]user.User
+	refresh map[string]auth.RefreshToken
+	keys    map[uint]apikey.APIKey
+	lastID  struct{ user, key uint }
+}
+
+//NewAccounts returns accounts without users whose tokens are signed with config
+func NewAccounts(config auth.Config) *Accounts {
+	return &Accounts{
+		config:  config,
+		users:   map[uint]user.User{},
+		refresh: map[string]auth.RefreshToken{},
+		keys:    map[uint]apikey.APIKey{},
+	}
+}
+
+//Users returns the accounts as a UserStore
+func (a *Accounts) Users() user.UserStore {
+	return userStore{a}
+}
+
+//Auth returns the accounts as an AuthStore
+func (a *Accounts) Auth() auth.AuthStore {
+	return authStore{a}
+}
+
+//APIKeys returns the accounts as an APIKeyStore
+func (a *Accounts) APIKeys() apikey.APIKeyStore {
+	return apiKeyStore{a}
+}
+
+//lock locks the accounts unless ctx is done already
+func (a *Accounts) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	return nil
+}
+
+//userStore serves the users of the accounts
+type userStore struct {
+	*Accounts
+}
+
+func (s userStore) Register(ctx context.Context, r user.Registration) (*user.User, error) {
+	u, err := user.NewAccount(r)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	if _, ok := s.userByName(u.Username); ok {
+		return nil, user.ErrUsernameTaken
+	}
+	s.lastID.user++
+	u.ID = s.lastID.user
+	u.CreatedAt = time.Now()
+	u.UpdatedAt = u.CreatedAt
+	s.users[u.ID] = *u
+	return u, nil
+}
+
+func (s userStore) GetByID(ctx context.Context, id uint) (*user.User, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &u, nil
+}
+
+func (s userStore) FindAll(ctx context.Context) ([]user.User, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	users := []user.User{}
+	for _, u := range s.users {
+		users = append(users, u)
+	}
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (s userStore) ChangeRole(ctx context.Context, id uint, role string) (*user.User, error) {
+	if !user.ValidRole(role) {
+		return nil, user.ErrUnknownRole
+	}
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	u, ok := s.users[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if u.Role == user.Admin && role != user.Admin {
+		admins := 0
+		for _, other := range s.users {
+			if other.Role == user.Admin {
+				admins++
+			}
+		}
+		if admins <= 1 {
+			return nil, user.ErrLastAdmin
+		}
+	}
+	u.Role = role
+	u.UpdatedAt = time.Now()
+	s.users[id] = u
+	return &u, nil
+}
+
+//userByName returns the user with a username
+func (a *Accounts) userByName(username string) (user.User, bool) {
+	username = user.NormalizeUsername(username)
+	for _, u := range a.users {
+		if u.Username == username {
+			return u, true
+		}
+	}
+	return user.User{}, false
+}
+
+var _ user.UserStore = userStore{}
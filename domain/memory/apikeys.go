@@ -0,0 +1,122 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/apikey"
+	"gorm.io/gorm"
+)
+
+//apiKeyStore serves the api keys of the accounts
+type apiKeyStore struct {
+	*Accounts
+}
+
+func (s apiKeyStore) Create(ctx context.Context, k apikey.NewKey, createdBy string) (*apikey.CreatedKey, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	secret, prefix, hash := apikey.NewSecret()
+	now := time.Now()
+	s.lastID.key++
+	key := apikey.APIKey{
+		ID:        s.lastID.key,
+		Name:      strings.TrimSpace(k.Name),
+		Prefix:    prefix,
+		KeyHash:   hash,
+		Scopes:    k.Scopes,
+		ExpiresAt: now.Add(apikey.DefaultTTL),
+		CreatedBy: createdBy,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if k.ExpiresAt != nil {
+		key.ExpiresAt = *k.ExpiresAt
+	}
+	s.keys[key.ID] = key
+	return &apikey.CreatedKey{APIKey: key, Key: secret}, nil
+}
+
+func (s apiKeyStore) FindAll(ctx context.Context) ([]apikey.APIKey, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	keys := []apikey.APIKey{}
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys, nil
+}
+
+//Rotate replaces the secret of a key, the old secret stops working at once
+func (s apiKeyStore) Rotate(ctx context.Context, id uint) (*apikey.CreatedKey, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if key.RevokedAt != nil {
+		return nil, apikey.ErrRevoked
+	}
+	secret, prefix, hash := apikey.NewSecret()
+	key.Prefix = prefix
+	key.KeyHash = hash
+	key.UpdatedAt = time.Now()
+	s.keys[id] = key
+	return &apikey.CreatedKey{APIKey: key, Key: secret}, nil
+}
+
+func (s apiKeyStore) Revoke(ctx context.Context, id uint) (*apikey.APIKey, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+		s.keys[id] = key
+	}
+	return &key, nil
+}
+
+//Authenticate returns the active key of secret and records its use
+func (s apiKeyStore) Authenticate(ctx context.Context, secret string) (*apikey.APIKey, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	hash := apikey.HashSecret(secret)
+	for id, key := range s.keys {
+		if key.KeyHash != hash {
+			continue
+		}
+		now := time.Now()
+		if !key.Active(now) {
+			return nil, apikey.ErrInvalidKey
+		}
+		key.LastUsedAt = &now
+		s.keys[id] = key
+		return &key, nil
+	}
+	return nil, apikey.ErrInvalidKey
+}
+
+var _ apikey.APIKeyStore = apiKeyStore{}
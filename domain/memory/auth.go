@@ -0,0 +1,115 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/auth"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+)
+
+//authStore issues the tokens of the users of the accounts, each refresh token is used once like with AuthRepository
+type authStore struct {
+	*Accounts
+}
+
+func (s authStore) Login(ctx context.Context, c auth.Credentials) (*auth.Tokens, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	u, ok := s.userByName(c.Username)
+	if !ok {
+		return nil, user.CheckPassword(nil, c.Password)
+	}
+	if err := user.CheckPassword(&u, c.Password); err != nil {
+		return nil, err
+	}
+	return s.issue(u)
+}
+
+//Refresh trades a refresh token for new tokens, presenting a refresh token again revokes every refresh token of its
+//subject
+func (s authStore) Refresh(ctx context.Context, token string) (*auth.Tokens, error) {
+	claims, err := s.Parse(token, auth.Refresh)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	stored, ok := s.refresh[claims.ID]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown refresh token", auth.ErrInvalidClaims)
+	}
+	if stored.RevokedAt != nil {
+		now := time.Now()
+		for id, t := range s.refresh {
+			if t.Subject == stored.Subject && t.RevokedAt == nil {
+				t.RevokedAt = &now
+				s.refresh[id] = t
+			}
+		}
+		return nil, fmt.Errorf("%w: refresh token was already used", auth.ErrInvalidClaims)
+	}
+
+	// the role is read again so that a role change takes effect on the next refresh
+	id, err := strconv.ParseUint(stored.Subject, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", auth.ErrInvalidClaims, err)
+	}
+	u, ok := s.users[uint(id)]
+	if !ok {
+		return nil, fmt.Errorf("%w: user no longer exists", auth.ErrInvalidClaims)
+	}
+
+	now := time.Now()
+	stored.RevokedAt = &now
+	s.refresh[stored.ID] = stored
+	return s.issue(u)
+}
+
+func (s authStore) Logout(ctx context.Context, token string) error {
+	claims, err := s.Parse(token, auth.Refresh)
+	if err != nil {
+		return err
+	}
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	if stored, ok := s.refresh[claims.ID]; ok && stored.RevokedAt == nil {
+		now := time.Now()
+		stored.RevokedAt = &now
+		s.refresh[stored.ID] = stored
+	}
+	return nil
+}
+
+func (s authStore) Parse(token, tokenType string) (*auth.Claims, error) {
+	return s.config.Parse(token, tokenType)
+}
+
+//issue signs an access and a refresh token for u and records the refresh token, the caller holds the lock
+func (s authStore) issue(u user.User) (*auth.Tokens, error) {
+	now := time.Now()
+	tokens, refresh, err := s.config.Sign(strconv.FormatUint(uint64(u.ID), 10), u.Role, now)
+	if err != nil {
+		return nil, err
+	}
+	s.refresh[refresh.ID] = auth.RefreshToken{
+		ID:        refresh.ID,
+		Subject:   refresh.Subject,
+		ExpiresAt: refresh.ExpiresAt.Time,
+		CreatedAt: now,
+	}
+	return tokens, nil
+}
+
+var _ auth.AuthStore = authStore{}
@@ -0,0 +1,168 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/author"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"gorm.io/gorm"
+)
+
+//authorStore is the AuthorStore of a catalog
+type authorStore struct {
+	*Catalog
+}
+
+func (s authorStore) GetAllAuthorsWithBookInformation(ctx context.Context) ([]author.Author, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	authors := []author.Author{}
+	for _, a := range s.authors {
+		if !a.DeletedAt.Valid {
+			a.Books = s.booksOf(a.AuthorID)
+			authors = append(authors, a)
+		}
+	}
+	sort.Slice(authors, func(i, j int) bool { return authors[i].ID < authors[j].ID })
+	return authors, nil
+}
+
+//GetAuthorWithName matches the name the way AuthorRepository.GetAuthorWithName does, the first author by id wins
+func (s authorStore) GetAuthorWithName(ctx context.Context, name string) (*author.Author, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	name = strings.Title(strings.ToLower(name))
+	var found *author.Author
+	for _, a := range s.authors {
+		if a.AuthorName == name && !a.DeletedAt.Valid && (found == nil || a.ID < found.ID) {
+			a := a
+			found = &a
+		}
+	}
+	if found == nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	found.Books = s.booksOf(found.AuthorID)
+	return found, nil
+}
+
+func (s authorStore) GetByAuthorID(ctx context.Context, authorID uint) (*author.Author, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	a, ok := s.authorByAuthorID(authorID, false)
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	a.Books = s.booksOf(a.AuthorID)
+	return &a, nil
+}
+
+func (s authorStore) Create(ctx context.Context, a *author.Author) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	if _, ok := s.authorByAuthorID(a.AuthorID, true); ok {
+		return author.ErrAuthorExists
+	}
+
+	s.lastID.author++
+	a.ID = s.lastID.author
+	a.CreatedAt = time.Now()
+	a.UpdatedAt = a.CreatedAt
+	a.Version = 1
+	created := *a
+	created.Books = nil
+	s.authors[created.ID] = created
+	return nil
+}
+
+func (s authorStore) Update(ctx context.Context, a *author.Author) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	before, ok := s.authors[a.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if before.Version != a.Version {
+		return author.ErrVersionConflict
+	}
+	if other, ok := s.authorByAuthorID(a.AuthorID, true); ok && other.ID != a.ID {
+		return uniqueViolation("authors", "idx_authors_author_id", "author_id", a.AuthorID)
+	}
+
+	saved := *a
+	saved.Books = nil
+	saved.CreatedAt = before.CreatedAt
+	saved.Version++
+	saved.UpdatedAt = time.Now()
+	s.authors[saved.ID] = saved
+
+	a.Version = saved.Version
+	a.UpdatedAt = saved.UpdatedAt
+	return nil
+}
+
+func (s authorStore) Delete(ctx context.Context, authorID uint, options author.DeleteOptions) error {
+	if options.Cascade && options.ReassignTo != 0 {
+		return author.ErrConflictingOptions
+	}
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	a, ok := s.authorByAuthorID(authorID, false)
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+
+	books := s.booksOf(authorID)
+	if len(books) > 0 {
+		switch {
+		case options.Cascade:
+			now := time.Now()
+			for _, b := range books {
+				b.DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+				s.books[b.ID] = b
+			}
+		case options.ReassignTo != 0:
+			target, ok := s.authorByAuthorID(options.ReassignTo, false)
+			if !ok || target.AuthorID == authorID {
+				return author.ErrReassignTargetNotFound
+			}
+			for _, b := range books {
+				b.AuthorID = target.AuthorID
+				touch(&b)
+				s.books[b.ID] = b
+			}
+		default:
+			return author.ErrAuthorHasBooks
+		}
+	}
+
+	a.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.authors[a.ID] = a
+	return nil
+}
+
+var (
+	_ book.BookStore     = bookStore{}
+	_ author.AuthorStore = authorStore{}
+)
@@ -0,0 +1,408 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"gorm.io/gorm"
+)
+
+//bookStore is the BookStore of a catalog
+type bookStore struct {
+	*Catalog
+}
+
+func (s bookStore) List(ctx context.Context, q book.ListQuery) (*book.ListResult, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	books := []book.Book{}
+	for _, b := range s.books {
+		if !b.DeletedAt.Valid {
+			books = append(books, b)
+		}
+	}
+	return book.Page(books, q)
+}
+
+func (s bookStore) GetByID(ctx context.Context, id uint) (*book.Book, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	b, err := s.liveBook(id)
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (s bookStore) FindByAuthorOrBookId(ctx context.Context, id uint) ([]book.Book, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	books := []book.Book{}
+	for _, b := range s.books {
+		if (b.ID == id || b.AuthorID == id) && !b.DeletedAt.Valid {
+			books = append(books, b)
+		}
+	}
+	sortBooks(books)
+	return books, nil
+}
+
+//FindByName matches names whatever their case like BookRepository.FindByName does
+func (s bookStore) FindByName(ctx context.Context, name string) ([]book.Book, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	name = strings.ToLower(name)
+	books := []book.Book{}
+	for _, b := range s.books {
+		if strings.Contains(strings.ToLower(b.Name), name) && !b.DeletedAt.Valid {
+			books = append(books, b)
+		}
+	}
+	sortBooks(books)
+	return books, nil
+}
+
+func (s bookStore) Create(ctx context.Context, b *book.Book) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	created := *b
+	if created.ID == 0 {
+		created.ID = s.lastID.book + 1
+	}
+	if _, ok := s.books[created.ID]; ok {
+		return uniqueViolation("books", "books_pkey", "id", created.ID)
+	}
+	if err := s.checkBook(created); err != nil {
+		return err
+	}
+	if created.ID > s.lastID.book {
+		s.lastID.book = created.ID
+	}
+
+	now := time.Now()
+	created.CreatedAt, created.UpdatedAt = now, now
+	created.Stock, created.Reserved, created.Version = 0, 0, 1
+	s.books[created.ID] = created
+	if b.Stock != 0 {
+		err := s.moveStock(&book.StockMovement{
+			BookID:   created.ID,
+			Kind:     book.Receipt,
+			Quantity: b.Stock,
+			Reason:   "initial stock",
+			User:     "system",
+		})
+		if err != nil {
+			delete(s.books, created.ID)
+			return err
+		}
+	}
+	*b = s.books[created.ID]
+	return nil
+}
+
+func (s bookStore) Update(ctx context.Context, b *book.Book) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	before, ok := s.books[b.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if before.Version != b.Version {
+		return book.ErrVersionConflict
+	}
+	if err := s.checkBook(*b); err != nil {
+		return err
+	}
+
+	saved := *b
+	saved.Stock = before.Stock
+	saved.Reserved = before.Reserved
+	saved.CreatedAt = before.CreatedAt
+	touch(&saved)
+	s.books[saved.ID] = saved
+
+	b.Version = saved.Version
+	b.UpdatedAt = saved.UpdatedAt
+	return nil
+}
+
+func (s bookStore) Delete(ctx context.Context, id uint) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	b, ok := s.books[id]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if b.DeletedAt.Valid {
+		return book.ErrAlreadyDeleted
+	}
+	b.DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
+	s.books[id] = b
+	return nil
+}
+
+func (s bookStore) Trash(ctx context.Context) ([]book.Book, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	books := []book.Book{}
+	for _, b := range s.books {
+		if b.DeletedAt.Valid {
+			books = append(books, b)
+		}
+	}
+	sort.Slice(books, func(i, j int) bool {
+		if !books[i].DeletedAt.Time.Equal(books[j].DeletedAt.Time) {
+			return books[i].DeletedAt.Time.After(books[j].DeletedAt.Time)
+		}
+		return books[i].ID < books[j].ID
+	})
+	return books, nil
+}
+
+func (s bookStore) Restore(ctx context.Context, id uint) (*book.Book, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	b, ok := s.books[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if !b.DeletedAt.Valid {
+		return nil, book.ErrNotDeleted
+	}
+	if _, ok := s.authorByAuthorID(b.AuthorID, false); !ok {
+		return nil, book.ErrAuthorDeleted
+	}
+
+	b.DeletedAt = gorm.DeletedAt{}
+	touch(&b)
+	s.books[id] = b
+	return &b, nil
+}
+
+func (s bookStore) HardDelete(ctx context.Context, id uint) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	if _, ok := s.books[id]; !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if s.onOrder(id) {
+		return book.ErrBookHasOrders
+	}
+	s.hardDelete(id)
+	return nil
+}
+
+func (s bookStore) Purge(ctx context.Context, cutoff time.Time) (int, error) {
+	if err := s.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer s.mu.Unlock()
+
+	purged := 0
+	for id, b := range s.books {
+		if b.DeletedAt.Valid && b.DeletedAt.Time.Before(cutoff) && !s.onOrder(id) {
+			s.hardDelete(id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+//onOrder reports whether a book is an item of any order, such a book is kept
+func (s bookStore) onOrder(id uint) bool {
+	for _, o := range s.orders {
+		for _, item := range o.Items {
+			if item.BookID == id {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//hardDelete removes a book with its stock movements and reservations
+func (s bookStore) hardDelete(id uint) {
+	movements := s.movements[:0]
+	for _, m := range s.movements {
+		if m.BookID != id {
+			movements = append(movements, m)
+		}
+	}
+	s.movements = movements
+	for reservationID, r := range s.reservations {
+		if r.BookID == id {
+			delete(s.reservations, reservationID)
+		}
+	}
+	delete(s.books, id)
+}
+
+func (s bookStore) RecordMovement(ctx context.Context, m *book.StockMovement) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	return s.moveStock(m)
+}
+
+func (s bookStore) Movements(ctx context.Context, bookID uint) ([]book.StockMovement, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	if _, err := s.liveBook(bookID); err != nil {
+		return nil, err
+	}
+	movements := []book.StockMovement{}
+	for i := len(s.movements) - 1; i >= 0; i-- {
+		if s.movements[i].BookID == bookID {
+			movements = append(movements, s.movements[i])
+		}
+	}
+	return movements, nil
+}
+
+func (s bookStore) Reserve(ctx context.Context, r *book.StockReservation) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	defer s.mu.Unlock()
+
+	b, err := s.liveBook(r.BookID)
+	if err != nil {
+		return err
+	}
+	if available := b.Stock - b.Reserved; r.Quantity > available {
+		return fmt.Errorf("%w: %d available", book.ErrInsufficientStock, available)
+	}
+
+	s.lastID.reservation++
+	r.ID = s.lastID.reservation
+	r.Status = book.Held
+	r.CreatedAt = time.Now()
+	r.UpdatedAt = r.CreatedAt
+	s.reservations[r.ID] = *r
+
+	b.Reserved += r.Quantity
+	touch(&b)
+	s.books[b.ID] = b
+	return nil
+}
+
+func (s bookStore) GetReservation(ctx context.Context, id uint) (*book.StockReservation, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	r, ok := s.reservations[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &r, nil
+}
+
+func (s bookStore) CommitReservation(ctx context.Context, id uint, user string) (*book.StockReservation, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	r, b, err := s.heldReservation(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// the reserved quantity leaves the reservation before the sale is recorded, so the sale may use it
+	b.Reserved -= r.Quantity
+	touch(&b)
+	s.books[b.ID] = b
+	err = s.moveStock(&book.StockMovement{
+		BookID:    r.BookID,
+		Kind:      book.Sale,
+		Quantity:  -r.Quantity,
+		Reason:    r.Reason,
+		User:      user,
+		Reference: fmt.Sprintf("reservation:%d", r.ID),
+	})
+	if err != nil {
+		b.Reserved += r.Quantity
+		s.books[b.ID] = b
+		return nil, err
+	}
+
+	r.Status = book.Committed
+	r.UpdatedAt = time.Now()
+	s.reservations[r.ID] = r
+	return &r, nil
+}
+
+func (s bookStore) ReleaseReservation(ctx context.Context, id uint) (*book.StockReservation, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	r, b, err := s.heldReservation(id)
+	if err != nil {
+		return nil, err
+	}
+
+	b.Reserved -= r.Quantity
+	touch(&b)
+	s.books[b.ID] = b
+
+	r.Status = book.Released
+	r.UpdatedAt = time.Now()
+	s.reservations[r.ID] = r
+	return &r, nil
+}
+
+//heldReservation returns a held reservation with its book
+func (s bookStore) heldReservation(id uint) (book.StockReservation, book.Book, error) {
+	r, ok := s.reservations[id]
+	if !ok {
+		return r, book.Book{}, gorm.ErrRecordNotFound
+	}
+	if r.Status != book.Held {
+		return r, book.Book{}, fmt.Errorf("%w: it is %s", book.ErrReservationNotHeld, r.Status)
+	}
+	b, err := s.liveBook(r.BookID)
+	return r, b, err
+}
@@ -0,0 +1,204 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/author"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/exporter"
+	"github.com/BatuhanSerin/postgresql/domain/importer"
+	"github.com/BatuhanSerin/postgresql/domain/order"
+	"github.com/jackc/pgconn"
+	"gorm.io/gorm"
+)
+
+//Catalog keeps books, their stock ledger, authors and the orders that take their stock in memory, it is safe for
+//concurrent use. It keeps the rules of the postgres schema: unique ISBNs, stock codes and author ids, books of
+//existing authors, soft deletes and versions. Nothing is recorded in the audit trail
+type Catalog struct {
+	mu           sync.Mutex
+	books        map[uint]book.Book
+	authors      map[uint]author.Author
+	movements    []book.StockMovement
+	reservations map[uint]book.StockReservation
+	orders       map[uint]order.Order
+	lastID       struct{ book, author, movement, reservation, order, item, transition uint }
+}
+
+//NewCatalog returns an empty catalog
+func NewCatalog() *Catalog {
+	return &Catalog{
+		books:        map[uint]book.Book{},
+		authors:      map[uint]author.Author{},
+		reservations: map[uint]book.StockReservation{},
+		orders:       map[uint]order.Order{},
+	}
+}
+
+//Books returns the catalog as a BookStore
+func (c *Catalog) Books() book.BookStore {
+	return bookStore{c}
+}
+
+//Authors returns the catalog as an AuthorStore
+func (c *Catalog) Authors() author.AuthorStore {
+	return authorStore{c}
+}
+
+//Orders returns the catalog as an OrderStore
+func (c *Catalog) Orders() order.OrderStore {
+	return orderStore{c}
+}
+
+//Imports returns the catalog as an ImportStore
+func (c *Catalog) Imports() importer.ImportStore {
+	return importStore{c}
+}
+
+//Exports returns the catalog as an ExportStore
+func (c *Catalog) Exports() exporter.ExportStore {
+	return exportStore{c}
+}
+
+//lock locks the catalog unless ctx is done already
+func (c *Catalog) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	return nil
+}
+
+//liveBook returns a book that is not deleted
+func (c *Catalog) liveBook(id uint) (book.Book, error) {
+	b, ok := c.books[id]
+	if !ok || b.DeletedAt.Valid {
+		return book.Book{}, gorm.ErrRecordNotFound
+	}
+	return b, nil
+}
+
+//booksOf returns the books of an author that are not deleted in id order
+func (c *Catalog) booksOf(authorID uint) []book.Book {
+	books := []book.Book{}
+	for _, b := range c.books {
+		if b.AuthorID == authorID && !b.DeletedAt.Valid {
+			books = append(books, b)
+		}
+	}
+	sortBooks(books)
+	return books
+}
+
+//authorByAuthorID returns the author with an author id, deleted ones too when unscoped
+func (c *Catalog) authorByAuthorID(authorID uint, unscoped bool) (author.Author, bool) {
+	for _, a := range c.authors {
+		if a.AuthorID == authorID && (unscoped || !a.DeletedAt.Valid) {
+			return a, true
+		}
+	}
+	return author.Author{}, false
+}
+
+//checkBook applies the unique indexes and the author foreign key of the books table to b
+func (c *Catalog) checkBook(b book.Book) error {
+	for _, other := range c.books {
+		if other.ID == b.ID {
+			continue
+		}
+		if other.ISBN == b.ISBN {
+			return uniqueViolation("books", "idx_books_isbn", "isbn", b.ISBN)
+		}
+		if other.StockCode == b.StockCode {
+			return uniqueViolation("books", "idx_books_stock_code", "stock_code", b.StockCode)
+		}
+	}
+	if _, ok := c.authorByAuthorID(b.AuthorID, true); !ok {
+		return &pgconn.PgError{
+			Code:           "23503",
+			Message:        `insert or update on table "books" violates foreign key constraint "fk_books_author"`,
+			Detail:         fmt.Sprintf(`Key (author_id)=(%d) is not present in table "authors".`, b.AuthorID),
+			TableName:      "books",
+			ConstraintName: "fk_books_author",
+		}
+	}
+	return nil
+}
+
+//uniqueViolation is the error postgres returns for a duplicate key, so that the http layer answers the same way
+func uniqueViolation(table, constraint, column string, value interface{}) error {
+	return &pgconn.PgError{
+		Code:           "23505",
+		Message:        fmt.Sprintf("duplicate key value violates unique constraint %q", constraint),
+		Detail:         fmt.Sprintf("Key (%s)=(%v) already exists.", column, value),
+		TableName:      table,
+		ConstraintName: constraint,
+	}
+}
+
+//moveStock adds a movement to the ledger and applies it to the stock of a book that is not deleted
+func (c *Catalog) moveStock(m *book.StockMovement) error {
+	b, err := c.liveBook(m.BookID)
+	if err != nil {
+		return err
+	}
+	return c.applyMovement(b, m)
+}
+
+//applyMovement adds a movement of b to the ledger and applies it to the stock of b
+func (c *Catalog) applyMovement(b book.Book, m *book.StockMovement) error {
+	stock := b.Stock + m.Quantity
+	if stock < b.Reserved {
+		return fmt.Errorf("%w: %d in stock, %d reserved", book.ErrInsufficientStock, b.Stock, b.Reserved)
+	}
+	c.lastID.movement++
+	m.ID = c.lastID.movement
+	m.StockAfter = stock
+	m.CreatedAt = time.Now()
+	c.movements = append(c.movements, *m)
+
+	b.Stock = stock
+	touch(&b)
+	c.books[b.ID] = b
+	return nil
+}
+
+//touch marks a book as written, the version changes with every write like in postgres
+func touch(b *book.Book) {
+	b.Version++
+	b.UpdatedAt = time.Now()
+}
+
+func sortBooks(books []book.Book) {
+	sort.Slice(books, func(i, j int) bool { return books[i].ID < books[j].ID })
+}
+
+//clone returns a copy of the catalog to change apart from it, the caller holds the lock of c
+func (c *Catalog) clone() *Catalog {
+	tx := NewCatalog()
+	for id, b := range c.books {
+		tx.books[id] = b
+	}
+	for id, a := range c.authors {
+		tx.authors[id] = a
+	}
+	tx.movements = append([]book.StockMovement{}, c.movements...)
+	for id, r := range c.reservations {
+		tx.reservations[id] = r
+	}
+	for id, o := range c.orders {
+		tx.orders[id] = o
+	}
+	tx.lastID = c.lastID
+	return tx
+}
+
+//adopt replaces the contents of the catalog with the ones of a clone, the caller holds the lock of c
+func (c *Catalog) adopt(tx *Catalog) {
+	c.books, c.authors, c.movements, c.reservations, c.orders = tx.books, tx.authors, tx.movements, tx.reservations, tx.orders
+	c.lastID = tx.lastID
+}
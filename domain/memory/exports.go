@@ -0,0 +1,52 @@
+package memory
+
+import (
+	"context"
+	"io"
+	"sort"
+
+	"github.com/BatuhanSerin/postgresql/domain/author"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/exporter"
+)
+
+//exportStore writes the books and authors of a catalog that are not deleted
+type exportStore struct {
+	*Catalog
+}
+
+//ExportBooks writes the books in id order, the catalog is only locked while they are copied
+func (s exportStore) ExportBooks(ctx context.Context, w io.Writer, format string) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	books := []book.Book{}
+	for _, b := range s.books {
+		if !b.DeletedAt.Valid {
+			books = append(books, b)
+		}
+	}
+	s.mu.Unlock()
+
+	sortBooks(books)
+	return exporter.WriteBooks(w, format, books)
+}
+
+//ExportAuthors writes the authors in AuthorID order without their books
+func (s exportStore) ExportAuthors(ctx context.Context, w io.Writer, format string) error {
+	if err := s.lock(ctx); err != nil {
+		return err
+	}
+	authors := []author.Author{}
+	for _, a := range s.authors {
+		if !a.DeletedAt.Valid {
+			authors = append(authors, a)
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(authors, func(i, j int) bool { return authors[i].AuthorID < authors[j].AuthorID })
+	return exporter.WriteAuthors(w, format, authors)
+}
+
+var _ exporter.ExportStore = exportStore{}
@@ -0,0 +1,23 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/BatuhanSerin/postgresql/common/db/migrations"
+	"github.com/BatuhanSerin/postgresql/domain/health"
+)
+
+//Health reports memory stores as a database that always answers and is migrated to this build
+type Health struct{}
+
+//Ping fails only when ctx is done
+func (Health) Ping(ctx context.Context) error {
+	return ctx.Err()
+}
+
+//MigrationVersion is the latest migration, memory stores have no schema to lag behind
+func (Health) MigrationVersion(ctx context.Context) (uint, error) {
+	return migrations.Latest(), ctx.Err()
+}
+
+var _ health.HealthStore = Health{}
@@ -0,0 +1,111 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/idempotency"
+)
+
+//IdempotencyKeys keeps the idempotency keys in memory with the rules of IdempotencyRepository, it is safe for
+//concurrent use
+type IdempotencyKeys struct {
+	mu      sync.Mutex
+	records map[[2]string]idempotency.Record
+}
+
+//NewIdempotencyKeys returns an empty IdempotencyStore
+func NewIdempotencyKeys() *IdempotencyKeys {
+	return &IdempotencyKeys{records: map[[2]string]idempotency.Record{}}
+}
+
+//lock locks the keys unless ctx is done already
+func (k *IdempotencyKeys) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	return nil
+}
+
+//Begin claims key, an expired key and the key of a request that did not complete within abandonAfter are claimed again
+func (k *IdempotencyKeys) Begin(ctx context.Context, principal, key, fingerprint string, ttl, abandonAfter time.Duration) (*idempotency.Record, error) {
+	if err := k.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer k.mu.Unlock()
+
+	now := time.Now()
+	stored, ok := k.records[[2]string{principal, key}]
+	abandoned := stored.Status == 0 && stored.CreatedAt.Before(now.Add(-abandonAfter))
+	if !ok || stored.ExpiresAt.Before(now) || abandoned {
+		k.records[[2]string{principal, key}] = idempotency.Record{
+			Principal:   principal,
+			Key:         key,
+			Fingerprint: fingerprint,
+			Headers:     idempotency.Headers{},
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(ttl),
+		}
+		return nil, nil
+	}
+
+	switch {
+	case stored.Fingerprint != fingerprint:
+		return nil, idempotency.ErrKeyReused
+	case stored.Status == 0:
+		return nil, idempotency.ErrInProgress
+	}
+	return &stored, nil
+}
+
+//Complete stores the response of the request that claimed key
+func (k *IdempotencyKeys) Complete(ctx context.Context, principal, key string, status int, headers idempotency.Headers, body []byte) error {
+	if err := k.lock(ctx); err != nil {
+		return err
+	}
+	defer k.mu.Unlock()
+
+	stored, ok := k.records[[2]string{principal, key}]
+	if !ok || stored.Status != 0 {
+		return nil
+	}
+	stored.Status = status
+	stored.Headers = headers
+	stored.Body = append([]byte{}, body...)
+	k.records[[2]string{principal, key}] = stored
+	return nil
+}
+
+//Release gives up the claim of a request that failed, so that a retry runs it again
+func (k *IdempotencyKeys) Release(ctx context.Context, principal, key string) error {
+	if err := k.lock(ctx); err != nil {
+		return err
+	}
+	defer k.mu.Unlock()
+
+	if stored, ok := k.records[[2]string{principal, key}]; ok && stored.Status == 0 {
+		delete(k.records, [2]string{principal, key})
+	}
+	return nil
+}
+
+//Purge deletes the keys that expired before now and returns how many were deleted
+func (k *IdempotencyKeys) Purge(ctx context.Context, now time.Time) (int, error) {
+	if err := k.lock(ctx); err != nil {
+		return 0, err
+	}
+	defer k.mu.Unlock()
+
+	purged := 0
+	for id, stored := range k.records {
+		if stored.ExpiresAt.Before(now) {
+			delete(k.records, id)
+			purged++
+		}
+	}
+	return purged, nil
+}
+
+var _ idempotency.IdempotencyStore = (*IdempotencyKeys)(nil)
@@ -0,0 +1,209 @@
+package memory
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/BatuhanSerin/postgresql/domain/author"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/importer"
+	"gorm.io/gorm"
+)
+
+//importStore imports books and authors into a catalog with the rules of importer.Importer
+type importStore struct {
+	*Catalog
+}
+
+func (s importStore) ImportBooks(ctx context.Context, r io.Reader, options importer.Options) (*importer.Report, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	rows, err := importer.ReadBooks(r, options.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := options.NewReport(len(rows))
+	return s.importInto(ctx, options, report, func(tx *Catalog) error {
+		seen := map[string]bool{}
+
+		for _, row := range rows {
+			if row.Fields != nil {
+				report.Errors = append(report.Errors, importer.RowError{Row: row.Row, Key: row.Book.ISBN, Fields: row.Fields})
+				continue
+			}
+			seen[row.Book.ISBN] = true
+
+			if err := importBook(ctx, tx, row.Book, options, report); err != nil {
+				report.Errors = append(report.Errors, importer.RowError{Row: row.Row, Key: row.Book.ISBN, Error: err.Error()})
+			}
+		}
+
+		if options.Mode != importer.Replace || len(report.Errors) > 0 {
+			return nil
+		}
+		stale := []book.Book{}
+		for _, b := range tx.books {
+			if !b.DeletedAt.Valid && !seen[b.ISBN] {
+				stale = append(stale, b)
+			}
+		}
+		sortBooks(stale)
+		for _, b := range stale {
+			if err := tx.Books().Delete(ctx, b.ID); err != nil {
+				return err
+			}
+			report.Deleted++
+		}
+		return nil
+	})
+}
+
+func importBook(ctx context.Context, tx *Catalog, b book.Book, options importer.Options, report *importer.Report) error {
+	books := tx.Books()
+	var existing *book.Book
+	for _, other := range tx.books {
+		if other.ISBN == b.ISBN {
+			existing = &other
+			break
+		}
+	}
+	if existing == nil {
+		if err := books.Create(ctx, &b); err != nil {
+			return err
+		}
+		report.Created++
+		return nil
+	}
+
+	if options.Mode == importer.Insert {
+		report.Skipped++
+		return nil
+	}
+
+	stock := b.Stock
+	if importer.SameBook(b, *existing) && stock == existing.Stock {
+		report.Skipped++
+		return nil
+	}
+	b.Model = existing.Model
+	b.DeletedAt = gorm.DeletedAt{}
+	b.Version = existing.Version
+	b.Stock = existing.Stock
+	b.Reserved = existing.Reserved
+	if err := books.Update(ctx, &b); err != nil {
+		return err
+	}
+	if stock != existing.Stock {
+		err := books.RecordMovement(ctx, &book.StockMovement{
+			BookID:   existing.ID,
+			Kind:     book.Adjustment,
+			Quantity: stock - existing.Stock,
+			Reason:   "import",
+			User:     options.User,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	report.Updated++
+	return nil
+}
+
+func (s importStore) ImportAuthors(ctx context.Context, r io.Reader, options importer.Options) (*importer.Report, error) {
+	if err := options.Validate(); err != nil {
+		return nil, err
+	}
+	rows, err := importer.ReadAuthors(r, options.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	report := options.NewReport(len(rows))
+	return s.importInto(ctx, options, report, func(tx *Catalog) error {
+		seen := map[uint]bool{}
+
+		for _, row := range rows {
+			key := strconv.FormatUint(uint64(row.Author.AuthorID), 10)
+			if row.Fields != nil {
+				report.Errors = append(report.Errors, importer.RowError{Row: row.Row, Key: key, Fields: row.Fields})
+				continue
+			}
+			seen[row.Author.AuthorID] = true
+
+			if err := importAuthor(ctx, tx, row.Author, options, report); err != nil {
+				report.Errors = append(report.Errors, importer.RowError{Row: row.Row, Key: key, Error: err.Error()})
+			}
+		}
+
+		if options.Mode != importer.Replace || len(report.Errors) > 0 {
+			return nil
+		}
+		stale := []uint{}
+		for _, a := range tx.authors {
+			if !a.DeletedAt.Valid && !seen[a.AuthorID] {
+				stale = append(stale, a.AuthorID)
+			}
+		}
+		sort.Slice(stale, func(i, j int) bool { return stale[i] < stale[j] })
+		for _, authorID := range stale {
+			if err := tx.Authors().Delete(ctx, authorID, author.DeleteOptions{}); err != nil {
+				report.Errors = append(report.Errors, importer.RowError{Key: strconv.FormatUint(uint64(authorID), 10), Error: err.Error()})
+				continue
+			}
+			report.Deleted++
+		}
+		return nil
+	})
+}
+
+func importAuthor(ctx context.Context, tx *Catalog, a author.Author, options importer.Options, report *importer.Report) error {
+	authors := tx.Authors()
+	existing, ok := tx.authorByAuthorID(a.AuthorID, true)
+	if !ok {
+		if err := authors.Create(ctx, &a); err != nil {
+			return err
+		}
+		report.Created++
+		return nil
+	}
+
+	if options.Mode == importer.Insert || (!existing.DeletedAt.Valid && existing.AuthorName == a.AuthorName) {
+		report.Skipped++
+		return nil
+	}
+	a.Model = existing.Model
+	a.DeletedAt = gorm.DeletedAt{}
+	a.Version = existing.Version
+	if err := authors.Update(ctx, &a); err != nil {
+		return err
+	}
+	report.Updated++
+	return nil
+}
+
+//importInto runs apply on a copy of the catalog and keeps the copy unless apply fails, rows were rejected or it is a
+//dry run. The catalog stays locked meanwhile, like the tables an import writes in postgres
+func (c *Catalog) importInto(ctx context.Context, options importer.Options, report *importer.Report, apply func(tx *Catalog) error) (*importer.Report, error) {
+	if err := c.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer c.mu.Unlock()
+
+	tx := c.clone()
+	if err := apply(tx); err != nil {
+		return nil, err
+	}
+	if len(report.Errors) > 0 {
+		return report, importer.ErrRowsRejected
+	}
+	if !options.DryRun {
+		c.adopt(tx)
+	}
+	return report, nil
+}
+
+var _ importer.ImportStore = importStore{}
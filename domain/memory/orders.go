@@ -0,0 +1,173 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/money"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/order"
+	"gorm.io/gorm"
+)
+
+//orderStore serves the orders of a catalog, checkouts and cancellations move the stock of its books
+type orderStore struct {
+	*Catalog
+}
+
+func (s orderStore) Checkout(ctx context.Context, cart order.Cart) (*order.Order, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	// every book is checked first, nothing is taken when any book lacks stock
+	items := order.MergeItems(cart.Items)
+	for _, item := range items {
+		b, err := s.liveBook(item.BookID)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %d", order.ErrUnknownBook, item.BookID)
+		}
+		if available := b.Stock - b.Reserved; item.Quantity > available {
+			return nil, fmt.Errorf("%w: %d available", book.ErrInsufficientStock, available)
+		}
+	}
+
+	now := time.Now()
+	s.lastID.order++
+	o := order.Order{ID: s.lastID.order, CustomerID: cart.CustomerID, Status: order.Pending, CreatedAt: now, UpdatedAt: now}
+	for _, item := range items {
+		// the stock is reserved and the reservation committed at once, like a checkout in postgres
+		s.lastID.reservation++
+		r := book.StockReservation{
+			ID:        s.lastID.reservation,
+			BookID:    item.BookID,
+			Quantity:  item.Quantity,
+			Status:    book.Committed,
+			Reason:    "checkout",
+			User:      cart.User,
+			Reference: fmt.Sprintf("order:%d", o.ID),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		s.reservations[r.ID] = r
+		b := s.books[item.BookID]
+		err := s.applyMovement(b, &book.StockMovement{
+			BookID:    item.BookID,
+			Kind:      book.Sale,
+			Quantity:  -item.Quantity,
+			Reason:    r.Reason,
+			User:      cart.User,
+			Reference: fmt.Sprintf("reservation:%d", r.ID),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		s.lastID.item++
+		line := order.OrderItem{
+			ID:        s.lastID.item,
+			OrderID:   o.ID,
+			BookID:    item.BookID,
+			Quantity:  item.Quantity,
+			UnitPrice: b.Cost,
+			LineTotal: b.Cost * money.Money(item.Quantity),
+		}
+		o.Items = append(o.Items, line)
+		o.Total += line.LineTotal
+	}
+	s.recordTransition(&o, "", order.Pending, "checkout", cart.User)
+	s.orders[o.ID] = o
+	return &o, nil
+}
+
+func (s orderStore) GetByID(ctx context.Context, id uint) (*order.Order, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &o, nil
+}
+
+func (s orderStore) FindByCustomer(ctx context.Context, customerID string) ([]order.Order, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	orders := []order.Order{}
+	for id := s.lastID.order; id > 0; id-- {
+		if o, ok := s.orders[id]; ok && o.CustomerID == customerID {
+			orders = append(orders, o)
+		}
+	}
+	return orders, nil
+}
+
+func (s orderStore) Transition(ctx context.Context, id uint, status, reason, user string) (*order.Order, error) {
+	if err := s.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer s.mu.Unlock()
+
+	o, ok := s.orders[id]
+	if !ok {
+		return nil, gorm.ErrRecordNotFound
+	}
+	if !o.CanMoveTo(status) {
+		return nil, fmt.Errorf("%w: %s to %s", order.ErrInvalidTransition, o.Status, status)
+	}
+
+	if status == order.Cancelled || status == order.Returned {
+		// the stock goes back to books in the trash as well, only a book that is gone for good fails the order
+		for _, item := range o.Items {
+			if _, ok := s.books[item.BookID]; !ok {
+				return nil, gorm.ErrRecordNotFound
+			}
+		}
+		for _, item := range o.Items {
+			err := s.applyMovement(s.books[item.BookID], &book.StockMovement{
+				BookID:    item.BookID,
+				Kind:      book.Return,
+				Quantity:  item.Quantity,
+				Reason:    "order " + status,
+				User:      user,
+				Reference: fmt.Sprintf("order:%d", o.ID),
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	s.recordTransition(&o, o.Status, status, reason, user)
+	s.orders[o.ID] = o
+	return &o, nil
+}
+
+//recordTransition moves o from one status to another and records the change. The transitions are copied, so that an
+//order handed out before keeps its own
+func (c *Catalog) recordTransition(o *order.Order, from, to, reason, user string) {
+	now := time.Now()
+	c.lastID.transition++
+	transitions := append([]order.OrderTransition{}, o.Transitions...)
+	o.Transitions = append(transitions, order.OrderTransition{
+		ID:         c.lastID.transition,
+		OrderID:    o.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+		User:       user,
+		CreatedAt:  now,
+	})
+	o.Status = to
+	o.UpdatedAt = now
+}
+
+var _ order.OrderStore = orderStore{}
@@ -0,0 +1,107 @@
+package order
+
+import (
+	"strings"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/money"
+)
+
+// Order statuses
+const (
+	Pending   = "pending"
+	Paid      = "paid"
+	Shipped   = "shipped"
+	Cancelled = "cancelled"
+	Returned  = "returned"
+)
+
+// transitions lists the statuses an order can move to from each status
+var transitions = map[string][]string{
+	Pending: {Paid, Cancelled},
+	Paid:    {Shipped, Cancelled},
+	Shipped: {Returned},
+}
+
+type Order struct {
+	ID          uint
+	CustomerID  string
+	Status      string
+	Total       money.Money `gorm:"type:numeric(12,2)"`
+	Items       []OrderItem
+	Transitions []OrderTransition
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+}
+
+//OrderItem is a book of an order priced at checkout
+type OrderItem struct {
+	ID        uint
+	OrderID   uint
+	BookID    uint
+	Quantity  int
+	UnitPrice money.Money `gorm:"type:numeric(12,2)"`
+	LineTotal money.Money `gorm:"type:numeric(12,2)"`
+}
+
+//OrderTransition records a status change of an order
+type OrderTransition struct {
+	ID         uint
+	OrderID    uint
+	FromStatus string
+	ToStatus   string
+	Reason     string
+	User       string `gorm:"column:created_by"`
+	CreatedAt  time.Time
+}
+
+//CartItem is a book and quantity to check out
+type CartItem struct {
+	BookID   uint
+	Quantity int
+}
+
+//Cart is what a customer checks out
+type Cart struct {
+	CustomerID string
+	Items      []CartItem
+	User       string
+}
+
+// Validate checks the cart fields and returns the rejected ones with their reasons
+func (c Cart) Validate() map[string]string {
+	fields := map[string]string{}
+
+	if strings.TrimSpace(c.CustomerID) == "" {
+		fields["CustomerID"] = "is required"
+	}
+	if strings.TrimSpace(c.User) == "" {
+		fields["User"] = "is required"
+	}
+	if len(c.Items) == 0 {
+		fields["Items"] = "must contain at least one book"
+	}
+	for _, item := range c.Items {
+		if item.BookID == 0 {
+			fields["Items"] = "every item needs a BookID"
+		}
+		if item.Quantity <= 0 {
+			fields["Items"] = "every item needs a positive Quantity"
+		}
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
+
+//CanMoveTo reports whether the order may change from its status to status
+func (o Order) CanMoveTo(status string) bool {
+	for _, next := range transitions[o.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
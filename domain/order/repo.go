@@ -0,0 +1,191 @@
+package order
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/money"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownBook       = errors.New("Book in the cart does not exist")
+	ErrInvalidTransition = errors.New("Order cannot move to this status")
+)
+
+//OrderRepository is a struct for OrderRepository
+type OrderRepository struct {
+	db *gorm.DB
+}
+
+//NewOrderRepository returns Order Repository
+func NewOrderRepository(db *gorm.DB) *OrderRepository {
+	return &OrderRepository{db: db}
+}
+
+//WithContext returns a repository whose queries run with ctx, the actor of ctx is recorded in the audit trail of the stock
+func (o *OrderRepository) WithContext(ctx context.Context) *OrderRepository {
+	return &OrderRepository{db: o.db.WithContext(ctx)}
+}
+
+//Checkout prices the cart from the book costs and takes its stock in one transaction,
+//the order starts as pending and nothing is written when any book lacks stock
+func (o *OrderRepository) Checkout(cart Cart) (*Order, error) {
+	items := MergeItems(cart.Items)
+	order := Order{CustomerID: cart.CustomerID, Status: Pending}
+
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.Omit(clause.Associations).Create(&order); result.Error != nil {
+			return result.Error
+		}
+
+		books := book.NewBookRepository(tx)
+		reference := fmt.Sprintf("order:%d", order.ID)
+		for _, item := range items {
+			reservation := book.StockReservation{
+				BookID:    item.BookID,
+				Quantity:  item.Quantity,
+				Reason:    "checkout",
+				User:      cart.User,
+				Reference: reference,
+			}
+			if err := books.Reserve(&reservation); err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return fmt.Errorf("%w: %d", ErrUnknownBook, item.BookID)
+				}
+				return err
+			}
+			if _, err := books.CommitReservation(reservation.ID, cart.User); err != nil {
+				return err
+			}
+
+			var b book.Book
+			if result := tx.First(&b, item.BookID); result.Error != nil {
+				return result.Error
+			}
+			line := OrderItem{
+				OrderID:   order.ID,
+				BookID:    item.BookID,
+				Quantity:  item.Quantity,
+				UnitPrice: b.Cost,
+				LineTotal: b.Cost * money.Money(item.Quantity),
+			}
+			if result := tx.Create(&line); result.Error != nil {
+				return result.Error
+			}
+			order.Items = append(order.Items, line)
+			order.Total += line.LineTotal
+		}
+
+		if result := tx.Model(&order).Update("total", order.Total); result.Error != nil {
+			return result.Error
+		}
+		return recordTransition(tx, &order, "", Pending, "checkout", cart.User)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
+
+//GetByID returns order by its ID with its items and transitions
+func (o *OrderRepository) GetByID(id uint) (*Order, error) {
+	var order Order
+	result := o.db.Preload("Items").Preload("Transitions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).First(&order, id)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &order, nil
+}
+
+//FindByCustomer returns the order history of a customer, newest first
+func (o *OrderRepository) FindByCustomer(customerID string) ([]Order, error) {
+	orders := []Order{}
+	result := o.db.Where("customer_id = ?", customerID).Preload("Items").Preload("Transitions", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id")
+	}).Order("id desc").Find(&orders)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return orders, nil
+}
+
+//Transition moves an order to status and records the change,
+//cancelling or returning an order puts the stock of its items back, even of books that are in the trash
+func (o *OrderRepository) Transition(id uint, status, reason, user string) (*Order, error) {
+	err := o.db.Transaction(func(tx *gorm.DB) error {
+		var order Order
+		result := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Items").First(&order, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if !order.CanMoveTo(status) {
+			return fmt.Errorf("%w: %s to %s", ErrInvalidTransition, order.Status, status)
+		}
+
+		if status == Cancelled || status == Returned {
+			books := book.NewBookRepository(tx)
+			for _, item := range order.Items {
+				err := books.ReturnStock(&book.StockMovement{
+					BookID:    item.BookID,
+					Quantity:  item.Quantity,
+					Reason:    "order " + status,
+					User:      user,
+					Reference: fmt.Sprintf("order:%d", order.ID),
+				})
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		from := order.Status
+		if result := tx.Model(&order).Update("status", status); result.Error != nil {
+			return result.Error
+		}
+		return recordTransition(tx, &order, from, status, reason, user)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return o.GetByID(id)
+}
+
+func recordTransition(tx *gorm.DB, order *Order, from, to, reason, user string) error {
+	t := OrderTransition{
+		OrderID:    order.ID,
+		FromStatus: from,
+		ToStatus:   to,
+		Reason:     reason,
+		User:       user,
+		CreatedAt:  time.Now(),
+	}
+	if result := tx.Create(&t); result.Error != nil {
+		return result.Error
+	}
+	order.Transitions = append(order.Transitions, t)
+	return nil
+}
+
+//MergeItems adds up the quantities of repeated books and orders the items by book,
+//so that concurrent checkouts always lock the book rows in the same order
+func MergeItems(items []CartItem) []CartItem {
+	quantities := map[uint]int{}
+	for _, item := range items {
+		quantities[item.BookID] += item.Quantity
+	}
+
+	merged := make([]CartItem, 0, len(quantities))
+	for bookID, quantity := range quantities {
+		merged = append(merged, CartItem{BookID: bookID, Quantity: quantity})
+	}
+	sort.Slice(merged, func(i, j int) bool { return merged[i].BookID < merged[j].BookID })
+	return merged
+}
@@ -0,0 +1,38 @@
+package order
+
+import "context"
+
+//OrderStore keeps the orders, every call runs with the context of its request.
+//OrderRepository keeps them in the database through Store
+type OrderStore interface {
+	Checkout(ctx context.Context, cart Cart) (*Order, error)
+	GetByID(ctx context.Context, id uint) (*Order, error)
+	FindByCustomer(ctx context.Context, customerID string) ([]Order, error)
+	Transition(ctx context.Context, id uint, status, reason, user string) (*Order, error)
+}
+
+//Store returns the repository as an OrderStore
+func (o *OrderRepository) Store() OrderStore {
+	return repoStore{repo: o}
+}
+
+//repoStore runs every call of an OrderStore on the repository with the context of the call
+type repoStore struct {
+	repo *OrderRepository
+}
+
+func (s repoStore) Checkout(ctx context.Context, cart Cart) (*Order, error) {
+	return s.repo.WithContext(ctx).Checkout(cart)
+}
+
+func (s repoStore) GetByID(ctx context.Context, id uint) (*Order, error) {
+	return s.repo.WithContext(ctx).GetByID(id)
+}
+
+func (s repoStore) FindByCustomer(ctx context.Context, customerID string) ([]Order, error) {
+	return s.repo.WithContext(ctx).FindByCustomer(customerID)
+}
+
+func (s repoStore) Transition(ctx context.Context, id uint, status, reason, user string) (*Order, error) {
+	return s.repo.WithContext(ctx).Transition(id, status, reason, user)
+}
@@ -0,0 +1,31 @@
+package search
+
+// BookHit is a book that matches a search, Highlight marks the matched words of its name and author with <mark>
+type BookHit struct {
+	ID              uint
+	Name            string
+	ISBN            string
+	AuthorID        uint
+	AuthorName      string
+	Rank            float64
+	Highlight       string
+	AuthorHighlight string
+	Exact           bool `json:"-"`
+}
+
+// AuthorHit is an author that matches a search
+type AuthorHit struct {
+	AuthorID   uint
+	AuthorName string
+	Rank       float64
+	Highlight  string
+	Exact      bool `json:"-"`
+}
+
+// Result is the ranked books and authors for a search and "did you mean" suggestions when nothing matched exactly
+type Result struct {
+	Query       string
+	Books       []BookHit
+	Authors     []AuthorHit
+	Suggestions []string
+}
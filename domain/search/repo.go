@@ -0,0 +1,172 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultLimit = 10
+	MaxLimit     = 50
+)
+
+var ErrEmptyQuery = errors.New("Search query is empty")
+
+const headlineOptions = "StartSel=<mark>, StopSel=</mark>, HighlightAll=true"
+
+//SearchRepository is a struct for SearchRepository
+type SearchRepository struct {
+	db *gorm.DB
+}
+
+//NewSearchRepository returns Search Repository
+func NewSearchRepository(db *gorm.DB) *SearchRepository {
+	return &SearchRepository{db: db}
+}
+
+//WithContext returns a repository whose queries run with ctx
+func (s *SearchRepository) WithContext(ctx context.Context) *SearchRepository {
+	return &SearchRepository{db: s.db.WithContext(ctx)}
+}
+
+//Search ranks books by their name, ISBN and author name and authors by their name,
+//every word of the query matches as a prefix and in any order, typos are caught by trigram similarity
+func (s *SearchRepository) Search(term string, limit int) (*Result, error) {
+	term = strings.TrimSpace(term)
+	words := prefixQuery(term)
+	isbn := isbnQuery(term)
+	if words == "" && isbn == "" {
+		return nil, ErrEmptyQuery
+	}
+	if limit <= 0 || limit > MaxLimit {
+		limit = DefaultLimit
+	}
+	if s.db.Dialector.Name() == "sqlite" {
+		return s.searchWords(term, isbn, limit)
+	}
+
+	args := map[string]interface{}{
+		"words":    words,
+		"term":     term,
+		"isbn":     isbn,
+		"limit":    limit,
+		"headline": headlineOptions,
+	}
+	result := &Result{Query: term, Books: []BookHit{}, Authors: []AuthorHit{}, Suggestions: []string{}}
+
+	err := s.db.Raw(`WITH q AS (
+			SELECT to_tsquery('simple', search_normalize(@words)) AS query, search_normalize(@term) AS term
+		)
+		SELECT b.id, b.name, b.isbn, b.author_id, COALESCE(a.author_name, '') AS author_name,
+			ts_rank(b.search_vector, q.query) * 2
+				+ COALESCE(ts_rank(a.search_vector, q.query), 0)
+				+ word_similarity(q.term, search_normalize(b.name))
+				+ COALESCE(word_similarity(q.term, search_normalize(a.author_name)), 0) / 2
+				+ CASE WHEN @isbn <> '' AND replace(b.isbn, '-', '') LIKE '%' || @isbn || '%' THEN 2 ELSE 0 END AS rank,
+			ts_headline('simple', b.name, q.query, @headline) AS highlight,
+			COALESCE(ts_headline('simple', a.author_name, q.query, @headline), '') AS author_highlight,
+			(b.search_vector @@ q.query OR COALESCE(a.search_vector @@ q.query, false)
+				OR (@isbn <> '' AND replace(b.isbn, '-', '') LIKE '%' || @isbn || '%')) AS exact
+		FROM q, books b
+		LEFT JOIN authors a ON a.author_id = b.author_id AND a.deleted_at IS NULL
+		WHERE b.deleted_at IS NULL AND (
+			b.search_vector @@ q.query
+			OR a.search_vector @@ q.query
+			OR q.term <% search_normalize(b.name)
+			OR q.term <% search_normalize(a.author_name)
+			OR (@isbn <> '' AND replace(b.isbn, '-', '') LIKE '%' || @isbn || '%'))
+		ORDER BY rank DESC, b.id
+		LIMIT @limit`, args).Scan(&result.Books).Error
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.db.Raw(`WITH q AS (
+			SELECT to_tsquery('simple', search_normalize(@words)) AS query, search_normalize(@term) AS term
+		)
+		SELECT a.author_id, a.author_name,
+			ts_rank(a.search_vector, q.query) * 2 + word_similarity(q.term, search_normalize(a.author_name)) AS rank,
+			ts_headline('simple', a.author_name, q.query, @headline) AS highlight,
+			a.search_vector @@ q.query AS exact
+		FROM q, authors a
+		WHERE a.deleted_at IS NULL AND (a.search_vector @@ q.query OR q.term <% search_normalize(a.author_name))
+		ORDER BY rank DESC, a.author_id
+		LIMIT @limit`, args).Scan(&result.Authors).Error
+	if err != nil {
+		return nil, err
+	}
+
+	if !anyExact(result) {
+		if err := s.suggest(term, result); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+//suggest fills the "did you mean" suggestions with the book and author names closest to the query
+func (s *SearchRepository) suggest(term string, result *Result) error {
+	return s.db.Raw(`SELECT name FROM (
+			SELECT name, similarity(search_normalize(name), search_normalize(@term)) AS score
+			FROM books WHERE deleted_at IS NULL AND search_normalize(name) % search_normalize(@term)
+			UNION
+			SELECT author_name, similarity(search_normalize(author_name), search_normalize(@term))
+			FROM authors WHERE deleted_at IS NULL AND search_normalize(author_name) % search_normalize(@term)
+		) t
+		WHERE search_normalize(name) <> search_normalize(@term)
+		ORDER BY score DESC, name
+		LIMIT 3`, map[string]interface{}{"term": term}).Scan(&result.Suggestions).Error
+}
+
+func anyExact(result *Result) bool {
+	for _, hit := range result.Books {
+		if hit.Exact {
+			return true
+		}
+	}
+	for _, hit := range result.Authors {
+		if hit.Exact {
+			return true
+		}
+	}
+	return false
+}
+
+//prefixQuery turns the words of the query into a tsquery where every word matches as a prefix,
+//anything but letters and digits is dropped so that user input never reaches the tsquery syntax
+func prefixQuery(term string) string {
+	words := queryWords(term)
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+//queryWords splits the query into its words, anything but letters and digits separates them
+func queryWords(term string) []string {
+	return strings.FieldsFunc(term, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+//isbnQuery returns the digits of the query when it looks like a part of an ISBN
+func isbnQuery(term string) string {
+	var digits strings.Builder
+	for _, r := range term {
+		switch {
+		case r >= '0' && r <= '9':
+			digits.WriteRune(r)
+		case r == '-' || r == ' ':
+		default:
+			return ""
+		}
+	}
+	if digits.Len() < 4 {
+		return ""
+	}
+	return digits.String()
+}
@@ -0,0 +1,23 @@
+package search
+
+import "context"
+
+//SearchStore searches the books and authors, every call runs with the context of its request.
+//SearchRepository searches the database through Store
+type SearchStore interface {
+	Search(ctx context.Context, term string, limit int) (*Result, error)
+}
+
+//Store returns the repository as a SearchStore
+func (s *SearchRepository) Store() SearchStore {
+	return repoStore{repo: s}
+}
+
+//repoStore runs every call of a SearchStore on the repository with the context of the call
+type repoStore struct {
+	repo *SearchRepository
+}
+
+func (s repoStore) Search(ctx context.Context, term string, limit int) (*Result, error) {
+	return s.repo.WithContext(ctx).Search(term, limit)
+}
@@ -0,0 +1,139 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+//normalizer does what the search_normalize function of postgres does before lowering
+var normalizer = strings.NewReplacer("İ", "i", "I", "i", "ı", "i", "Ş", "s", "ş", "s", "Ğ", "g", "ğ", "g",
+	"Ç", "c", "ç", "c", "Ö", "o", "ö", "o", "Ü", "u", "ü", "u")
+
+//searchWords is the search of databases without full text search and trigrams, which is sqlite.
+//Books and authors are matched in Go: every word of the query is the prefix of a word of the name, in any order.
+//Typos are not caught, so there are no suggestions either
+func (s *SearchRepository) searchWords(term, isbn string, limit int) (*Result, error) {
+	words := queryWords(normalize(term))
+	result := &Result{Query: term, Books: []BookHit{}, Authors: []AuthorHit{}, Suggestions: []string{}}
+
+	var books []BookHit
+	err := s.db.Raw(`SELECT b.id, b.name, b.isbn, b.author_id, COALESCE(a.author_name, '') AS author_name
+		FROM books b
+		LEFT JOIN authors a ON a.author_id = b.author_id AND a.deleted_at IS NULL
+		WHERE b.deleted_at IS NULL`).Scan(&books).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range books {
+		if matchesWords(words, hit.Name) {
+			hit.Rank += 2
+		}
+		if hit.AuthorName != "" && matchesWords(words, hit.AuthorName) {
+			hit.Rank++
+		}
+		if isbn != "" && strings.Contains(strings.ReplaceAll(hit.ISBN, "-", ""), isbn) {
+			hit.Rank += 2
+		}
+		if hit.Rank == 0 {
+			continue
+		}
+		hit.Highlight = highlight(words, hit.Name)
+		hit.AuthorHighlight = highlight(words, hit.AuthorName)
+		hit.Exact = true
+		result.Books = append(result.Books, hit)
+	}
+	sort.SliceStable(result.Books, func(i, j int) bool {
+		if result.Books[i].Rank != result.Books[j].Rank {
+			return result.Books[i].Rank > result.Books[j].Rank
+		}
+		return result.Books[i].ID < result.Books[j].ID
+	})
+	if len(result.Books) > limit {
+		result.Books = result.Books[:limit]
+	}
+
+	var authors []AuthorHit
+	err = s.db.Raw(`SELECT author_id, author_name FROM authors WHERE deleted_at IS NULL ORDER BY author_id`).
+		Scan(&authors).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, hit := range authors {
+		if len(result.Authors) == limit {
+			break
+		}
+		if !matchesWords(words, hit.AuthorName) {
+			continue
+		}
+		hit.Rank = 2
+		hit.Highlight = highlight(words, hit.AuthorName)
+		hit.Exact = true
+		result.Authors = append(result.Authors, hit)
+	}
+	return result, nil
+}
+
+func normalize(s string) string {
+	return strings.ToLower(normalizer.Replace(s))
+}
+
+//matchesWords tells if every query word is the prefix of a word of text
+func matchesWords(words []string, text string) bool {
+	if len(words) == 0 {
+		return false
+	}
+	textWords := queryWords(normalize(text))
+	for _, word := range words {
+		if !hasPrefixed(textWords, word) {
+			return false
+		}
+	}
+	return true
+}
+
+func hasPrefixed(textWords []string, word string) bool {
+	for _, textWord := range textWords {
+		if strings.HasPrefix(textWord, word) {
+			return true
+		}
+	}
+	return false
+}
+
+//highlight marks the words of text that start with a query word with <mark> like ts_headline does
+func highlight(words []string, text string) string {
+	var out, word strings.Builder
+	flush := func() {
+		if word.Len() == 0 {
+			return
+		}
+		if matchesAny(words, word.String()) {
+			out.WriteString("<mark>" + word.String() + "</mark>")
+		} else {
+			out.WriteString(word.String())
+		}
+		word.Reset()
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			word.WriteRune(r)
+			continue
+		}
+		flush()
+		out.WriteRune(r)
+	}
+	flush()
+	return out.String()
+}
+
+//matchesAny tells if a word of text starts with any of the query words
+func matchesAny(words []string, textWord string) bool {
+	textWord = normalize(textWord)
+	for _, word := range words {
+		if strings.HasPrefix(textWord, word) {
+			return true
+		}
+	}
+	return false
+}
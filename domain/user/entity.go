@@ -0,0 +1,113 @@
+package user
+
+import (
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Roles, each role has the permissions listed in rolePermissions
+const (
+	Admin  = "admin"
+	Editor = "editor"
+	Clerk  = "clerk"
+	Reader = "reader"
+)
+
+// Permissions a route can require
+const (
+	BooksRead     = "books:read"
+	BooksWrite    = "books:write"
+	AuthorsRead   = "authors:read"
+	AuthorsWrite  = "authors:write"
+	StockAdjust   = "stock:adjust"
+	OrdersRead    = "orders:read"
+	OrdersWrite   = "orders:write"
+	CatalogImport = "catalog:import"
+	CatalogExport = "catalog:export"
+	UsersManage   = "users:manage"
+	APIKeysManage = "apikeys:manage"
+	AuditRead     = "audit:read"
+	SystemRead    = "system:read"
+)
+
+// rolePermissions lists what each role may do, readers browse, clerks handle stock and orders,
+// editors change the catalog and admins do everything
+var rolePermissions = map[string][]string{
+	Reader: {BooksRead, AuthorsRead},
+	Clerk:  {BooksRead, AuthorsRead, StockAdjust, OrdersRead, OrdersWrite},
+	Editor: {BooksRead, AuthorsRead, BooksWrite, AuthorsWrite, CatalogImport, CatalogExport, AuditRead},
+	Admin: {BooksRead, AuthorsRead, BooksWrite, AuthorsWrite, StockAdjust, OrdersRead, OrdersWrite,
+		CatalogImport, CatalogExport, UsersManage, APIKeysManage, AuditRead, SystemRead},
+}
+
+var usernamePattern = regexp.MustCompile(`^[a-z0-9._-]{3,64}$`)
+
+type User struct {
+	ID           uint
+	Username     string
+	PasswordHash string `json:"-"`
+	Role         string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+//Registration is the body of a new account, Role is reader unless an admin creates the account
+type Registration struct {
+	Username string
+	Password string
+	Role     string
+}
+
+//RoleChange is the body of a role change
+type RoleChange struct {
+	Role string
+}
+
+//ValidRole reports whether role is a known role
+func ValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+//ValidPermission reports whether permission is granted by any role
+func ValidPermission(permission string) bool {
+	return HasPermission(Admin, permission)
+}
+
+//HasPermission reports whether role grants permission
+func HasPermission(role, permission string) bool {
+	for _, p := range rolePermissions[role] {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+//NormalizeUsername makes usernames case insensitive, usernames are stored and looked up normalized
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// Validate checks the registration fields and returns the rejected ones with their reasons
+func (r Registration) Validate() map[string]string {
+	fields := map[string]string{}
+
+	if !usernamePattern.MatchString(NormalizeUsername(r.Username)) {
+		fields["Username"] = "must be 3 to 64 letters, digits, dots, dashes or underscores"
+	}
+	if len(r.Password) < 8 {
+		fields["Password"] = "must be at least 8 characters"
+	} else if len(r.Password) > 72 {
+		fields["Password"] = "must be at most 72 bytes"
+	}
+	if r.Role != "" && !ValidRole(r.Role) {
+		fields["Role"] = "must be admin, editor, clerk or reader"
+	}
+
+	if len(fields) == 0 {
+		return nil
+	}
+	return fields
+}
@@ -0,0 +1,32 @@
+package user
+
+import "golang.org/x/crypto/bcrypt"
+
+// dummyHash is compared against when the username is unknown
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+//NewAccount returns the user of a registration with a bcrypt hash of its password, the role defaults to reader
+func NewAccount(r Registration) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(r.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	user := User{Username: NormalizeUsername(r.Username), PasswordHash: string(hash), Role: r.Role}
+	if user.Role == "" {
+		user.Role = Reader
+	}
+	return &user, nil
+}
+
+//CheckPassword returns ErrWrongCredentials unless password is the one of u. A nil u is an unknown username, the
+//password is compared anyway so that unknown usernames take as long as wrong passwords
+func CheckPassword(u *User, password string) error {
+	hash := dummyHash
+	if u != nil {
+		hash = []byte(u.PasswordHash)
+	}
+	if err := bcrypt.CompareHashAndPassword(hash, []byte(password)); err != nil || u == nil {
+		return ErrWrongCredentials
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrUsernameTaken    = errors.New("Username is already taken")
+	ErrWrongCredentials = errors.New("Wrong credentials")
+	ErrUnknownRole      = errors.New("Unknown role")
+	ErrLastAdmin        = errors.New("The last admin cannot lose the admin role")
+	ErrInvalidAdmin     = errors.New("Invalid admin credentials")
+)
+
+//UserRepository is a struct for UserRepository
+type UserRepository struct {
+	db *gorm.DB
+}
+
+//NewUserRepository returns User Repository
+func NewUserRepository(db *gorm.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+//WithContext returns a repository whose queries run with ctx
+func (u *UserRepository) WithContext(ctx context.Context) *UserRepository {
+	return &UserRepository{db: u.db.WithContext(ctx)}
+}
+
+//Register creates an account with a bcrypt hash of its password, the role defaults to reader
+func (u *UserRepository) Register(r Registration) (*User, error) {
+	user, err := NewAccount(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var count int64
+	if result := u.db.Model(&User{}).Where("username = ?", user.Username).Count(&count); result.Error != nil {
+		return nil, result.Error
+	}
+	if count > 0 {
+		return nil, ErrUsernameTaken
+	}
+	if result := u.db.Create(user); result.Error != nil {
+		return nil, result.Error
+	}
+	return user, nil
+}
+
+//Authenticate returns the user of the credentials, a wrong username and a wrong password fail alike
+func (u *UserRepository) Authenticate(username, password string) (*User, error) {
+	var user User
+	result := u.db.Where("username = ?", NormalizeUsername(username)).First(&user)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, CheckPassword(nil, password)
+	}
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if err := CheckPassword(&user, password); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+//GetByID returns user by its ID
+func (u *UserRepository) GetByID(id uint) (*User, error) {
+	var user User
+	if result := u.db.First(&user, id); result.Error != nil {
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+//FindAll returns every user ordered by ID
+func (u *UserRepository) FindAll() ([]User, error) {
+	users := []User{}
+	if result := u.db.Order("id").Find(&users); result.Error != nil {
+		return nil, result.Error
+	}
+	return users, nil
+}
+
+//ChangeRole gives a user another role, the last admin keeps its role
+func (u *UserRepository) ChangeRole(id uint, role string) (*User, error) {
+	if !ValidRole(role) {
+		return nil, ErrUnknownRole
+	}
+	var user User
+	err := u.db.Transaction(func(tx *gorm.DB) error {
+		if result := tx.First(&user, id); result.Error != nil {
+			return result.Error
+		}
+		if user.Role == Admin && role != Admin {
+			var admins int64
+			if result := tx.Model(&User{}).Where("role = ?", Admin).Count(&admins); result.Error != nil {
+				return result.Error
+			}
+			if admins <= 1 {
+				return ErrLastAdmin
+			}
+		}
+		user.Role = role
+		return tx.Model(&user).Update("role", role).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+//EnsureAdmin creates an admin account when there is no admin yet, so that a new installation can be managed.
+//The credentials are validated like every registration even when an admin exists, a weak password is refused
+func (u *UserRepository) EnsureAdmin(username, password string) error {
+	reg := Registration{Username: username, Password: password, Role: Admin}
+	if fields := reg.Validate(); fields != nil {
+		names := make([]string, 0, len(fields))
+		for name := range fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		err := ErrInvalidAdmin
+		for _, name := range names {
+			err = fmt.Errorf("%w, %s %s", err, name, fields[name])
+		}
+		return err
+	}
+
+	var admins int64
+	if result := u.db.Model(&User{}).Where("role = ?", Admin).Count(&admins); result.Error != nil {
+		return result.Error
+	}
+	if admins > 0 {
+		return nil
+	}
+	_, err := u.Register(reg)
+	return err
+}
@@ -0,0 +1,38 @@
+package user
+
+import "context"
+
+//UserStore keeps the users, every call runs with the context of its request.
+//UserRepository keeps them in the database through Store
+type UserStore interface {
+	Register(ctx context.Context, r Registration) (*User, error)
+	GetByID(ctx context.Context, id uint) (*User, error)
+	FindAll(ctx context.Context) ([]User, error)
+	ChangeRole(ctx context.Context, id uint, role string) (*User, error)
+}
+
+//Store returns the repository as a UserStore
+func (u *UserRepository) Store() UserStore {
+	return repoStore{repo: u}
+}
+
+//repoStore runs every call of a UserStore on the repository with the context of the call
+type repoStore struct {
+	repo *UserRepository
+}
+
+func (s repoStore) Register(ctx context.Context, r Registration) (*User, error) {
+	return s.repo.WithContext(ctx).Register(r)
+}
+
+func (s repoStore) GetByID(ctx context.Context, id uint) (*User, error) {
+	return s.repo.WithContext(ctx).GetByID(id)
+}
+
+func (s repoStore) FindAll(ctx context.Context) ([]User, error) {
+	return s.repo.WithContext(ctx).FindAll()
+}
+
+func (s repoStore) ChangeRole(ctx context.Context, id uint, role string) (*User, error) {
+	return s.repo.WithContext(ctx).ChangeRole(id, role)
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	postgres "github.com/BatuhanSerin/postgresql/common/db"
+	"github.com/BatuhanSerin/postgresql/domain/importer"
+)
+
+const importUsage = "usage: import books|authors [-format csv|json|ndjson] [-mode insert|upsert|replace] [-dry-run] [-user name] <file>"
+
+//runImport runs the import command: go run . import books|authors [flags] <file>,
+//the report is printed as json and rejected rows make the command fail
+func runImport(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(importUsage)
+	}
+	kind := args[0]
+
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "csv, json or ndjson, taken from the file extension when empty")
+	mode := flags.String("mode", importer.Insert, "insert, upsert or replace")
+	dryRun := flags.Bool("dry-run", false, "validate and report without writing anything")
+	user := flags.String("user", "import", "user recorded on the stock movements")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return errors.New(importUsage)
+	}
+	path := flags.Arg(0)
+
+	options := importer.Options{Format: *format, Mode: *mode, DryRun: *dryRun, User: *user}
+	if options.Format == "" {
+		options.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+
+	db, err := postgres.Open(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("Database cannot init %v", err)
+	}
+	i := importer.NewImporter(db)
+
+	var run func(io.Reader, importer.Options) (*importer.Report, error)
+	switch kind {
+	case "books":
+		run = i.ImportBooks
+	case "authors":
+		run = i.ImportAuthors
+	default:
+		return errors.New(importUsage)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	report, err := run(f, options)
+	if report != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return err
+		}
+	}
+	return err
+}
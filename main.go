@@ -1,13 +1,42 @@
 package main
 
 import (
+	"log"
+	"os"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
 	srv "github.com/BatuhanSerin/postgresql/server"
 	//bookStruct "github.com/BatuhanSerin/postgresql/domain/book"
 )
 
 func main() {
-	
-	srv.Server()
+
+	// the config flags come before the command: go run . -server-addr :8091 migrate up
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) > 0 && args[0] == "migrate" {
+		if err := migrate(cfg, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "import" {
+		if err := runImport(cfg, args[1:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(args) > 0 && args[0] == "config" {
+		if err := cfg.Dump(os.Stdout); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	srv.Server(cfg)
 	
 	
 	
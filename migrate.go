@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	postgres "github.com/BatuhanSerin/postgresql/common/db"
+	"github.com/BatuhanSerin/postgresql/common/db/migrations"
+)
+
+const migrateUsage = "usage: migrate up|down|status|to <version>"
+
+//migrate runs the migrate command: go run . migrate up|down|status|to <version>
+func migrate(cfg *config.Config, args []string) error {
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	db, err := postgres.Open(cfg.Database)
+	if err != nil {
+		return fmt.Errorf("Database cannot init %v", err)
+	}
+
+	switch {
+	case args[0] == "up" && len(args) == 1:
+		return migrations.Up(db)
+	case args[0] == "down" && len(args) == 1:
+		return migrations.Down(db)
+	case args[0] == "to" && len(args) == 2:
+		version, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid version %q: %v", args[1], err)
+		}
+		return migrations.To(db, uint(version))
+	case args[0] == "status" && len(args) == 1:
+		statuses, err := migrations.Statuses(db)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, s := range statuses {
+			appliedAt := "pending"
+			if s.Applied {
+				appliedAt = s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, s.Name, appliedAt)
+		}
+		return w.Flush()
+	}
+	return errors.New(migrateUsage)
+}
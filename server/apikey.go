@@ -0,0 +1,84 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/BatuhanSerin/postgresql/domain/apikey"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+)
+
+//APIKeyList returns every api key without its secret
+func (s *Services) APIKeyList(w http.ResponseWriter, r *http.Request) {
+
+	d, err := s.APIKeys.FindAll(r.Context())
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//APIKeyCreate creates an api key, the response is the only time its secret is shown
+func (s *Services) APIKeyCreate(w http.ResponseWriter, r *http.Request) {
+
+	var k apikey.NewKey
+	if err := decodeJSON(r, &k); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	if fields := k.Validate(); fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
+
+	d, err := s.APIKeys.Create(r.Context(), k, requestPrincipal(r).name())
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusCreated, d)
+}
+
+//APIKeyRotate gives an api key a new secret
+func (s *Services) APIKeyRotate(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := s.APIKeys.Rotate(r.Context(), id)
+	if errors.Is(err, apikey.ErrRevoked) {
+		respondWithError(w, r, httpErrors.NewRestError(http.StatusConflict, err.Error(), err))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//APIKeyRevoke disables an api key for good
+func (s *Services) APIKeyRevoke(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := s.APIKeys.Revoke(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
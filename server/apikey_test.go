@@ -0,0 +1,63 @@
+package server_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/BatuhanSerin/postgresql/domain/apikey"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+)
+
+func TestAPIKeyRoutes(t *testing.T) {
+	ts, catalog := newTestServer(t)
+	addBooks(t, catalog, 1, "The Dispossessed")
+
+	// keep puts the secret it is given into current and the one before into old, the requests after it send them
+	current, old := map[string]string{}, map[string]string{}
+	keep := func(t *testing.T, resp *http.Response) {
+		var k apikey.CreatedKey
+		decode(t, resp, &k)
+		if k.Prefix == "" || !strings.HasPrefix(k.Key, k.Prefix) {
+			t.Fatalf("got key %q with prefix %q", k.Key, k.Prefix)
+		}
+		old["X-API-Key"] = current["X-API-Key"]
+		current["X-API-Key"] = k.Key
+	}
+	newKey := map[string]interface{}{"Name": "shop sync", "Scopes": []string{user.BooksRead}}
+	runRouteTests(t, ts, []routeTest{
+		{name: "create as editor", method: http.MethodPost, path: "/apikeys", token: user.Editor, body: newKey,
+			want: http.StatusForbidden},
+		{name: "create with an admin scope", method: http.MethodPost, path: "/apikeys", token: user.Admin,
+			body: map[string]interface{}{"Name": "root", "Scopes": []string{user.APIKeysManage}}, want: http.StatusBadRequest},
+		{name: "create without scopes", method: http.MethodPost, path: "/apikeys", token: user.Admin,
+			body: map[string]interface{}{"Name": "nothing"}, want: http.StatusBadRequest},
+		{name: "create", method: http.MethodPost, path: "/apikeys", token: user.Admin, body: newKey,
+			want: http.StatusCreated, check: keep},
+		{name: "list hides the secret", method: http.MethodGet, path: "/apikeys", token: user.Admin, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var keys []map[string]interface{}
+				decode(t, resp, &keys)
+				if len(keys) != 1 || keys[0]["Key"] != nil || keys[0]["KeyHash"] != nil {
+					t.Errorf("got %v", keys)
+				}
+			}},
+		{name: "read with the key", method: http.MethodGet, path: "/book/1", header: current, want: http.StatusOK},
+		{name: "write with a read key", method: http.MethodPost, path: "/author", header: current,
+			body: map[string]interface{}{"AuthorID": 2, "AuthorName": "Octavia Butler"}, want: http.StatusForbidden},
+		{name: "manage keys with the key", method: http.MethodGet, path: "/apikeys", header: current, want: http.StatusForbidden},
+		{name: "read with an unknown key", method: http.MethodGet, path: "/book/1",
+			header: map[string]string{"X-API-Key": apikey.Prefix + "unknown"}, want: http.StatusUnauthorized},
+		{name: "rotate", method: http.MethodPost, path: "/apikeys/1/rotate", token: user.Admin, want: http.StatusOK,
+			check: keep},
+		{name: "read with the old secret", method: http.MethodGet, path: "/book/1", header: old, want: http.StatusUnauthorized},
+		{name: "read with the rotated secret", method: http.MethodGet, path: "/book/1", header: current, want: http.StatusOK},
+		{name: "rotate unknown", method: http.MethodPost, path: "/apikeys/9/rotate", token: user.Admin, want: http.StatusNotFound},
+		{name: "revoke", method: http.MethodDelete, path: "/apikeys/1", token: user.Admin, want: http.StatusOK},
+		{name: "read with a revoked key", method: http.MethodGet, path: "/book/1", header: current, want: http.StatusUnauthorized},
+		{name: "rotate a revoked key", method: http.MethodPost, path: "/apikeys/1/rotate", token: user.Admin,
+			want: http.StatusConflict},
+		{name: "revoke with a bad id", method: http.MethodDelete, path: "/apikeys/one", token: user.Admin,
+			want: http.StatusBadRequest},
+	})
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/BatuhanSerin/postgresql/domain/audit"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+)
+
+//AuditList returns the audit trail filtered by ?entity=book|author, ?id= and ?actor=, newest first.
+//?limit= bounds the page and ?before=<entry id> returns the page after the last entry of the previous one
+func (s *Services) AuditList(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Entity:   query.Get("entity"),
+		EntityID: query.Get("id"),
+		Actor:    query.Get("actor"),
+		Limit:    audit.DefaultLimit,
+	}
+	fields := map[string]string{}
+
+	switch filter.Entity {
+	case "", audit.Book, audit.Author:
+	default:
+		fields["entity"] = "must be book or author"
+	}
+	if filter.EntityID != "" && filter.Entity == "" {
+		fields["id"] = "needs an entity"
+	}
+	if v := query.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > audit.MaxLimit {
+			fields["limit"] = "must be an integer between 1 and " + strconv.Itoa(audit.MaxLimit)
+		}
+		filter.Limit = l
+	}
+	if v := query.Get("before"); v != "" {
+		before, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || before == 0 {
+			fields["before"] = "must be a positive integer"
+		}
+		filter.BeforeID = uint(before)
+	}
+	if len(fields) > 0 {
+		respondWithError(w, r, httpErrors.NewBadQueryParamsError(fields))
+		return
+	}
+
+	d, err := s.Audit.Find(r.Context(), filter)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
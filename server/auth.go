@@ -0,0 +1,202 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"github.com/BatuhanSerin/postgresql/domain/apikey"
+	"github.com/BatuhanSerin/postgresql/domain/audit"
+	"github.com/BatuhanSerin/postgresql/domain/auth"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+	"gorm.io/gorm"
+)
+
+// principalKey is the request context key of the principal of an authenticated request
+type principalKey struct{}
+
+// publicPaths are served without an access token
+var publicPaths = map[string]bool{
+	"/auth/login":    true,
+	"/auth/refresh":  true,
+	"/auth/register": true,
+	"/healthz":       true,
+	"/readyz":        true,
+}
+
+//AuthRepo returns the auth repository of db that signs the tokens with the configured keys
+func AuthRepo(cfg *config.Config, db *gorm.DB) *auth.AuthRepository {
+	authConfig, err := auth.NewConfig(cfg.JWT)
+	if err != nil {
+		log.Fatal("Authentication cannot init ", err)
+	}
+
+	return auth.NewAuthRepository(db, authConfig)
+}
+
+//Login issues an access and a refresh token for valid credentials
+func (s *Services) Login(w http.ResponseWriter, r *http.Request) {
+
+	var c auth.Credentials
+	if err := decodeJSON(r, &c); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := s.Auth.Login(r.Context(), c)
+	if err != nil {
+		respondWithError(w, r, authError(w, err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//TokenRefresh trades a refresh token for new tokens
+func (s *Services) TokenRefresh(w http.ResponseWriter, r *http.Request) {
+
+	var req auth.RefreshRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := s.Auth.Refresh(r.Context(), req.RefreshToken)
+	if err != nil {
+		respondWithError(w, r, authError(w, err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//Logout revokes a refresh token
+func (s *Services) Logout(w http.ResponseWriter, r *http.Request) {
+
+	var req auth.RefreshRequest
+	if err := decodeJSON(r, &req); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	if err := s.Auth.Logout(r.Context(), req.RefreshToken); err != nil {
+		respondWithError(w, r, authError(w, err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//authenticationMiddleware requires a bearer access token or an api key on every route but the public ones
+//and puts who made the request in the request context, where the audit trail finds it as the actor.
+//A key is sent as X-API-Key or as the bearer token
+func (s *Services) authenticationMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		header := r.Header.Get("Authorization")
+		token := strings.TrimSpace(strings.TrimPrefix(header, "Bearer "))
+		if token == header {
+			token = ""
+		}
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			token = key
+		}
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+			respondWithError(w, r, httpErrors.NewRestError(http.StatusUnauthorized, httpErrors.Unauthorized.Error(), nil))
+			return
+		}
+
+		var p principal
+		if strings.HasPrefix(token, apikey.Prefix) {
+			key, err := s.APIKeys.Authenticate(r.Context(), token)
+			if err != nil {
+				respondWithError(w, r, authError(w, err))
+				return
+			}
+			p.Key = key
+		} else {
+			claims, err := s.Auth.Parse(token, auth.Access)
+			if err != nil {
+				respondWithError(w, r, authError(w, err))
+				return
+			}
+			p.Claims = claims
+		}
+
+		ctx := context.WithValue(r.Context(), principalKey{}, &p)
+		next.ServeHTTP(w, r.WithContext(audit.WithActor(ctx, p.name())))
+	})
+}
+
+//principal is who made a request, a user with the claims of its access token or a machine client with its key
+type principal struct {
+	Claims *auth.Claims
+	Key    *apikey.APIKey
+}
+
+//can reports whether the role of the user or the scopes of the key grant permission
+func (p *principal) can(permission string) bool {
+	if p.Key != nil {
+		return p.Key.HasScope(permission)
+	}
+	return p.Claims != nil && user.HasPermission(p.Claims.Role, permission)
+}
+
+//name identifies the principal in records such as the creator of a key
+func (p *principal) name() string {
+	if p.Key != nil {
+		return "apikey:" + strconv.FormatUint(uint64(p.Key.ID), 10)
+	}
+	return "user:" + p.Claims.Subject
+}
+
+//requestPrincipal returns who made an authenticated request
+func requestPrincipal(r *http.Request) *principal {
+	p, _ := r.Context().Value(principalKey{}).(*principal)
+	return p
+}
+
+//actingUser returns the name a write of the ledger or an order is recorded under, the authenticated caller. A body
+//that names its own user is rejected, so the ledger always agrees with the audit log
+func actingUser(r *http.Request, bodyUser string) (string, error) {
+	if bodyUser != "" {
+		return "", httpErrors.NewValidationError(map[string]string{"User": "must not be set, writes are recorded as the authenticated caller"})
+	}
+	return requestPrincipal(r).name(), nil
+}
+
+//requestClaims returns the claims of a request made with an access token, nil for api keys
+func requestClaims(r *http.Request) *auth.Claims {
+	if p := requestPrincipal(r); p != nil {
+		return p.Claims
+	}
+	return nil
+}
+
+//authError maps the auth errors to 401 responses
+func authError(w http.ResponseWriter, err error) error {
+	switch {
+	case errors.Is(err, auth.ErrInvalidToken):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		return httpErrors.NewRestError(http.StatusUnauthorized, httpErrors.InvalidJWTToken.Error(), err)
+	case errors.Is(err, auth.ErrInvalidClaims):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		return httpErrors.NewRestError(http.StatusUnauthorized, httpErrors.InvalidJWTClaims.Error(), err)
+	case errors.Is(err, apikey.ErrInvalidKey):
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		return httpErrors.NewRestError(http.StatusUnauthorized, err.Error(), err)
+	case errors.Is(err, user.ErrWrongCredentials):
+		return httpErrors.NewRestError(http.StatusUnauthorized, httpErrors.WrongCredentials.Error(), err)
+	}
+	return err
+}
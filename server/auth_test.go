@@ -0,0 +1,81 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/BatuhanSerin/postgresql/domain/auth"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+)
+
+func TestAuthRoutes(t *testing.T) {
+	ts, _ := newTestServer(t)
+
+	// the checks keep the tokens they are given, bearer sends the latest access token and misused the latest refresh
+	// token in its place
+	var first, rotated, again auth.Tokens
+	bearer, misused := map[string]string{}, map[string]string{}
+	keep := func(tokens *auth.Tokens) func(t *testing.T, resp *http.Response) {
+		return func(t *testing.T, resp *http.Response) {
+			decode(t, resp, tokens)
+			if tokens.AccessToken == "" || tokens.RefreshToken == "" || tokens.TokenType != "Bearer" {
+				t.Fatalf("got tokens %+v", tokens)
+			}
+			bearer["Authorization"] = "Bearer " + tokens.AccessToken
+			misused["Authorization"] = "Bearer " + tokens.RefreshToken
+		}
+	}
+	refresh := func(tokens *auth.Tokens) func() interface{} {
+		return func() interface{} { return auth.RefreshRequest{RefreshToken: tokens.RefreshToken} }
+	}
+	credentials := auth.Credentials{Username: "Shevek", Password: "anarres-1974"}
+	runRouteTests(t, ts, []routeTest{
+		{name: "register with a short password", method: http.MethodPost, path: "/auth/register",
+			body: user.Registration{Username: "shevek", Password: "short"}, want: http.StatusBadRequest},
+		{name: "register as admin", method: http.MethodPost, path: "/auth/register",
+			body: user.Registration{Username: credentials.Username, Password: credentials.Password, Role: user.Admin},
+			want: http.StatusCreated,
+			check: func(t *testing.T, resp *http.Response) {
+				var u user.User
+				decode(t, resp, &u)
+				if u.Username != "shevek" || u.Role != user.Reader {
+					t.Errorf("got %q with role %q, want a reader shevek", u.Username, u.Role)
+				}
+			}},
+		{name: "register a taken username", method: http.MethodPost, path: "/auth/register",
+			body: user.Registration{Username: "SHEVEK", Password: credentials.Password}, want: http.StatusConflict},
+		{name: "login with a wrong password", method: http.MethodPost, path: "/auth/login",
+			body: auth.Credentials{Username: "shevek", Password: "urras-1974"}, want: http.StatusUnauthorized},
+		{name: "login as nobody", method: http.MethodPost, path: "/auth/login",
+			body: auth.Credentials{Username: "takver", Password: credentials.Password}, want: http.StatusUnauthorized},
+		{name: "login", method: http.MethodPost, path: "/auth/login", body: credentials, want: http.StatusOK,
+			check: keep(&first)},
+		{name: "me", method: http.MethodGet, path: "/users/me", header: bearer, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var u user.User
+				decode(t, resp, &u)
+				if u.Username != "shevek" {
+					t.Errorf("got %q", u.Username)
+				}
+			}},
+		{name: "list users as reader", method: http.MethodGet, path: "/users", header: bearer, want: http.StatusForbidden},
+		{name: "refresh with the access token", method: http.MethodPost, path: "/auth/refresh",
+			body: func() interface{} { return auth.RefreshRequest{RefreshToken: first.AccessToken} }, want: http.StatusUnauthorized},
+		{name: "me with the refresh token", method: http.MethodGet, path: "/users/me",
+			header: misused, want: http.StatusUnauthorized},
+		{name: "refresh", method: http.MethodPost, path: "/auth/refresh", body: refresh(&first), want: http.StatusOK,
+			check: keep(&rotated)},
+		{name: "me with the rotated access token", method: http.MethodGet, path: "/users/me", header: bearer,
+			want: http.StatusOK},
+		{name: "refresh with a used token", method: http.MethodPost, path: "/auth/refresh", body: refresh(&first),
+			want: http.StatusUnauthorized},
+		{name: "refresh with the rotated token after the reuse", method: http.MethodPost, path: "/auth/refresh",
+			body: refresh(&rotated), want: http.StatusUnauthorized},
+		{name: "login again", method: http.MethodPost, path: "/auth/login", body: credentials, want: http.StatusOK,
+			check: keep(&again)},
+		{name: "logout", method: http.MethodPost, path: "/auth/logout", header: bearer, body: refresh(&again),
+			want: http.StatusNoContent},
+		{name: "refresh after the logout", method: http.MethodPost, path: "/auth/refresh", body: refresh(&again),
+			want: http.StatusUnauthorized},
+	})
+}
@@ -0,0 +1,11 @@
+package server
+
+import (
+	"net/http"
+)
+
+//DatabaseStats returns the statistics of the connection pool every repository shares
+func (s *Services) DatabaseStats(w http.ResponseWriter, r *http.Request) {
+
+	respondWithJSON(w, r, http.StatusOK, s.Pool.Stats())
+}
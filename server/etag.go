@@ -0,0 +1,83 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BatuhanSerin/postgresql/domain/author"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+)
+
+//bookETag is the entity tag of a book, it changes with every write of the book
+func bookETag(b *book.Book) string {
+	return `"` + strconv.FormatUint(uint64(b.Version), 10) + `"`
+}
+
+//authorETag is the entity tag of an author with its books, it changes when the author or any of its books changes
+func authorETag(a *author.Author, books []book.Book) string {
+	versions := make([]string, len(books))
+	for i, b := range books {
+		versions[i] = fmt.Sprintf("%d:%d", b.ID, b.Version)
+	}
+	sort.Strings(versions)
+
+	h := fnv.New64a()
+	h.Write([]byte(strings.Join(versions, ",")))
+	return fmt.Sprintf(`"%d-%x"`, a.Version, h.Sum64())
+}
+
+//notModified sets the ETag of the response and answers 304 when If-None-Match already has it
+func notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	w.Header().Set("ETag", etag)
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, etag, true) {
+		w.WriteHeader(http.StatusNotModified)
+		return true
+	}
+	return false
+}
+
+//checkIfMatch requires an If-Match header with the current ETag of the resource a write changes
+func checkIfMatch(r *http.Request, etag string) error {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return httpErrors.NewRestError(http.StatusPreconditionRequired, httpErrors.PreconditionRequired.Error(), nil)
+	}
+	if !etagMatches(header, etag, false) {
+		return httpErrors.NewRestError(http.StatusPreconditionFailed, httpErrors.PreconditionFailed.Error(), nil)
+	}
+	return nil
+}
+
+//etagMatches reports whether the list of entity tags of a header has etag, weak tags only match in a weak comparison
+func etagMatches(header, etag string, weak bool) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if strings.HasPrefix(tag, "W/") {
+			if !weak {
+				continue
+			}
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+//versionError maps a write based on a stale version to 412
+func versionError(err error) error {
+	if errors.Is(err, book.ErrVersionConflict) || errors.Is(err, author.ErrVersionConflict) {
+		return httpErrors.NewRestError(http.StatusPreconditionFailed, httpErrors.PreconditionFailed.Error(), err)
+	}
+	return err
+}
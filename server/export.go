@@ -0,0 +1,119 @@
+package server
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/BatuhanSerin/postgresql/domain/exporter"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+)
+
+// exportContentTypes maps the export formats to their content types
+var exportContentTypes = map[string]string{
+	exporter.CSV:    "text/csv",
+	exporter.JSON:   "application/json",
+	exporter.NDJSON: "application/x-ndjson",
+	exporter.XLSX:   "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+//ExportBooks streams every book as a file download
+func (s *Services) ExportBooks(w http.ResponseWriter, r *http.Request) {
+	handleExport(w, r, "books", s.Exports.ExportBooks)
+}
+
+//ExportAuthors streams every author as a file download
+func (s *Services) ExportAuthors(w http.ResponseWriter, r *http.Request) {
+	handleExport(w, r, "authors", s.Exports.ExportAuthors)
+}
+
+//handleExport takes the format from ?format=csv|json|ndjson|xlsx or the Accept header, csv by default,
+//and compresses with gzip when ?gzip=true or the client accepts gzip
+func handleExport(w http.ResponseWriter, r *http.Request, name string, run func(context.Context, io.Writer, string) error) {
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		format = acceptedExportFormat(r.Header.Get("Accept"))
+	}
+	if !exporter.ValidFormat(format) {
+		respondWithError(w, r, httpErrors.NewBadQueryParamsError(map[string]string{"format": exporter.ErrUnknownFormat.Error()}))
+		return
+	}
+
+	compress := strings.Contains(r.Header.Get("Accept-Encoding"), "gzip")
+	if v := query.Get("gzip"); v != "" {
+		var err error
+		if compress, err = strconv.ParseBool(v); err != nil {
+			respondWithError(w, r, httpErrors.NewBadQueryParamsError(map[string]string{"gzip": "must be true or false"}))
+			return
+		}
+	}
+	// an xlsx file is already a zip archive
+	compress = compress && format != exporter.XLSX
+
+	header := w.Header()
+	header.Set("Content-Type", exportContentTypes[format])
+	header.Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + "." + format}))
+	header.Add("Vary", "Accept")
+	header.Add("Vary", "Accept-Encoding")
+
+	out := &exportWriter{w: w}
+	var body io.Writer = out
+	var gz *gzip.Writer
+	if compress {
+		header.Set("Content-Encoding", "gzip")
+		gz = gzip.NewWriter(out)
+		body = gz
+	}
+
+	err := run(r.Context(), body, format)
+	if err == nil && gz != nil {
+		err = gz.Close()
+	}
+	if err == nil {
+		return
+	}
+	if !out.written {
+		header.Del("Content-Disposition")
+		header.Del("Content-Encoding")
+		respondWithError(w, r, err)
+		return
+	}
+	// the status is already sent, the client sees a truncated file
+	log.Printf("Export of %s failed: %v", name, err)
+}
+
+//acceptedExportFormat returns the first export format the Accept header names
+func acceptedExportFormat(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		for format, contentType := range exportContentTypes {
+			if mediaType == contentType {
+				return format
+			}
+		}
+	}
+	return exporter.CSV
+}
+
+//exportWriter remembers whether the response has started
+type exportWriter struct {
+	w       io.Writer
+	written bool
+}
+
+func (e *exportWriter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		e.written = true
+	}
+	return e.w.Write(p)
+}
@@ -0,0 +1,68 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"github.com/BatuhanSerin/postgresql/domain/exporter"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+	"github.com/BatuhanSerin/postgresql/server"
+)
+
+// pacedExport writes rows csv lines, after the first half it waits until the client has read the first line, which
+// never happens when the response is buffered
+type pacedExport struct {
+	exporter.ExportStore
+	rows     int
+	received chan struct{}
+}
+
+func (p pacedExport) ExportBooks(ctx context.Context, w io.Writer, format string) error {
+	for i := 0; i < p.rows; i++ {
+		if i == p.rows/2 {
+			select {
+			case <-p.received:
+			case <-time.After(5 * time.Second):
+				return errors.New("the client got nothing of the first half of the export")
+			}
+		}
+		if _, err := fmt.Fprintf(w, "%d,Streamed Book %d,100,1,9.90,SB-%d,9780000000002,1\n", i, i, i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestExportIsStreamedPastTheWriteTimeout(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.WriteTimeout = 100 * time.Millisecond
+	export := pacedExport{rows: 100000, received: make(chan struct{})}
+	ts := startServer(t, &cfg, &server.Services{Auth: fakeAuth{}, Exports: export})
+
+	resp := do(t, http.MethodGet, ts.URL+"/export/books?format=csv&gzip=false", user.Admin, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /export/books: got %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	lines := bufio.NewScanner(resp.Body)
+	if !lines.Scan() {
+		t.Fatalf("GET /export/books: no first row: %v", lines.Err())
+	}
+	// the export is now waiting for us, outlast the write timeout before it goes on
+	time.Sleep(2 * cfg.Server.WriteTimeout)
+	close(export.received)
+
+	rows := 1
+	for lines.Scan() {
+		rows++
+	}
+	if err := lines.Err(); err != nil || rows != export.rows {
+		t.Errorf("GET /export/books: got %d of %d rows: %v", rows, export.rows, err)
+	}
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/db/migrations"
+)
+
+// Statuses of the health checks
+const (
+	healthOK   = "ok"
+	healthFail = "fail"
+)
+
+// draining is set once ShutdownServer starts draining connections, the readiness probe fails from then on
+var draining int32
+
+//healthCheck is the outcome of one dependency check
+type healthCheck struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Latency string `json:"latency"`
+	Error   string `json:"error,omitempty"`
+}
+
+//healthReport is the body of the health probes, Status fails when any check fails
+type healthReport struct {
+	Status string        `json:"status"`
+	Checks []healthCheck `json:"checks,omitempty"`
+}
+
+//Healthz answers as long as the process serves requests, it checks no dependency
+func Healthz(w http.ResponseWriter, r *http.Request) {
+
+	respondWithJSON(w, r, http.StatusOK, healthReport{Status: healthOK})
+}
+
+//Readyz checks that the database answers, that its migrations are at the version of this build and that the server
+//is not shutting down. Every check is run and reported with its latency, any failed check answers 503
+func (s *Services) Readyz(w http.ResponseWriter, r *http.Request) {
+
+	ctx := r.Context()
+	report := healthReport{Status: healthOK, Checks: []healthCheck{
+		runHealthCheck(r, "database", "database unreachable", func() error { return s.Health.Ping(ctx) }),
+		runHealthCheck(r, "migrations", "database is not migrated to this build", func() error {
+			version, err := s.Health.MigrationVersion(ctx)
+			if err != nil {
+				return err
+			}
+			if version != migrations.Latest() {
+				return fmt.Errorf("database is at version %d, expected %d", version, migrations.Latest())
+			}
+			return nil
+		}),
+		runHealthCheck(r, "shutdown", "server is shutting down", func() error {
+			if atomic.LoadInt32(&draining) == 1 {
+				return errors.New("server is shutting down")
+			}
+			return nil
+		}),
+	}}
+
+	status := http.StatusOK
+	for _, check := range report.Checks {
+		if check.Status != healthOK {
+			report.Status = healthFail
+			status = http.StatusServiceUnavailable
+		}
+	}
+	// probes only read the status and the body, they must never be cached
+	w.Header().Set("Cache-Control", "no-store")
+	respondWithJSON(w, r, status, report)
+}
+
+//runHealthCheck runs check and times it. The probes are public, so a failed check is reported with failure and its
+//error, which may name hosts, users or certificates of the database, is only logged
+func runHealthCheck(r *http.Request, name, failure string, check func() error) healthCheck {
+	start := time.Now()
+	err := check()
+	result := healthCheck{Name: name, Status: healthOK, Latency: time.Since(start).String()}
+	if err != nil {
+		log.Printf("%s health check %s failed: %v", requestID(r), name, err)
+		result.Status = healthFail
+		result.Error = failure
+	}
+	return result
+}
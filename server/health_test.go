@@ -0,0 +1,89 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"github.com/BatuhanSerin/postgresql/common/db/migrations"
+	"github.com/BatuhanSerin/postgresql/domain/health"
+)
+
+// brokenHealth is a database that fails its pings with ping or is migrated to version
+type brokenHealth struct {
+	ping    error
+	version uint
+}
+
+func (b brokenHealth) Ping(ctx context.Context) error {
+	return b.ping
+}
+
+func (b brokenHealth) MigrationVersion(ctx context.Context) (uint, error) {
+	return b.version, b.ping
+}
+
+func TestReadyz(t *testing.T) {
+	secret := "dial tcp db.internal:5432: password authentication failed for user books"
+	tests := []struct {
+		name   string
+		health health.HealthStore
+		want   int
+		// failed are the checks that fail with their generic error
+		failed map[string]string
+	}{
+		{name: "memory stores", health: nil, want: http.StatusOK},
+		{name: "database down", health: brokenHealth{ping: errors.New(secret)}, want: http.StatusServiceUnavailable,
+			failed: map[string]string{"database": "database unreachable", "migrations": "database is not migrated to this build"}},
+		{name: "database behind this build", health: brokenHealth{version: migrations.Latest() - 1},
+			want: http.StatusServiceUnavailable, failed: map[string]string{"migrations": "database is not migrated to this build"}},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := memoryServices(t)
+			if tt.health != nil {
+				s.Health = tt.health
+			}
+			cfg := config.Default()
+			ts := startServer(t, &cfg, s)
+
+			resp := do(t, http.MethodGet, ts.URL+"/readyz", "", nil)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("GET /readyz: got %d, want %d", resp.StatusCode, tt.want)
+			}
+			if cc := resp.Header.Get("Cache-Control"); cc != "no-store" {
+				t.Errorf("got Cache-Control %q", cc)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(body), "db.internal") {
+				t.Errorf("readyz shows the database error: %s", body)
+			}
+
+			var report struct {
+				Status string
+				Checks []struct{ Name, Status, Error string }
+			}
+			if err := json.Unmarshal(body, &report); err != nil {
+				t.Fatal(err)
+			}
+			if (report.Status == "ok") != (tt.want == http.StatusOK) {
+				t.Errorf("got status %q", report.Status)
+			}
+			for _, check := range report.Checks {
+				failure, failed := tt.failed[check.Name]
+				if (check.Status == "fail") != failed || check.Error != failure {
+					t.Errorf("check %s: got %s %q, want failed %v %q", check.Name, check.Status, check.Error, failed, failure)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package http_errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/jackc/pgconn"
+)
+
+// Postgres error codes, https://www.postgresql.org/docs/current/errcodes-appendix.html
+const (
+	pgNotNullViolation     = "23502"
+	pgForeignKeyViolation  = "23503"
+	pgUniqueViolation      = "23505"
+	pgCheckViolation       = "23514"
+	pgSerializationFailure = "40001"
+	pgDeadlockDetected     = "40P01"
+	pgLockNotAvailable     = "55P03"
+	pgQueryCanceled        = "57014"
+	pgDataExceptionClass   = "22"
+)
+
+var (
+	UniqueViolation      = errors.New("Already exists")
+	ForeignKeyViolation  = errors.New("Referenced row does not exist or is still referenced")
+	CheckViolation       = errors.New("Value is not allowed")
+	NotNullViolation     = errors.New("Value is required")
+	SerializationFailure = errors.New("Conflicting concurrent change, retry the request")
+	Deadlock             = errors.New("Deadlock with a concurrent change, retry the request")
+	DatabaseBusy         = errors.New("Database is busy, retry the request")
+	StatementTimeout     = errors.New("Database statement timed out")
+	InvalidValue         = errors.New("Invalid value")
+)
+
+// tableEntities names the entity stored in each table
+var tableEntities = map[string]string{
+	"books":              "Book",
+	"authors":            "Author",
+	"stock_movements":    "Stock movement",
+	"stock_reservations": "Stock reservation",
+	"orders":             "Order",
+	"order_items":        "Order item",
+	"order_transitions":  "Order transition",
+	"users":              "User",
+	"api_keys":           "API key",
+	"refresh_tokens":     "Refresh token",
+}
+
+// keyColumns finds the columns in the detail of a constraint violation, `Key (isbn)=(123) already exists.`
+var keyColumns = regexp.MustCompile(`^Key \(([^)]+)\)`)
+
+// parsePgError classifies a postgres error by its code, the message names the entity and constraint involved.
+// The table of a foreign key violation is the referencing table, whether a reference is missing or a deleted row is
+// still referenced only shows in the detail. Sqlite errors reach here translated to the same codes by common/db
+func parsePgError(err *pgconn.PgError) RestErr {
+	entity := entityName(err.TableName)
+	columns := err.ColumnName
+	if m := keyColumns.FindStringSubmatch(err.Detail); m != nil {
+		columns = m[1]
+	}
+
+	switch {
+	case err.Code == pgUniqueViolation:
+		return newDbError(http.StatusConflict, UniqueViolation,
+			fmt.Sprintf("%s with this %s already exists (%s)", entity, columns, err.ConstraintName), err)
+	case err.Code == pgForeignKeyViolation && strings.Contains(err.Detail, "is still referenced"):
+		return newDbError(http.StatusConflict, ForeignKeyViolation,
+			fmt.Sprintf("Row is still referenced by a %s (%s)", entity, err.ConstraintName), err)
+	case err.Code == pgForeignKeyViolation && err.TableName == "":
+		// sqlite names neither the table nor the constraint
+		return newDbError(http.StatusUnprocessableEntity, ForeignKeyViolation, ForeignKeyViolation.Error(), err)
+	case err.Code == pgForeignKeyViolation:
+		return newDbError(http.StatusUnprocessableEntity, ForeignKeyViolation,
+			fmt.Sprintf("%s refers to a missing %s (%s)", entity, columns, err.ConstraintName), err)
+	case err.Code == pgCheckViolation:
+		return newDbError(http.StatusUnprocessableEntity, CheckViolation,
+			fmt.Sprintf("%s violates %s", entity, err.ConstraintName), err)
+	case err.Code == pgNotNullViolation:
+		return newDbError(http.StatusBadRequest, NotNullViolation,
+			fmt.Sprintf("%s needs a %s", entity, err.ColumnName), err)
+	case err.Code == pgSerializationFailure:
+		return newDbError(http.StatusConflict, SerializationFailure, SerializationFailure.Error(), err)
+	case err.Code == pgDeadlockDetected:
+		return newDbError(http.StatusServiceUnavailable, Deadlock, Deadlock.Error(), err)
+	case err.Code == pgLockNotAvailable:
+		return newDbError(http.StatusServiceUnavailable, DatabaseBusy, DatabaseBusy.Error(), err)
+	case err.Code == pgQueryCanceled:
+		return newDbError(http.StatusGatewayTimeout, StatementTimeout, StatementTimeout.Error(), err)
+	case strings.HasPrefix(err.Code, pgDataExceptionClass):
+		return newDbError(http.StatusBadRequest, InvalidValue, fmt.Sprintf("%s: %s", InvalidValue, err.Message), err)
+	}
+	return NewInternalServerError(err)
+}
+
+// newDbError returns a RestError whose message names the constraint, kind is kept in the causes for errors.Is
+func newDbError(status int, kind error, message string, err *pgconn.PgError) RestErr {
+	return RestError{
+		ErrStatus: status,
+		ErrError:  message,
+		ErrCauses: fmt.Errorf("%w: %v", kind, err),
+	}
+}
+
+func entityName(table string) string {
+	if entity, ok := tableEntities[table]; ok {
+		return entity
+	}
+	if table == "" {
+		return "Row"
+	}
+	return table
+}
@@ -3,10 +3,16 @@ package http_errors
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
+
+	"github.com/jackc/pgconn"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 var (
@@ -26,23 +32,31 @@ var (
 	BadQueryParams        = errors.New("Invalid query params")
 	InternalServerError   = errors.New("Internal Server Error")
 	RequestTimeoutError   = errors.New("Request Timeout")
+	ClientClosedRequest   = errors.New("Client closed the request")
 	ExistsUserIDError     = errors.New("User with given id already exists")
 	InvalidJWTToken       = errors.New("Invalid JWT token")
 	InvalidJWTClaims      = errors.New("Invalid JWT claims")
 	NotAllowedImageHeader = errors.New("Not allowed image header")
 	NotAllowedVideoHeader = errors.New("Not allowed video header")
 	MissingFields         = errors.New("Missing fields")
+	InvalidFields         = errors.New("Invalid fields")
+	PreconditionFailed    = errors.New("Resource has been changed, fetch it again and retry with its new ETag")
+	PreconditionRequired  = errors.New("If-Match header with the ETag of the resource is required")
 )
 
+// StatusClientClosedRequest is the status of a request whose client went away before it was answered, nginx uses it too
+const StatusClientClosedRequest = 499
+
 type RestErr interface {
 	Status() int
 	Error() string
 }
 
 type RestError struct {
-	ErrStatus int         `json:"code,omitempty"`
-	ErrError  string      `json:"message,omitempty"`
-	ErrCauses interface{} `json:"-"`
+	ErrStatus int               `json:"code,omitempty"`
+	ErrError  string            `json:"message,omitempty"`
+	ErrFields map[string]string `json:"fields,omitempty"`
+	ErrCauses interface{}       `json:"-"`
 }
 
 // Error  Error() interface method
@@ -54,6 +68,12 @@ func (e RestError) Status() int {
 	return e.ErrStatus
 }
 
+// Unwrap returns the cause of the error when it is an error
+func (e RestError) Unwrap() error {
+	err, _ := e.ErrCauses.(error)
+	return err
+}
+
 func NewRestError(status int, err string, causes interface{}) RestErr {
 	return RestError{
 		ErrStatus: status,
@@ -71,47 +91,121 @@ func NewInternalServerError(causes interface{}) RestErr {
 	return result
 }
 
-// ParseErrors Parser of error string messages returns RestError
+// NewValidationError returns a bad request error that reports every rejected field
+func NewValidationError(fields map[string]string) RestErr {
+	return RestError{
+		ErrStatus: http.StatusBadRequest,
+		ErrError:  InvalidFields.Error(),
+		ErrFields: fields,
+	}
+}
+
+// NewBadQueryParamsError returns a bad request error that reports every rejected query parameter
+func NewBadQueryParamsError(params map[string]string) RestErr {
+	return RestError{
+		ErrStatus: http.StatusBadRequest,
+		ErrError:  BadQueryParams.Error(),
+		ErrFields: params,
+	}
+}
+
+// ParseErrors Parser of errors returns RestError, database errors are classified by their postgres code
 func ParseErrors(err error) RestErr {
+	var restErr RestErr
+	var pgErr *pgconn.PgError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+
 	switch {
-	case errors.Is(err, sql.ErrNoRows):
+	case errors.As(err, &restErr):
+		return restErr
+	case errors.Is(err, sql.ErrNoRows), errors.Is(err, gorm.ErrRecordNotFound):
 		return NewRestError(http.StatusNotFound, NotFound.Error(), err)
 	case errors.Is(err, context.DeadlineExceeded):
 		return NewRestError(http.StatusRequestTimeout, RequestTimeoutError.Error(), err)
+	case errors.Is(err, context.Canceled):
+		return NewRestError(StatusClientClosedRequest, ClientClosedRequest.Error(), err)
+	case errors.As(err, &pgErr):
+		return parsePgError(pgErr)
 	case errors.Is(err, NotAllowedImageHeader):
 		return NewRestError(http.StatusBadRequest, NotAllowedImageHeader.Error(), err)
 	case errors.Is(err, NotAllowedVideoHeader):
 		return NewRestError(http.StatusBadRequest, NotAllowedVideoHeader.Error(), err)
-	case strings.Contains(err.Error(), "SQLSTATE"):
-		return parseSqlErrors(err)
-	case strings.Contains(err.Error(), "Unmarshal"):
+	case errors.As(err, &syntaxErr), errors.As(err, &typeErr):
 		return NewRestError(http.StatusBadRequest, BadRequest.Error(), err)
-	case strings.Contains(err.Error(), "is required"):
+	case errors.Is(err, MissingFields):
 		return NewRestError(http.StatusBadRequest, MissingFields.Error(), err)
-	case strings.Contains(err.Error(), "UUID"):
-		return NewRestError(http.StatusBadRequest, err.Error(), err)
-	case strings.Contains(strings.ToLower(err.Error()), "cookie"):
+	case errors.Is(err, http.ErrNoCookie):
 		return NewRestError(http.StatusUnauthorized, Unauthorized.Error(), err)
-	case strings.Contains(strings.ToLower(err.Error()), "token"):
+	case errors.Is(err, InvalidJWTToken), errors.Is(err, InvalidJWTClaims):
 		return NewRestError(http.StatusUnauthorized, Unauthorized.Error(), err)
-	case strings.Contains(strings.ToLower(err.Error()), "bcrypt"):
-		return NewRestError(http.StatusBadRequest, BadRequest.Error(), err)
-	default:
-		if restErr, ok := err.(RestErr); ok {
-			return restErr
-		}
-		return NewInternalServerError(err)
+	case errors.Is(err, bcrypt.ErrMismatchedHashAndPassword):
+		return NewRestError(http.StatusUnauthorized, WrongCredentials.Error(), err)
 	}
+	return NewInternalServerError(err)
 }
 
-func parseSqlErrors(err error) RestErr {
-	if strings.Contains(err.Error(), "23505") {
-		return NewRestError(http.StatusBadRequest, ExistsUserIDError.Error(), err)
-	}
+func ErrorResponse(err error) (int, interface{}) {
+	return ParseErrors(err).Status(), ParseErrors(err)
+}
+
+// ProblemContentType is the content type of error responses
+const ProblemContentType = "application/problem+json"
 
-	return NewRestError(http.StatusBadRequest, BadRequest.Error(), err)
+// problemTypeBase prefixes the type URI of every problem, the rest of the URI names the status
+const problemTypeBase = "/problems/"
+
+// InvalidParam is a rejected field or query parameter of a problem
+type InvalidParam struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
 }
 
-func ErrorResponse(err error) (int, interface{}) {
-	return ParseErrors(err).Status(), ParseErrors(err)
+// Problem is an RFC 7807 problem details body
+type Problem struct {
+	Type          string         `json:"type"`
+	Title         string         `json:"title"`
+	Status        int            `json:"status"`
+	Detail        string         `json:"detail,omitempty"`
+	Instance      string         `json:"instance,omitempty"`
+	RequestID     string         `json:"request_id,omitempty"`
+	InvalidParams []InvalidParam `json:"invalid_params,omitempty"`
+}
+
+// StatusText is http.StatusText that knows StatusClientClosedRequest
+func StatusText(status int) string {
+	if status == StatusClientClosedRequest {
+		return "Client Closed Request"
+	}
+	return http.StatusText(status)
+}
+
+// NewProblem parses err with ParseErrors and describes it as a problem of the request path instance
+func NewProblem(err error, instance, requestID string) Problem {
+	restErr := ParseErrors(err)
+	status := restErr.Status()
+	title := StatusText(status)
+	if title == "" {
+		status = http.StatusInternalServerError
+		title = http.StatusText(status)
+	}
+
+	p := Problem{
+		Type:      problemTypeBase + strings.ToLower(strings.ReplaceAll(title, " ", "-")),
+		Title:     title,
+		Status:    status,
+		Instance:  instance,
+		RequestID: requestID,
+	}
+	if r, ok := restErr.(RestError); ok {
+		p.Detail = r.ErrError
+		for name, reason := range r.ErrFields {
+			p.InvalidParams = append(p.InvalidParams, InvalidParam{Name: name, Reason: reason})
+		}
+		sort.Slice(p.InvalidParams, func(i, j int) bool { return p.InvalidParams[i].Name < p.InvalidParams[j].Name })
+	}
+	if len(p.InvalidParams) > 0 {
+		p.Type = problemTypeBase + "validation-error"
+	}
+	return p
 }
@@ -0,0 +1,162 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/idempotency"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+)
+
+// idempotencyPurgeInterval is how often expired keys are deleted
+const idempotencyPurgeInterval = time.Hour
+
+// abandonMargin is how long past the longest route timeout the key of a request that never completed stays claimed,
+// time for its handler to notice the deadline and for the response to be stored
+const abandonMargin = time.Minute
+
+// idempotencyExcluded are the path prefixes whose responses carry secrets and are never stored
+var idempotencyExcluded = []string{"/auth/", "/apikeys"}
+
+// replayedHeaders are the response headers stored and replayed with the body
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+//idempotencyMiddleware makes a POST with an Idempotency-Key run once per principal and key within ttl, a retry gets
+//the stored response again. The key is bound to the method, path, query and body of the first request, reusing it for
+//another request is 422 and retrying while the first request still runs is 409. Server errors are not stored.
+//A key is claimed again after abandonAfter when its request never completed, because the server stopped
+func (s *Services) idempotencyMiddleware(ttl, abandonAfter time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			p := requestPrincipal(r)
+			if r.Method != http.MethodPost || key == "" || p == nil || isIdempotencyExcluded(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > idempotency.MaxKeyLength {
+				respondWithError(w, r, httpErrors.NewValidationError(map[string]string{
+					"Idempotency-Key": "must be at most " + strconv.Itoa(idempotency.MaxKeyLength) + " characters",
+				}))
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxImportSize))
+			if err != nil {
+				respondWithError(w, r, httpErrors.NewRestError(http.StatusRequestEntityTooLarge, err.Error(), err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			principal := p.name()
+			stored, err := s.Idempotency.Begin(r.Context(), principal, key, fingerprint(r, body), ttl, abandonAfter)
+			switch {
+			case errors.Is(err, idempotency.ErrKeyReused):
+				respondWithError(w, r, httpErrors.NewRestError(http.StatusUnprocessableEntity, err.Error(), err))
+				return
+			case errors.Is(err, idempotency.ErrInProgress):
+				w.Header().Set("Retry-After", "1")
+				respondWithError(w, r, httpErrors.NewRestError(http.StatusConflict, err.Error(), err))
+				return
+			case err != nil:
+				respondWithError(w, r, err)
+				return
+			case stored != nil:
+				for name, value := range stored.Headers {
+					w.Header().Set(name, value)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(stored.Status)
+				w.Write(stored.Body)
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w, status: http.StatusOK}
+			defer func() {
+				if v := recover(); v != nil {
+					s.Idempotency.Release(context.Background(), principal, key)
+					panic(v)
+				}
+			}()
+			next.ServeHTTP(rec, r)
+
+			// the key is stored without the request context, which may be done already. A request that timed out
+			// or whose client went away is not stored either, so that the retry runs again
+			if rec.status >= http.StatusInternalServerError || r.Context().Err() != nil {
+				err = s.Idempotency.Release(context.Background(), principal, key)
+			} else {
+				headers := idempotency.Headers{}
+				for _, name := range replayedHeaders {
+					if value := w.Header().Get(name); value != "" {
+						headers[name] = value
+					}
+				}
+				err = s.Idempotency.Complete(context.Background(), principal, key, rec.status, headers, rec.body.Bytes())
+			}
+			if err != nil {
+				log.Printf("%s idempotency key %q cannot be stored: %v", requestID(r), key, err)
+			}
+		})
+	}
+}
+
+//recordingWriter passes a response through and keeps a copy of its status and body
+type recordingWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if !rw.wroteHeader {
+		rw.status = status
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	rw.body.Write(p)
+	return rw.ResponseWriter.Write(p)
+}
+
+//fingerprint identifies a request by its method, path, query, content type and body
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	for _, part := range []string{r.Method, r.URL.Path, r.URL.RawQuery, r.Header.Get("Content-Type")} {
+		io.WriteString(h, part)
+		h.Write([]byte{0})
+	}
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func isIdempotencyExcluded(path string) bool {
+	for _, prefix := range idempotencyExcluded {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+//purgeIdempotencyKeys deletes the expired keys every idempotencyPurgeInterval until the server stops
+func purgeIdempotencyKeys(keys idempotency.IdempotencyStore) {
+	for {
+		if _, err := keys.Purge(context.Background(), time.Now()); err != nil {
+			log.Println("Idempotency keys cannot be purged ", err)
+		}
+		time.Sleep(idempotencyPurgeInterval)
+	}
+}
@@ -0,0 +1,96 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/idempotency"
+	"github.com/BatuhanSerin/postgresql/domain/order"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+)
+
+// heldOrders holds the first checkout until release is closed, started is closed once it is held
+type heldOrders struct {
+	order.OrderStore
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h heldOrders) Checkout(ctx context.Context, cart order.Cart) (*order.Order, error) {
+	select {
+	case <-h.started:
+	default:
+		close(h.started)
+		<-h.release
+	}
+	return h.OrderStore.Checkout(ctx, cart)
+}
+
+func TestIdempotencyReplayAndConflict(t *testing.T) {
+	s, catalog := memoryServices(t)
+	orders := heldOrders{OrderStore: s.Orders, started: make(chan struct{}), release: make(chan struct{})}
+	s.Orders = orders
+	cfg := config.Default()
+	ts := startServer(t, &cfg, s)
+	addBooks(t, catalog, 5, "The Dispossessed")
+
+	key := map[string]string{"Idempotency-Key": "checkout-1"}
+	cart := map[string]interface{}{"CustomerID": "c-1", "Items": []order.CartItem{{BookID: 1, Quantity: 1}}}
+	first := make(chan *http.Response)
+	go func() {
+		first <- send(t, http.MethodPost, ts.URL+"/orders", user.Clerk, key, cart)
+	}()
+	<-orders.started
+
+	retryAfter := func(t *testing.T, resp *http.Response) {
+		if resp.Header.Get("Retry-After") == "" {
+			t.Error("no Retry-After")
+		}
+	}
+	runRouteTests(t, ts, []routeTest{
+		{name: "retry while the first runs", method: http.MethodPost, path: "/orders", token: user.Clerk, header: key,
+			body: cart, want: http.StatusConflict, check: retryAfter},
+		{name: "reuse the key for another cart", method: http.MethodPost, path: "/orders", token: user.Clerk, header: key,
+			body: map[string]interface{}{"CustomerID": "c-2", "Items": []order.CartItem{{BookID: 1, Quantity: 1}}},
+			want: http.StatusUnprocessableEntity},
+		{name: "key longer than allowed", method: http.MethodPost, path: "/orders", token: user.Clerk,
+			header: map[string]string{"Idempotency-Key": strings.Repeat("k", idempotency.MaxKeyLength+1)}, body: cart, want: http.StatusBadRequest},
+	})
+
+	close(orders.release)
+	if resp := <-first; resp.StatusCode != http.StatusCreated {
+		t.Fatalf("first checkout: got %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+
+	runRouteTests(t, ts, []routeTest{
+		{name: "retry after the first completed", method: http.MethodPost, path: "/orders", token: user.Clerk, header: key,
+			body: cart, want: http.StatusCreated,
+			check: func(t *testing.T, resp *http.Response) {
+				if resp.Header.Get("Idempotent-Replayed") != "true" {
+					t.Error("the retry is not a replay")
+				}
+				var o order.Order
+				decode(t, resp, &o)
+				if o.ID != 1 {
+					t.Errorf("got order %d, want the first order", o.ID)
+				}
+			}},
+		{name: "reuse the key after the first completed", method: http.MethodPost, path: "/orders", token: user.Clerk,
+			header: key, body: map[string]interface{}{"CustomerID": "c-2", "Items": []order.CartItem{{BookID: 1, Quantity: 1}}},
+			want: http.StatusUnprocessableEntity},
+		{name: "stock is taken once", method: http.MethodGet, path: "/book/1", token: user.Reader, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var b book.Book
+				decode(t, resp, &b)
+				if b.Stock != 4 {
+					t.Errorf("got stock %d, want 4", b.Stock)
+				}
+			}},
+		{name: "another key checks out again", method: http.MethodPost, path: "/orders", token: user.Clerk,
+			header: map[string]string{"Idempotency-Key": "checkout-2"}, body: cart, want: http.StatusCreated},
+	})
+}
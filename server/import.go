@@ -0,0 +1,98 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/BatuhanSerin/postgresql/domain/importer"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+)
+
+// maxImportSize limits the size of an uploaded import file
+const maxImportSize = 32 << 20
+
+//ImportBooks imports books from the request body or a multipart "file" field
+func (s *Services) ImportBooks(w http.ResponseWriter, r *http.Request) {
+	handleImport(w, r, s.Imports.ImportBooks)
+}
+
+//ImportAuthors imports authors from the request body or a multipart "file" field
+func (s *Services) ImportAuthors(w http.ResponseWriter, r *http.Request) {
+	handleImport(w, r, s.Imports.ImportAuthors)
+}
+
+//handleImport reads ?format=csv|json|ndjson (or the content type), ?mode=insert|upsert|replace and ?dry_run=true,
+//a report with rejected rows is returned as 422 and nothing is imported
+func handleImport(w http.ResponseWriter, r *http.Request, run func(context.Context, io.Reader, importer.Options) (*importer.Report, error)) {
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxImportSize)
+	query := r.URL.Query()
+	options := importer.Options{
+		Format: query.Get("format"),
+		Mode:   query.Get("mode"),
+		User:   query.Get("user"),
+	}
+	if options.Mode == "" {
+		options.Mode = importer.Insert
+	}
+	if options.User == "" {
+		options.User = "import"
+	}
+	if v := query.Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			respondWithError(w, r, httpErrors.NewBadQueryParamsError(map[string]string{"dry_run": "must be true or false"}))
+			return
+		}
+		options.DryRun = dryRun
+	}
+
+	body := io.Reader(r.Body)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "multipart/form-data" {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			respondWithError(w, r, httpErrors.NewRestError(http.StatusBadRequest, httpErrors.BadRequest.Error(), err))
+			return
+		}
+		defer file.Close()
+		body = file
+		if options.Format == "" {
+			options.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	}
+	if options.Format == "" {
+		options.Format = importFormat(mediaType)
+	}
+
+	report, err := run(r.Context(), body, options)
+	switch {
+	case errors.Is(err, importer.ErrRowsRejected):
+		respondWithJSON(w, r, http.StatusUnprocessableEntity, report)
+	case errors.Is(err, importer.ErrUnknownFormat), errors.Is(err, importer.ErrUnknownMode), errors.Is(err, importer.ErrUnreadable):
+		respondWithError(w, r, httpErrors.NewRestError(http.StatusBadRequest, err.Error(), err))
+	case err != nil:
+		respondWithError(w, r, err)
+	default:
+		respondWithJSON(w, r, http.StatusOK, report)
+	}
+}
+
+//importFormat maps a content type to an import format
+func importFormat(mediaType string) string {
+	switch mediaType {
+	case "text/csv":
+		return importer.CSV
+	case "application/json":
+		return importer.JSON
+	case "application/x-ndjson", "application/ndjson":
+		return importer.NDJSON
+	}
+	return ""
+}
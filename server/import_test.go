@@ -0,0 +1,102 @@
+package server_test
+
+import (
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/BatuhanSerin/postgresql/domain/importer"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+)
+
+//reportOf checks the counts of an import report, rows with errors included
+func reportOf(created, updated, skipped, deleted, errors int) func(t *testing.T, resp *http.Response) {
+	return func(t *testing.T, resp *http.Response) {
+		var r importer.Report
+		decode(t, resp, &r)
+		if r.Created != created || r.Updated != updated || r.Skipped != skipped || r.Deleted != deleted || len(r.Errors) != errors {
+			t.Errorf("got %d created, %d updated, %d skipped, %d deleted and errors %v, want %d, %d, %d, %d and %d errors",
+				r.Created, r.Updated, r.Skipped, r.Deleted, r.Errors, created, updated, skipped, deleted, errors)
+		}
+	}
+}
+
+func TestImportExportRoutes(t *testing.T) {
+	ts, _ := newTestServer(t)
+	csv := map[string]string{"Content-Type": "text/csv"}
+	authors := "AuthorID,AuthorName\n1,Ursula Le Guin\n2,Octavia Butler\n"
+	columns := "Name,Page,Stock,Cost,StockCode,ISBN,AuthorID\n"
+	books := columns +
+		"The Dispossessed,387,4,14.90,LG-1974," + isbn13(1) + ",1\n" +
+		"Kindred,264,2,12.50,OB-1979," + isbn13(2) + ",2\n"
+	rejected := books + "Dawn,248,1,11.00,OB-1987,not-an-isbn,2\n"
+	restocked := columns + "Kindred,264,5,12.50,OB-1979," + isbn13(2) + ",2\n"
+
+	// the exports are kept for the imports after them
+	var exportedCSV, exportedJSON string
+	keep := func(export *string, contentType string) func(t *testing.T, resp *http.Response) {
+		return func(t *testing.T, resp *http.Response) {
+			if got := resp.Header.Get("Content-Type"); got != contentType {
+				t.Errorf("got content type %q, want %q", got, contentType)
+			}
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			*export = string(body)
+		}
+	}
+	exported := func(export *string) func() interface{} {
+		return func() interface{} { return *export }
+	}
+	bookCount := func(want int) func(t *testing.T, resp *http.Response) {
+		return func(t *testing.T, resp *http.Response) {
+			var p bookPage
+			decode(t, resp, &p)
+			if p.Total != int64(want) {
+				t.Errorf("got %d books, want %d", p.Total, want)
+			}
+		}
+	}
+	runRouteTests(t, ts, []routeTest{
+		{name: "import as clerk", method: http.MethodPost, path: "/import/authors", token: user.Clerk, header: csv,
+			body: authors, want: http.StatusForbidden},
+		{name: "import an unknown format", method: http.MethodPost, path: "/import/authors?format=xml", token: user.Editor,
+			body: authors, want: http.StatusBadRequest},
+		{name: "import with an unknown mode", method: http.MethodPost, path: "/import/authors?mode=merge", token: user.Editor,
+			header: csv, body: authors, want: http.StatusBadRequest},
+		{name: "import with a bad dry run flag", method: http.MethodPost, path: "/import/authors?dry_run=maybe",
+			token: user.Editor, header: csv, body: authors, want: http.StatusBadRequest},
+		{name: "import authors", method: http.MethodPost, path: "/import/authors", token: user.Editor, header: csv,
+			body: authors, want: http.StatusOK, check: reportOf(2, 0, 0, 0, 0)},
+		{name: "import books as a dry run", method: http.MethodPost, path: "/import/books?format=csv&dry_run=true",
+			token: user.Editor, body: books, want: http.StatusOK, check: reportOf(2, 0, 0, 0, 0)},
+		{name: "the dry run wrote nothing", method: http.MethodGet, path: "/book", token: user.Reader, want: http.StatusOK,
+			check: bookCount(0)},
+		{name: "import books with a rejected row", method: http.MethodPost, path: "/import/books?format=csv",
+			token: user.Editor, body: rejected, want: http.StatusUnprocessableEntity, check: reportOf(2, 0, 0, 0, 1)},
+		{name: "the rejected import wrote nothing", method: http.MethodGet, path: "/book", token: user.Reader,
+			want: http.StatusOK, check: bookCount(0)},
+		{name: "import books", method: http.MethodPost, path: "/import/books?format=csv", token: user.Editor,
+			body: books, want: http.StatusOK, check: reportOf(2, 0, 0, 0, 0)},
+		{name: "import books again", method: http.MethodPost, path: "/import/books?format=csv", token: user.Editor,
+			body: books, want: http.StatusOK, check: reportOf(0, 0, 2, 0, 0)},
+		{name: "export an unknown format", method: http.MethodGet, path: "/export/books?format=pdf", token: user.Editor,
+			want: http.StatusBadRequest},
+		{name: "export as clerk", method: http.MethodGet, path: "/export/books", token: user.Clerk, want: http.StatusForbidden},
+		{name: "export csv", method: http.MethodGet, path: "/export/books?format=csv&gzip=false", token: user.Editor,
+			want: http.StatusOK, check: keep(&exportedCSV, "text/csv")},
+		{name: "export json", method: http.MethodGet, path: "/export/books?format=json&gzip=false", token: user.Editor,
+			want: http.StatusOK, check: keep(&exportedJSON, "application/json")},
+		{name: "upsert the csv export", method: http.MethodPost, path: "/import/books?format=csv&mode=upsert",
+			token: user.Editor, body: exported(&exportedCSV), want: http.StatusOK, check: reportOf(0, 0, 2, 0, 0)},
+		{name: "upsert the json export", method: http.MethodPost, path: "/import/books?format=json&mode=upsert",
+			token: user.Editor, body: exported(&exportedJSON), want: http.StatusOK, check: reportOf(0, 0, 2, 0, 0)},
+		{name: "upsert a changed book", method: http.MethodPost, path: "/import/books?format=csv&mode=upsert",
+			token: user.Editor, body: restocked, want: http.StatusOK, check: reportOf(0, 1, 0, 0, 0)},
+		{name: "replace with one book", method: http.MethodPost, path: "/import/books?format=csv&mode=replace",
+			token: user.Editor, body: restocked, want: http.StatusOK, check: reportOf(0, 0, 1, 1, 0)},
+		{name: "the replace deleted the other book", method: http.MethodGet, path: "/book", token: user.Reader,
+			want: http.StatusOK, check: bookCount(1)},
+	})
+}
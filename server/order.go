@@ -0,0 +1,112 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/order"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+	"github.com/gorilla/mux"
+)
+
+//OrderCheckout prices the cart and takes its stock, the order starts as pending
+func (s *Services) OrderCheckout(w http.ResponseWriter, r *http.Request) {
+
+	var cart order.Cart
+	err := decodeJSON(r, &cart)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	if cart.User, err = actingUser(r, cart.User); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	if fields := cart.Validate(); fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
+
+	d, err := s.Orders.Checkout(r.Context(), cart)
+	if err != nil {
+		respondWithError(w, r, orderError(err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusCreated, d)
+}
+
+//OrderGetById returns an order with its items and transitions
+func (s *Services) OrderGetById(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := s.Orders.GetByID(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//OrderTransition moves an order to the status of the JSON request body in the name of the caller
+func (s *Services) OrderTransition(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	var body struct {
+		Status string
+		Reason string
+	}
+	if err := decodeJSON(r, &body); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if body.Status == "" {
+		respondWithError(w, r, httpErrors.NewValidationError(map[string]string{"Status": "is required"}))
+		return
+	}
+
+	d, err := s.Orders.Transition(r.Context(), id, body.Status, body.Reason, requestPrincipal(r).name())
+	if err != nil {
+		respondWithError(w, r, orderError(err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//CustomerOrders returns the order history of a customer, newest first
+func (s *Services) CustomerOrders(w http.ResponseWriter, r *http.Request) {
+
+	d, err := s.Orders.FindByCustomer(r.Context(), mux.Vars(r)["customerID"])
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//orderError maps order errors to their http errors
+func orderError(err error) error {
+	switch {
+	case errors.Is(err, order.ErrUnknownBook):
+		return httpErrors.NewRestError(http.StatusBadRequest, err.Error(), err)
+	case errors.Is(err, order.ErrInvalidTransition), errors.Is(err, book.ErrInsufficientStock):
+		return httpErrors.NewRestError(http.StatusConflict, err.Error(), err)
+	}
+	return err
+}
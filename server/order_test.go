@@ -0,0 +1,102 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/order"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+)
+
+func TestOrderRoutes(t *testing.T) {
+	ts, catalog := newTestServer(t)
+	addBooks(t, catalog, 5, "The Dispossessed")
+	addBooks(t, catalog, 2, "The Lathe of Heaven")
+
+	cart := func(items ...order.CartItem) map[string]interface{} {
+		return map[string]interface{}{"CustomerID": "c-1", "Items": items}
+	}
+	orderIs := func(status string, transitions int) func(t *testing.T, resp *http.Response) {
+		return func(t *testing.T, resp *http.Response) {
+			var o order.Order
+			decode(t, resp, &o)
+			if o.Status != status || len(o.Transitions) != transitions {
+				t.Fatalf("got order %s with %d transitions, want %s with %d", o.Status, len(o.Transitions), status, transitions)
+			}
+			if last := o.Transitions[transitions-1]; last.User != "user:1" {
+				t.Errorf("transition to %s recorded by %q, want user:1", last.ToStatus, last.User)
+			}
+		}
+	}
+	stockIs := func(trashed bool, id uint, stock int) func(t *testing.T, resp *http.Response) {
+		return func(t *testing.T, resp *http.Response) {
+			var b book.Book
+			if trashed {
+				var trash []book.Book
+				decode(t, resp, &trash)
+				b = trash[0]
+			} else {
+				decode(t, resp, &b)
+			}
+			if b.ID != id || b.Stock != stock || b.Reserved != 0 {
+				t.Errorf("book %d has stock %d with %d reserved, want book %d with %d", b.ID, b.Stock, b.Reserved, id, stock)
+			}
+		}
+	}
+	runRouteTests(t, ts, []routeTest{
+		{name: "checkout as reader", method: http.MethodPost, path: "/orders", token: user.Reader,
+			body: cart(order.CartItem{BookID: 1, Quantity: 1}), want: http.StatusForbidden},
+		{name: "checkout naming a user", method: http.MethodPost, path: "/orders", token: user.Clerk,
+			body: map[string]interface{}{"CustomerID": "c-1", "Items": []order.CartItem{{BookID: 1, Quantity: 1}}, "User": "someone"},
+			want: http.StatusBadRequest},
+		{name: "checkout an empty cart", method: http.MethodPost, path: "/orders", token: user.Clerk,
+			body: cart(), want: http.StatusBadRequest},
+		{name: "checkout an unknown book", method: http.MethodPost, path: "/orders", token: user.Clerk,
+			body: cart(order.CartItem{BookID: 1, Quantity: 1}, order.CartItem{BookID: 99, Quantity: 1}), want: http.StatusBadRequest},
+		{name: "checkout more than in stock", method: http.MethodPost, path: "/orders", token: user.Clerk,
+			body: cart(order.CartItem{BookID: 1, Quantity: 1}, order.CartItem{BookID: 2, Quantity: 3}), want: http.StatusConflict},
+		{name: "checkout", method: http.MethodPost, path: "/orders", token: user.Clerk,
+			body:  cart(order.CartItem{BookID: 1, Quantity: 2}, order.CartItem{BookID: 2, Quantity: 1}, order.CartItem{BookID: 1, Quantity: 1}),
+			want:  http.StatusCreated,
+			check: orderIs(order.Pending, 1)},
+		{name: "stock is taken", method: http.MethodGet, path: "/book/1", token: user.Reader, want: http.StatusOK,
+			check: stockIs(false, 1, 2)},
+		{name: "get", method: http.MethodGet, path: "/orders/1", token: user.Clerk, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var o order.Order
+				decode(t, resp, &o)
+				if len(o.Items) != 2 || o.Total != 4000 {
+					t.Errorf("got %d items for %s, want 2 for 40.00", len(o.Items), o.Total)
+				}
+			}},
+		{name: "get unknown", method: http.MethodGet, path: "/orders/99", token: user.Clerk, want: http.StatusNotFound},
+		{name: "skip a status", method: http.MethodPost, path: "/orders/1/transitions", token: user.Clerk,
+			body: map[string]interface{}{"Status": order.Shipped}, want: http.StatusConflict},
+		{name: "move without a status", method: http.MethodPost, path: "/orders/1/transitions", token: user.Clerk,
+			body: map[string]interface{}{"Reason": "paid"}, want: http.StatusBadRequest},
+		{name: "move naming a user", method: http.MethodPost, path: "/orders/1/transitions", token: user.Clerk,
+			body: map[string]interface{}{"Status": order.Paid, "User": "someone"}, want: http.StatusBadRequest},
+		{name: "pay", method: http.MethodPost, path: "/orders/1/transitions", token: user.Clerk,
+			body: map[string]interface{}{"Status": order.Paid, "Reason": "card"}, want: http.StatusOK,
+			check: orderIs(order.Paid, 2)},
+		{name: "trash a book of the order", method: http.MethodDelete, path: "/book/1", token: user.Editor, want: http.StatusNoContent},
+		{name: "cancel", method: http.MethodPost, path: "/orders/1/transitions", token: user.Clerk,
+			body: map[string]interface{}{"Status": order.Cancelled, "Reason": "changed mind"}, want: http.StatusOK,
+			check: orderIs(order.Cancelled, 3)},
+		{name: "cancel again", method: http.MethodPost, path: "/orders/1/transitions", token: user.Clerk,
+			body: map[string]interface{}{"Status": order.Cancelled}, want: http.StatusConflict},
+		{name: "stock of the trashed book is back", method: http.MethodGet, path: "/book/trash", token: user.Editor,
+			want: http.StatusOK, check: stockIs(true, 1, 5)},
+		{name: "stock of the live book is back", method: http.MethodGet, path: "/book/2", token: user.Reader, want: http.StatusOK,
+			check: stockIs(false, 2, 2)},
+		{name: "order history", method: http.MethodGet, path: "/customers/c-1/orders", token: user.Clerk, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var orders []order.Order
+				decode(t, resp, &orders)
+				if len(orders) != 1 || orders[0].Status != order.Cancelled {
+					t.Errorf("got %d orders, want the cancelled one", len(orders))
+				}
+			}},
+	})
+}
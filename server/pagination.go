@@ -0,0 +1,154 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/BatuhanSerin/postgresql/common/money"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+)
+
+// bookListParams is the allowlist of query parameters of GET /book
+var bookListParams = map[string]bool{
+	"limit":     true,
+	"offset":    true,
+	"cursor":    true,
+	"sort":      true,
+	"author_id": true,
+	"min_stock": true,
+	"max_cost":  true,
+	"isbn":      true,
+}
+
+type page struct {
+	Data       interface{} `json:"data"`
+	Total      int64       `json:"total"`
+	Limit      int         `json:"limit"`
+	Offset     int         `json:"offset"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+	PrevCursor string      `json:"prev_cursor,omitempty"`
+	Links      pageLinks   `json:"links"`
+}
+
+type pageLinks struct {
+	Self string `json:"self"`
+	Next string `json:"next,omitempty"`
+	Prev string `json:"prev,omitempty"`
+}
+
+//parseBookListQuery reads limit, offset or cursor, sort=name,-cost and the author_id, min_stock, max_cost and isbn filters
+func parseBookListQuery(r *http.Request) (book.ListQuery, error) {
+	query := r.URL.Query()
+	q := book.ListQuery{Limit: book.DefaultLimit}
+	invalid := map[string]string{}
+
+	for param := range query {
+		if !bookListParams[param] {
+			invalid[param] = "is not a supported parameter"
+		}
+	}
+
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > book.MaxLimit {
+			invalid["limit"] = "must be an integer between 1 and " + strconv.Itoa(book.MaxLimit)
+		}
+		q.Limit = limit
+	}
+	if v := query.Get("offset"); v != "" {
+		offset, err := strconv.Atoi(v)
+		if err != nil || offset < 0 {
+			invalid["offset"] = "must be a non-negative integer"
+		}
+		q.Offset = offset
+	}
+	q.Cursor = query.Get("cursor")
+	if q.Cursor != "" && query.Get("offset") != "" {
+		invalid["cursor"] = "cannot be used together with offset"
+	}
+	if v := query.Get("sort"); v != "" {
+		sort, err := book.ParseSort(v)
+		if err != nil {
+			invalid["sort"] = err.Error()
+		}
+		q.Sort = sort
+	}
+
+	if v := query.Get("author_id"); v != "" {
+		authorID, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			invalid["author_id"] = "must be a positive integer"
+		}
+		q.Filter.AuthorID = uint(authorID)
+	}
+	if v := query.Get("min_stock"); v != "" {
+		minStock, err := strconv.Atoi(v)
+		if err != nil {
+			invalid["min_stock"] = "must be an integer"
+		}
+		q.Filter.MinStock = &minStock
+	}
+	if v := query.Get("max_cost"); v != "" {
+		maxCost, err := money.Parse(v)
+		if err != nil {
+			invalid["max_cost"] = "must be a decimal amount"
+		}
+		q.Filter.MaxCost = &maxCost
+	}
+	q.Filter.ISBN = query.Get("isbn")
+
+	if len(invalid) > 0 {
+		return q, httpErrors.NewBadQueryParamsError(invalid)
+	}
+	return q, nil
+}
+
+//newBookPage wraps a book list result with its links
+func newBookPage(r *http.Request, q book.ListQuery, result *book.ListResult) page {
+	p := page{
+		Data:       result.Books,
+		Total:      result.Total,
+		Limit:      q.Limit,
+		Offset:     q.Offset,
+		NextCursor: result.NextCursor,
+		PrevCursor: result.PrevCursor,
+		Links:      pageLinks{Self: r.URL.RequestURI()},
+	}
+	if len(result.Books) == 0 {
+		p.Data = []book.Book{}
+	}
+
+	if q.Cursor != "" {
+		if result.NextCursor != "" {
+			p.Links.Next = pageLink(r, url.Values{"cursor": {result.NextCursor}}, "offset")
+		}
+		if result.PrevCursor != "" {
+			p.Links.Prev = pageLink(r, url.Values{"cursor": {result.PrevCursor}}, "offset")
+		}
+		return p
+	}
+
+	if int64(q.Offset+len(result.Books)) < result.Total {
+		p.Links.Next = pageLink(r, url.Values{"offset": {strconv.Itoa(q.Offset + q.Limit)}}, "cursor")
+	}
+	if q.Offset > 0 {
+		prev := q.Offset - q.Limit
+		if prev < 0 {
+			prev = 0
+		}
+		p.Links.Prev = pageLink(r, url.Values{"offset": {strconv.Itoa(prev)}}, "cursor")
+	}
+	return p
+}
+
+//pageLink returns the request path with the given query parameters replaced and drop removed
+func pageLink(r *http.Request, set url.Values, drop string) string {
+	query := r.URL.Query()
+	for key, values := range set {
+		query[key] = values
+	}
+	query.Del(drop)
+	return r.URL.Path + "?" + query.Encode()
+}
@@ -0,0 +1,112 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+)
+
+//bookPage is a page of GET /book as a client reads it
+type bookPage struct {
+	Data       []book.Book
+	Total      int64
+	NextCursor string `json:"next_cursor"`
+	PrevCursor string `json:"prev_cursor"`
+	Links      struct {
+		Self string
+		Next string
+		Prev string
+	}
+}
+
+//pageOf checks that a page holds the books with ids in that order and hands the page to next when it is not nil
+func pageOf(next *bookPage, ids ...uint) func(t *testing.T, resp *http.Response) {
+	return func(t *testing.T, resp *http.Response) {
+		var p bookPage
+		decode(t, resp, &p)
+		got := make([]uint, len(p.Data))
+		for i, b := range p.Data {
+			got[i] = b.ID
+		}
+		if len(got) != len(ids) {
+			t.Fatalf("got books %v, want %v", got, ids)
+		}
+		for i := range ids {
+			if got[i] != ids[i] {
+				t.Fatalf("got books %v, want %v", got, ids)
+			}
+		}
+		if next != nil {
+			*next = p
+		}
+	}
+}
+
+func TestBookListPagination(t *testing.T) {
+	ts, catalog := newTestServer(t)
+	// ids 1 to 5, by name the order is 3 5 2 1 4
+	addBooks(t, catalog, 5, "The Dispossessed", "Lavinia", "Always Coming Home", "The Lathe of Heaven", "Four Ways to Forgiveness")
+
+	var first bookPage
+	runRouteTests(t, ts, []routeTest{
+		{name: "first page", method: http.MethodGet, path: "/book?limit=2", token: user.Reader, want: http.StatusOK,
+			check: pageOf(&first, 1, 2)},
+		{name: "last page", method: http.MethodGet, path: "/book?limit=2&offset=4", token: user.Reader, want: http.StatusOK,
+			check: pageOf(nil, 5)},
+		{name: "past the end", method: http.MethodGet, path: "/book?offset=9", token: user.Reader, want: http.StatusOK,
+			check: pageOf(nil)},
+		{name: "by name", method: http.MethodGet, path: "/book?sort=name", token: user.Reader, want: http.StatusOK,
+			check: pageOf(nil, 3, 5, 2, 1, 4)},
+		{name: "by name descending", method: http.MethodGet, path: "/book?sort=-name&limit=2", token: user.Reader,
+			want: http.StatusOK, check: pageOf(nil, 4, 1)},
+		{name: "by isbn", method: http.MethodGet, path: "/book?isbn=" + isbn13(2), token: user.Reader, want: http.StatusOK,
+			check: pageOf(nil, 2)},
+		{name: "limit too large", method: http.MethodGet, path: "/book?limit=1000", token: user.Reader, want: http.StatusBadRequest},
+		{name: "negative offset", method: http.MethodGet, path: "/book?offset=-1", token: user.Reader, want: http.StatusBadRequest},
+		{name: "unknown sort", method: http.MethodGet, path: "/book?sort=weight", token: user.Reader, want: http.StatusBadRequest},
+		{name: "unknown parameter", method: http.MethodGet, path: "/book?page=2", token: user.Reader, want: http.StatusBadRequest},
+		{name: "cursor with offset", method: http.MethodGet, path: "/book?cursor=x&offset=2", token: user.Reader,
+			want: http.StatusBadRequest},
+		{name: "bad cursor", method: http.MethodGet, path: "/book?cursor=not-a-cursor", token: user.Reader,
+			want: http.StatusBadRequest},
+	})
+	if first.Total != 5 || first.Links.Next != "/book?limit=2&offset=2" || first.Links.Prev != "" {
+		t.Errorf("got total %d and links %+v", first.Total, first.Links)
+	}
+}
+
+func TestBookListCursorRoundTrip(t *testing.T) {
+	ts, catalog := newTestServer(t)
+	addBooks(t, catalog, 5, "The Dispossessed", "Lavinia", "Always Coming Home", "The Lathe of Heaven", "Four Ways to Forgiveness")
+
+	// each step follows a link of the page the step before read
+	var p bookPage
+	steps := []struct {
+		name string
+		path func() string
+		ids  []uint
+	}{
+		{"first page", func() string { return "/book?sort=name&limit=2" }, []uint{3, 5}},
+		{"second page by cursor", func() string { return "/book?sort=name&limit=2&cursor=" + p.NextCursor }, []uint{2, 1}},
+		{"next", func() string { return p.Links.Next }, []uint{4}},
+		{"back", func() string { return p.Links.Prev }, []uint{2, 1}},
+		{"back to the first page", func() string { return p.Links.Prev }, []uint{3, 5}},
+		{"forward again", func() string { return p.Links.Next }, []uint{2, 1}},
+	}
+	for _, step := range steps {
+		path := step.path()
+		if path == "" {
+			t.Fatalf("%s: the page before has no link to follow", step.name)
+		}
+		resp := do(t, http.MethodGet, ts.URL+path, user.Reader, nil)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("%s: GET %s: got %d, want %d", step.name, path, resp.StatusCode, http.StatusOK)
+		}
+		pageOf(&p, step.ids...)(t, resp)
+	}
+	if p.Links.Prev == "" {
+		t.Error("the second page has no link back")
+	}
+}
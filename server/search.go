@@ -0,0 +1,39 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BatuhanSerin/postgresql/domain/search"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+)
+
+//Search ranks books and authors matching ?q= and suggests close names when nothing matched exactly
+func (s *Services) Search(w http.ResponseWriter, r *http.Request) {
+
+	query := r.URL.Query()
+	limit := search.DefaultLimit
+	if v := query.Get("limit"); v != "" {
+		l, err := strconv.Atoi(v)
+		if err != nil || l < 1 || l > search.MaxLimit {
+			respondWithError(w, r, httpErrors.NewBadQueryParamsError(map[string]string{
+				"limit": "must be an integer between 1 and " + strconv.Itoa(search.MaxLimit),
+			}))
+			return
+		}
+		limit = l
+	}
+
+	d, err := s.Searcher.Search(r.Context(), query.Get("q"), limit)
+	if errors.Is(err, search.ErrEmptyQuery) {
+		respondWithError(w, r, httpErrors.NewBadQueryParamsError(map[string]string{"q": "must contain a letter or a digit"}))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
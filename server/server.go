@@ -1,252 +1,836 @@
 package server
 
 import (
+	"bytes"
 	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
 	"os/signal"
+	"regexp"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
+	"github.com/BatuhanSerin/postgresql/common/config"
 	postgres "github.com/BatuhanSerin/postgresql/common/db"
+	"github.com/BatuhanSerin/postgresql/common/db/migrations"
+	"github.com/BatuhanSerin/postgresql/domain/apikey"
+	"github.com/BatuhanSerin/postgresql/domain/audit"
+	"github.com/BatuhanSerin/postgresql/domain/auth"
 	"github.com/BatuhanSerin/postgresql/domain/author"
 	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/exporter"
+	"github.com/BatuhanSerin/postgresql/domain/health"
+	"github.com/BatuhanSerin/postgresql/domain/idempotency"
+	"github.com/BatuhanSerin/postgresql/domain/importer"
+	"github.com/BatuhanSerin/postgresql/domain/order"
+	"github.com/BatuhanSerin/postgresql/domain/search"
+	"github.com/BatuhanSerin/postgresql/domain/user"
 	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
 	"github.com/gorilla/handlers"
 	"github.com/gorilla/mux"
-	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
-var Bookrepo = BookRepo()
-var Authorrepo = AuthorRepo()
+// requestIDKey is the request context key of the request id
+type requestIDKey struct{}
 
-//Server runs the server
-func Server() {
+// requestIDPattern accepts the request ids sent by clients, anything else is replaced
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,128}$`)
 
+//Catalog serves the book, author, stock and trash routes from the stores it is given
+type Catalog struct {
+	Books   book.BookStore
+	Authors author.AuthorStore
+}
+
+//NewCatalog returns the catalog handlers of books and authors
+func NewCatalog(books book.BookStore, authors author.AuthorStore) *Catalog {
+	return &Catalog{Books: books, Authors: authors}
+}
+
+//Services are the stores every route is served from. Server fills them from the database, the routes can be served
+//from memory stores and fakes as well
+type Services struct {
+	Catalog     *Catalog
+	Orders      order.OrderStore
+	Users       user.UserStore
+	Auth        auth.AuthStore
+	APIKeys     apikey.APIKeyStore
+	Audit       audit.AuditStore
+	Searcher    search.SearchStore
+	Exports     exporter.ExportStore
+	Imports     importer.ImportStore
+	Idempotency idempotency.IdempotencyStore
+	Health      health.HealthStore
+	Pool        Pool
+}
+
+//Pool reports the statistics of the connection pool of the database, *sql.DB is one
+type Pool interface {
+	Stats() sql.DBStats
+}
+
+//Server runs the server with the given configuration
+func Server(cfg *config.Config) {
+
+	var dump bytes.Buffer
+	if err := cfg.Dump(&dump); err == nil {
+		log.Printf("Configuration\n%s", dump.String())
+	}
+
+	// every repository shares the connection pool of db
+	db, err := postgres.Open(cfg.Database)
+	if err != nil {
+		log.Fatal("Database cannot init ", err)
+	}
+
+	log.Println("Database connected")
+
+	if cfg.Database.Migrate {
+		if err := migrations.Up(db); err != nil {
+			log.Fatal("Migrations failed ", err)
+		}
+	}
+	pool, err := db.DB()
+	if err != nil {
+		log.Fatal("Database cannot init ", err)
+	}
+
+	// authors are set up first, books reference them
+	authors := AuthorRepo(cfg, db).Store()
+	services := &Services{
+		Catalog:     NewCatalog(BookRepo(cfg, db).Store(), authors),
+		Searcher:    search.NewSearchRepository(db).Store(),
+		Orders:      order.NewOrderRepository(db).Store(),
+		Imports:     importer.NewImporter(db).Store(),
+		Exports:     exporter.NewExporter(db).Store(),
+		Users:       UserRepo(cfg, db).Store(),
+		Auth:        AuthRepo(cfg, db).Store(),
+		APIKeys:     apikey.NewAPIKeyRepository(db).Store(),
+		Audit:       audit.NewAuditRepository(db).Store(),
+		Idempotency: idempotency.NewIdempotencyRepository(db).Store(),
+		Health:      health.NewHealthRepository(db).Store(),
+		Pool:        pool,
+	}
+	go purgeTrash(services.Catalog.Books, cfg.Trash.Retention, cfg.Trash.PurgeInterval)
+	go purgeIdempotencyKeys(services.Idempotency)
+
+	srv := &http.Server{
+		Addr:         cfg.Server.Addr,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		Handler:      NewRouter(cfg, services),
+	}
+
+	go func() {
+		if err := srv.ListenAndServe(); err != nil {
+			log.Println(err)
+		}
+	}()
+
+	ShutdownServer(srv, cfg.Server.DrainDelay, cfg.Server.ShutdownTimeout)
+}
+
+//NewRouter returns the routes of the server served from the stores of s
+func NewRouter(cfg *config.Config, s *Services) *mux.Router {
 	r := mux.NewRouter()
+	catalog := s.Catalog
 
 	handlers.AllowedOrigins([]string{"https://www.example.com"})
-	handlers.AllowedHeaders([]string{"Content-Type", "Authorization"})
-	handlers.AllowedMethods([]string{"POST", "GET", "PUT", "DELETE"})
-
+	handlers.AllowedHeaders([]string{"Content-Type", "Authorization", "X-API-Key", "Idempotency-Key"})
+	handlers.AllowedMethods([]string{"POST", "GET", "PUT", "PATCH", "DELETE"})
+
+	r.NotFoundHandler = requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithError(w, r, httpErrors.NewRestError(http.StatusNotFound, httpErrors.NotFound.Error(), nil))
+	}))
+	r.MethodNotAllowedHandler = requestIDMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respondWithError(w, r, httpErrors.NewRestError(http.StatusMethodNotAllowed, http.StatusText(http.StatusMethodNotAllowed), nil))
+	}))
+	r.Use(requestIDMiddleware)
 	r.Use(loggingMiddleware)
-	r.Use(authenticationMiddleware)
+	r.Use(timeoutMiddleware(cfg.Server, cfg.Timeouts))
+	r.Use(s.authenticationMiddleware)
+	// a running request keeps its key until every route would have timed out
+	r.Use(s.idempotencyMiddleware(cfg.Idempotency.TTL, cfg.Timeouts.Longest()+abandonMargin))
+
+	//0.0.0.0:8090/healthz
+	r.HandleFunc("/healthz", Healthz).Methods(http.MethodGet)
+	r.HandleFunc("/readyz", s.Readyz).Methods(http.MethodGet)
+
+	//0.0.0.0:8090/auth/login
+	au := r.PathPrefix("/auth").Subrouter()
+	au.HandleFunc("/login", s.Login).Methods(http.MethodPost)
+	au.HandleFunc("/refresh", s.TokenRefresh).Methods(http.MethodPost)
+	au.HandleFunc("/logout", s.Logout).Methods(http.MethodPost)
+	au.HandleFunc("/register", s.Register).Methods(http.MethodPost)
+
+	//0.0.0.0:8090/users
+	u := r.PathPrefix("/users").Subrouter()
+	u.HandleFunc("", requirePermission(user.UsersManage, s.UserList)).Methods(http.MethodGet)
+	u.HandleFunc("", requirePermission(user.UsersManage, s.UserCreate)).Methods(http.MethodPost)
+	u.HandleFunc("/me", s.UserMe).Methods(http.MethodGet)
+	u.HandleFunc("/{id}/role", requirePermission(user.UsersManage, s.UserChangeRole)).Methods(http.MethodPut)
+
+	//0.0.0.0:8090/apikeys
+	k := r.PathPrefix("/apikeys").Subrouter()
+	k.HandleFunc("", requirePermission(user.APIKeysManage, s.APIKeyList)).Methods(http.MethodGet)
+	k.HandleFunc("", requirePermission(user.APIKeysManage, s.APIKeyCreate)).Methods(http.MethodPost)
+	k.HandleFunc("/{id}/rotate", requirePermission(user.APIKeysManage, s.APIKeyRotate)).Methods(http.MethodPost)
+	k.HandleFunc("/{id}", requirePermission(user.APIKeysManage, s.APIKeyRevoke)).Methods(http.MethodDelete)
 
 	//0.0.0.0:8090/book
 	b := r.PathPrefix("/book").Subrouter()
 
-	b.HandleFunc("", BookList).Methods(http.MethodGet)
+	b.HandleFunc("", requirePermission(user.BooksRead, catalog.BookList)).Methods(http.MethodGet)
+	b.HandleFunc("", requirePermission(user.BooksWrite, catalog.BookCreate)).Methods(http.MethodPost)
+	//0.0.0.0:8090/book/trash
+	b.HandleFunc("/trash", requirePermission(user.BooksWrite, catalog.BookTrash)).Methods(http.MethodGet)
 	//0.0.0.0:8090/book/2
-	b.HandleFunc("/{id}", BookListById).Methods(http.MethodGet)
+	b.HandleFunc("/{id}", requirePermission(user.BooksRead, catalog.BookListById)).Methods(http.MethodGet)
+	b.HandleFunc("/{id}", requirePermission(user.BooksWrite, catalog.BookUpdate)).Methods(http.MethodPut)
+	b.HandleFunc("/{id}", requirePermission(user.BooksWrite, catalog.BookPatch)).Methods(http.MethodPatch)
+	//0.0.0.0:8090/book/2?hard=true
+	b.HandleFunc("/{id}", requirePermission(user.BooksWrite, catalog.BookDelete)).Methods(http.MethodDelete)
+	b.HandleFunc("/{id}/restore", requirePermission(user.BooksWrite, catalog.BookRestore)).Methods(http.MethodPost)
 	//0.0.0.0:8090/book/id/20
-	b.HandleFunc("/id/{id}", BookListByAuthorOrBookId).Methods(http.MethodGet)
+	b.HandleFunc("/id/{id}", requirePermission(user.BooksRead, catalog.BookListByAuthorOrBookId)).Methods(http.MethodGet)
 	//0.0.0.0:8090/book/<name>
-	b.HandleFunc("/", BookListByName).Methods(http.MethodGet)
-	b.HandleFunc("/delete/{id}", BookBeforeDelete).Methods(http.MethodDelete)
+	b.HandleFunc("/", requirePermission(user.BooksRead, catalog.BookListByName)).Methods(http.MethodGet)
+	b.HandleFunc("/delete/{id}", requirePermission(user.BooksWrite, catalog.BookBeforeDelete)).Methods(http.MethodDelete)
+	//0.0.0.0:8090/book/2/stock/movements
+	b.HandleFunc("/{id}/stock/movements", requirePermission(user.BooksRead, catalog.BookStockMovements)).Methods(http.MethodGet)
+	b.HandleFunc("/{id}/stock/movements", requirePermission(user.StockAdjust, catalog.BookStockMovementCreate)).Methods(http.MethodPost)
+	b.HandleFunc("/{id}/stock/reservations", requirePermission(user.StockAdjust, catalog.BookStockReserve)).Methods(http.MethodPost)
+
+	//0.0.0.0:8090/stock/reservations/7
+	st := r.PathPrefix("/stock/reservations").Subrouter()
+	st.HandleFunc("/{id}", requirePermission(user.BooksRead, catalog.StockReservationGet)).Methods(http.MethodGet)
+	st.HandleFunc("/{id}/commit", requirePermission(user.StockAdjust, catalog.StockReservationCommit)).Methods(http.MethodPost)
+	st.HandleFunc("/{id}/release", requirePermission(user.StockAdjust, catalog.StockReservationRelease)).Methods(http.MethodPost)
 
 	//0.0.0.0:8090/author
 	a := r.PathPrefix("/author").Subrouter()
-	a.HandleFunc("", BookListWithAuthors).Methods(http.MethodGet)
+	a.HandleFunc("", requirePermission(user.AuthorsRead, catalog.BookListWithAuthors)).Methods(http.MethodGet)
+	a.HandleFunc("", requirePermission(user.AuthorsWrite, catalog.AuthorCreate)).Methods(http.MethodPost)
 	//0.0.0.0:8090/author/<name>
-	a.HandleFunc("/name", BookListByAuthorWithName).Methods(http.MethodGet)
+	a.HandleFunc("/name", requirePermission(user.AuthorsRead, catalog.BookListByAuthorWithName)).Methods(http.MethodGet)
+	//0.0.0.0:8090/author/20
+	a.HandleFunc("/{id}", requirePermission(user.AuthorsRead, catalog.AuthorGetById)).Methods(http.MethodGet)
+	a.HandleFunc("/{id}", requirePermission(user.AuthorsWrite, catalog.AuthorUpdate)).Methods(http.MethodPut)
+	a.HandleFunc("/{id}", requirePermission(user.AuthorsWrite, catalog.AuthorPatch)).Methods(http.MethodPatch)
+	//0.0.0.0:8090/author/20?cascade=true or ?reassign_to=50
+	a.HandleFunc("/{id}", requirePermission(user.AuthorsWrite, catalog.AuthorDelete)).Methods(http.MethodDelete)
+
+	//0.0.0.0:8090/orders
+	o := r.PathPrefix("/orders").Subrouter()
+	o.HandleFunc("", requirePermission(user.OrdersWrite, s.OrderCheckout)).Methods(http.MethodPost)
+	//0.0.0.0:8090/orders/3
+	o.HandleFunc("/{id}", requirePermission(user.OrdersRead, s.OrderGetById)).Methods(http.MethodGet)
+	o.HandleFunc("/{id}/transitions", requirePermission(user.OrdersWrite, s.OrderTransition)).Methods(http.MethodPost)
+	//0.0.0.0:8090/customers/<customer id>/orders
+	r.HandleFunc("/customers/{customerID}/orders", requirePermission(user.OrdersRead, s.CustomerOrders)).Methods(http.MethodGet)
+
+	//0.0.0.0:8090/search?q=<words>
+	r.HandleFunc("/search", requirePermission(user.BooksRead, s.Search)).Methods(http.MethodGet)
+
+	//0.0.0.0:8090/import/books?format=csv&mode=upsert&dry_run=true
+	i := r.PathPrefix("/import").Subrouter()
+	i.HandleFunc("/books", requirePermission(user.CatalogImport, s.ImportBooks)).Methods(http.MethodPost)
+	i.HandleFunc("/authors", requirePermission(user.CatalogImport, s.ImportAuthors)).Methods(http.MethodPost)
+
+	//0.0.0.0:8090/export/books?format=xlsx
+	e := r.PathPrefix("/export").Subrouter()
+	e.HandleFunc("/books", requirePermission(user.CatalogExport, s.ExportBooks)).Methods(http.MethodGet)
+	e.HandleFunc("/authors", requirePermission(user.CatalogExport, s.ExportAuthors)).Methods(http.MethodGet)
+
+	//0.0.0.0:8090/audit?entity=book&id=<id>
+	r.HandleFunc("/audit", requirePermission(user.AuditRead, s.AuditList)).Methods(http.MethodGet)
+
+	//0.0.0.0:8090/db/stats
+	r.HandleFunc("/db/stats", requirePermission(user.SystemRead, s.DatabaseStats)).Methods(http.MethodGet)
+
+	return r
+}
 
-	srv := &http.Server{
-		Addr:         "localhost:8090",
-		WriteTimeout: time.Second * 15,
-		ReadTimeout:  time.Second * 15,
-		IdleTimeout:  time.Second * 60,
-		Handler:      r,
+//BookRepo returns the book repository of db and seeds the configured books
+func BookRepo(cfg *config.Config, db *gorm.DB) *book.BookRepository {
+	bookRepo := book.NewBookRepository(db)
+	if cfg.Seed.Books != "" {
+		if err := bookRepo.InsertData(cfg.Seed.Books); err != nil {
+			log.Println("Books cannot be seeded ", err)
+		}
 	}
 
-	go func() {
-		if err := srv.ListenAndServe(); err != nil {
-			log.Println(err)
+	return bookRepo
+}
+
+//AuthorRepo returns the author repository of db and seeds the configured authors
+func AuthorRepo(cfg *config.Config, db *gorm.DB) *author.AuthorRepository {
+	authorRepo := author.NewAuthorRepository(db)
+	if cfg.Seed.Authors != "" {
+		if err := authorRepo.InsertData(cfg.Seed.Authors); err != nil {
+			log.Println("Authors cannot be seeded ", err)
 		}
-	}()
+	}
+	return authorRepo
+}
+
+//BookList returns a page of books, see parseBookListQuery for the query parameters
+func (c *Catalog) BookList(w http.ResponseWriter, r *http.Request) {
 
-	ShutdownServer(srv, time.Second*10)
+	q, err := parseBookListQuery(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := c.Books.List(r.Context(), q)
+	if errors.Is(err, book.ErrInvalidCursor) {
+		respondWithError(w, r, httpErrors.NewBadQueryParamsError(map[string]string{"cursor": err.Error()}))
+		return
+	}
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, newBookPage(r, q, d))
 }
 
-func BookRepo() *book.BookRepository {
+//BookListById returns a book by its ID with its ETag, 304 when If-None-Match already has it
+func (c *Catalog) BookListById(w http.ResponseWriter, r *http.Request) {
 
-	err := godotenv.Load()
+	id, err := uintParam(r, "id")
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		respondWithError(w, r, err)
+		return
 	}
 
-	db, err := postgres.NewPsqlDB()
+	d, err := c.Books.GetByID(r.Context(), id)
 	if err != nil {
-		log.Fatal("Postgres cannot init ", err)
+		respondWithError(w, r, err)
+		return
+	}
+	if notModified(w, r, bookETag(d)) {
+		return
 	}
 
-	log.Println("Postgres connected")
+	respondWithJSON(w, r, http.StatusOK, d)
+}
 
-	bookRepo := book.NewBookRepository(db)
-	bookRepo.Migrations()
-	bookRepo.InsertData()
+//BookListByAuthorOrBookId returns the books whose ID or author id is id
+func (c *Catalog) BookListByAuthorOrBookId(w http.ResponseWriter, r *http.Request) {
 
-	return bookRepo
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := c.Books.FindByAuthorOrBookId(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
 }
-func AuthorRepo() *author.AuthorRepository {
-	err := godotenv.Load()
+
+//BookListByName returns the books whose name contains ?name=
+func (c *Catalog) BookListByName(w http.ResponseWriter, r *http.Request) {
+
+	param := r.URL.Query().Get("name")
+	if strings.TrimSpace(param) == "" {
+		respondWithError(w, r, httpErrors.NewBadQueryParamsError(map[string]string{"name": "is required"}))
+		return
+	}
+
+	d, err := c.Books.FindByName(r.Context(), param)
 	if err != nil {
-		log.Fatal("Error loading .env file")
+		respondWithError(w, r, err)
+		return
 	}
 
-	db, err := postgres.NewPsqlDB()
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//BookBeforeDelete deletes a book, a missing book is 404 and an already deleted one 410
+func (c *Catalog) BookBeforeDelete(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
 	if err != nil {
-		log.Fatal("Postgres cannot init ", err)
+		respondWithError(w, r, err)
+		return
 	}
 
-	log.Println("Postgres connected")
+	if err := c.Books.Delete(r.Context(), id); err != nil {
+		respondWithError(w, r, trashError(err))
+		return
+	}
 
-	authorRepo := author.NewAuthorRepository(db)
-	authorRepo.Migrations()
-	authorRepo.InsertData()
-	return authorRepo
+	w.WriteHeader(http.StatusNoContent)
 }
 
-func BookList(w http.ResponseWriter, r *http.Request) {
-	//vars := mux.Vars(r)
-	//r.URL.Query().Get("param")
+//BookCreate creates a book from the JSON request body
+func (c *Catalog) BookCreate(w http.ResponseWriter, r *http.Request) {
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("Content-Type", "application/json")
+	var newBook book.Book
+	if err := decodeJSON(r, &newBook); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	newBook.Model = gorm.Model{}
+	newBook.Version = 0
+	newBook.Reserved = 0
 
-	d := Bookrepo.FinAll()
+	if fields := newBook.Validate(); fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
 
-	resp, _ := json.Marshal(d)
-	w.Write(resp)
+	if err := c.Books.Create(r.Context(), &newBook); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", bookETag(&newBook))
+	respondWithJSON(w, r, http.StatusCreated, newBook)
 }
 
-func BookListById(w http.ResponseWriter, r *http.Request) {
+//BookUpdate replaces every field of an existing book with the JSON request body, If-Match must have the ETag of the book
+func (c *Catalog) BookUpdate(w http.ResponseWriter, r *http.Request) {
 
-	vars := mux.Vars(r)
-	id, _ := strconv.Atoi(vars["id"])
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("Content-Type", "application/json")
+	current, err := c.Books.GetByID(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if err := checkIfMatch(r, bookETag(current)); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
 
-	d := Bookrepo.FindBookById(id)
+	var updated book.Book
+	if err := decodeJSON(r, &updated); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	updated.Model = current.Model
+	updated.Reserved = current.Reserved
+	updated.Version = current.Version
 
-	resp, _ := json.Marshal(d)
-	w.Write(resp)
+	fields := updated.Validate()
+	if updated.Stock != current.Stock {
+		fields = stockChanged(fields)
+	}
+	if fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
+
+	if err := c.Books.Update(r.Context(), &updated); err != nil {
+		respondWithError(w, r, versionError(err))
+		return
+	}
+
+	w.Header().Set("ETag", bookETag(&updated))
+	respondWithJSON(w, r, http.StatusOK, updated)
 }
 
-func BookListByAuthorOrBookId(w http.ResponseWriter, r *http.Request) {
+//BookPatch updates only the fields of an existing book that are present in the JSON request body,
+//If-Match must have the ETag of the book
+func (c *Catalog) BookPatch(w http.ResponseWriter, r *http.Request) {
 
-	vars := mux.Vars(r)
-	id, _ := strconv.Atoi(vars["id"])
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("Content-Type", "application/json")
+	current, err := c.Books.GetByID(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if err := checkIfMatch(r, bookETag(current)); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
 
-	d := Bookrepo.FindByAuthorOrBookId(id)
+	patched := *current
+	if err := decodeJSON(r, &patched); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	patched.Model = current.Model
+	patched.Reserved = current.Reserved
+	patched.Version = current.Version
 
-	resp, _ := json.Marshal(d)
-	w.Write(resp)
+	fields := patched.Validate()
+	if patched.Stock != current.Stock {
+		fields = stockChanged(fields)
+	}
+	if fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
+
+	if err := c.Books.Update(r.Context(), &patched); err != nil {
+		respondWithError(w, r, versionError(err))
+		return
+	}
+
+	w.Header().Set("ETag", bookETag(&patched))
+	respondWithJSON(w, r, http.StatusOK, patched)
+}
+
+//BookListWithAuthors returns every author with its books
+func (c *Catalog) BookListWithAuthors(w http.ResponseWriter, r *http.Request) {
+
+	d, err := c.Authors.GetAllAuthorsWithBookInformation(r.Context())
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
 }
 
-func BookListByName(w http.ResponseWriter, r *http.Request) {
+//BookListByAuthorWithName returns the author named ?name= with its books
+func (c *Catalog) BookListByAuthorWithName(w http.ResponseWriter, r *http.Request) {
 
-	//vars := mux.Vars(r)
 	param := r.URL.Query().Get("name")
-	// id, _ := strconv.Atoi(vars["name"])
+	if strings.TrimSpace(param) == "" {
+		respondWithError(w, r, httpErrors.NewBadQueryParamsError(map[string]string{"name": "is required"}))
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("Content-Type", "application/json")
+	d, err := c.Authors.GetAuthorWithName(r.Context(), param)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
 
-	d := Bookrepo.FindByName(param)
+	respondWithJSON(w, r, http.StatusOK, d)
+}
 
-	resp, _ := json.Marshal(d)
-	if len(resp) != 0 {
-		w.Write([]byte(httpErrors.NotFound.Error()))
+//stockChanged rejects a stock change that does not go through the stock ledger
+func stockChanged(fields map[string]string) map[string]string {
+	if fields == nil {
+		fields = map[string]string{}
 	}
-	w.Write(resp)
+	fields["Stock"] = "can only be changed through /book/{id}/stock/movements"
+	return fields
 }
 
-func BookBeforeDelete(w http.ResponseWriter, r *http.Request) {
+//AuthorCreate creates an author from the JSON request body
+func (c *Catalog) AuthorCreate(w http.ResponseWriter, r *http.Request) {
+
+	var newAuthor author.Author
+	if err := decodeJSON(r, &newAuthor); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	newAuthor.Model = gorm.Model{}
+	newAuthor.Version = 0
+	newAuthor.Books = nil
+
+	if fields := newAuthor.Validate(); fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
 
-	vars := mux.Vars(r)
-	id, _ := strconv.Atoi(vars["id"])
+	if err := c.Authors.Create(r.Context(), &newAuthor); err != nil {
+		respondWithError(w, r, authorError(err))
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("Content-Type", "application/json")
+	w.Header().Set("ETag", authorETag(&newAuthor, nil))
+	respondWithJSON(w, r, http.StatusCreated, newAuthor)
+}
 
-	d := Bookrepo.BeforeDelete(id)
+//AuthorGetById returns the author with the given author id and its books with their ETag, 304 when If-None-Match already has it
+func (c *Catalog) AuthorGetById(w http.ResponseWriter, r *http.Request) {
 
-	resp, _ := json.Marshal(d)
-	w.Write(resp)
+	id, err := authorIDParam(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := c.Authors.GetByAuthorID(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if notModified(w, r, authorETag(d, d.Books)) {
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
 }
 
-func BookListWithAuthors(w http.ResponseWriter, r *http.Request) {
+//AuthorUpdate replaces every field of an existing author with the JSON request body, If-Match must have the ETag of the author
+func (c *Catalog) AuthorUpdate(w http.ResponseWriter, r *http.Request) {
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("Content-Type", "application/json")
+	id, err := authorIDParam(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
 
-	d := Authorrepo.GetAllAuthorsWithBookInformation()
+	current, err := c.Authors.GetByAuthorID(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if err := checkIfMatch(r, authorETag(current, current.Books)); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
 
-	resp, _ := json.Marshal(d)
+	var updated author.Author
+	if err := decodeJSON(r, &updated); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	updated.Model = current.Model
+	updated.AuthorID = current.AuthorID
+	updated.Version = current.Version
+	updated.Books = nil
+
+	if fields := updated.Validate(); fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
+
+	if err := c.Authors.Update(r.Context(), &updated); err != nil {
+		respondWithError(w, r, authorError(err))
+		return
+	}
 
-	w.Write([]byte(resp))
+	w.Header().Set("ETag", authorETag(&updated, current.Books))
+	respondWithJSON(w, r, http.StatusOK, updated)
 }
 
-func BookListByAuthorWithName(w http.ResponseWriter, r *http.Request) {
+//AuthorPatch updates only the fields of an existing author that are present in the JSON request body,
+//If-Match must have the ETag of the author
+func (c *Catalog) AuthorPatch(w http.ResponseWriter, r *http.Request) {
 
-	//vars := mux.Vars(r)
-	param := r.URL.Query().Get("name")
-	// id, _ := strconv.Atoi(vars["name"])
+	id, err := authorIDParam(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Header().Add("Content-Type", "application/json")
+	current, err := c.Authors.GetByAuthorID(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	if err := checkIfMatch(r, authorETag(current, current.Books)); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
 
-	d := Authorrepo.GetAuthorWithName(param)
+	patched := *current
+	if err := decodeJSON(r, &patched); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	patched.Model = current.Model
+	patched.AuthorID = current.AuthorID
+	patched.Version = current.Version
+	patched.Books = nil
+
+	if fields := patched.Validate(); fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
 
-	resp, _ := json.Marshal(d)
+	if err := c.Authors.Update(r.Context(), &patched); err != nil {
+		respondWithError(w, r, authorError(err))
+		return
+	}
 
-	w.Write([]byte(resp))
+	w.Header().Set("ETag", authorETag(&patched, current.Books))
+	respondWithJSON(w, r, http.StatusOK, patched)
 }
 
+//AuthorDelete deletes the author with the given author id,
+//an author who still has books needs ?cascade=true or ?reassign_to=<author id>
+func (c *Catalog) AuthorDelete(w http.ResponseWriter, r *http.Request) {
 
+	id, err := authorIDParam(r)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	query := r.URL.Query()
+
+	var options author.DeleteOptions
+	if reassignTo := query.Get("reassign_to"); reassignTo != "" {
+		target, err := strconv.ParseUint(reassignTo, 10, 64)
+		if err != nil {
+			respondWithError(w, r, httpErrors.NewRestError(http.StatusBadRequest, httpErrors.BadQueryParams.Error(), err))
+			return
+		}
+		options.ReassignTo = uint(target)
+	}
+	if cascade := query.Get("cascade"); cascade != "" {
+		c, err := strconv.ParseBool(cascade)
+		if err != nil {
+			respondWithError(w, r, httpErrors.NewRestError(http.StatusBadRequest, httpErrors.BadQueryParams.Error(), err))
+			return
+		}
+		options.Cascade = c
+	}
 
-func loggingMiddleware(next http.Handler) http.Handler {
+	if err := c.Authors.Delete(r.Context(), id, options); err != nil {
+		respondWithError(w, r, authorError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//authorIDParam parses the author id of the route
+func authorIDParam(r *http.Request) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)["id"], 10, 64)
+	if err != nil {
+		return 0, httpErrors.NewValidationError(map[string]string{"id": "must be a positive integer"})
+	}
+	return uint(id), nil
+}
+
+//authorError maps author repository errors to their http errors
+func authorError(err error) error {
+	switch {
+	case errors.Is(err, author.ErrAuthorExists), errors.Is(err, author.ErrAuthorHasBooks):
+		return httpErrors.NewRestError(http.StatusConflict, err.Error(), err)
+	case errors.Is(err, author.ErrReassignTargetNotFound), errors.Is(err, author.ErrConflictingOptions):
+		return httpErrors.NewRestError(http.StatusBadRequest, err.Error(), err)
+	}
+	return versionError(err)
+}
+
+//decodeJSON decodes a JSON request body into v and rejects unknown fields
+func decodeJSON(r *http.Request, v interface{}) error {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		return httpErrors.NewRestError(http.StatusUnsupportedMediaType, httpErrors.ContentType.Error(), nil)
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return httpErrors.NewRestError(http.StatusBadRequest, httpErrors.BadRequest.Error(), err)
+	}
+	return nil
+}
+
+//respondWithJSON writes payload as a JSON response with the given status code
+func respondWithJSON(w http.ResponseWriter, r *http.Request, status int, payload interface{}) {
+	// gorm does not report a raw query that the deadline stopped before its first row, the result may be cut short.
+	// Writes are answered anyway, they have been committed
+	if err := r.Context().Err(); err != nil && r.Method == http.MethodGet {
+		respondWithError(w, r, err)
+		return
+	}
+
+	resp, err := json.Marshal(payload)
+	if err != nil {
+		respondWithError(w, r, httpErrors.NewInternalServerError(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
+
+//respondWithError writes err as an RFC 7807 problem parsed by http_errors
+func respondWithError(w http.ResponseWriter, r *http.Request, err error) {
+	// a query stopped at the deadline or because the client went away fails with a driver error, the context tells why
+	var restErr httpErrors.RestErr
+	if ctxErr := r.Context().Err(); ctxErr != nil && !errors.Is(err, ctxErr) && !errors.As(err, &restErr) {
+		err = fmt.Errorf("%w: %v", ctxErr, err)
+	}
+	problem := httpErrors.NewProblem(err, r.URL.Path, requestID(r))
+	if problem.Status >= http.StatusInternalServerError {
+		log.Printf("%s %s: %v", problem.RequestID, r.URL.Path, err)
+	}
+
+	resp, _ := json.Marshal(problem)
+	w.Header().Set("Content-Type", httpErrors.ProblemContentType)
+	w.WriteHeader(problem.Status)
+	w.Write(resp)
+}
+
+//requestIDMiddleware keeps the X-Request-ID of the client or makes one up, returns it in the response
+//and puts it in the request context for logs and error responses
+func requestIDMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// Do stuff here
-		log.Println(r.RequestURI)
-		// Call the next handler, which can be another middleware in the chain, or the final handler.
-		next.ServeHTTP(w, r)
+		id := r.Header.Get("X-Request-ID")
+		if !requestIDPattern.MatchString(id) {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
 	})
 }
 
-func authenticationMiddleware(next http.Handler) http.Handler {
+//requestID returns the id of the request set by requestIDMiddleware
+func requestID(r *http.Request) string {
+	id, _ := r.Context().Value(requestIDKey{}).(string)
+	return id
+}
+
+func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get("Authorization")
-		if strings.HasPrefix(r.URL.Path, "/book/") {
-			if token != "" {
-				next.ServeHTTP(w, r)
-			} else {
-				http.Error(w, "Token not found", http.StatusUnauthorized)
-			}
-		} else {
-			next.ServeHTTP(w, r)
-		}
+		// Do stuff here
+		log.Println(requestID(r), r.Method, r.RequestURI)
+		// Call the next handler, which can be another middleware in the chain, or the final handler.
+		next.ServeHTTP(w, r)
 	})
 }
 
 //https://medium.com/@pinkudebnath/graceful-shutdown-of-golang-servers-using-context-and-os-signals-cc1fa2c55e97
 //https://www.rudderstack.com/blog/implementing-graceful-shutdown-in-go/
-func ShutdownServer(srv *http.Server, timeout time.Duration) {
+func ShutdownServer(srv *http.Server, drainDelay, timeout time.Duration) {
 	c := make(chan os.Signal, 1)
-	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C)
-	// SIGKILL, SIGQUIT or SIGTERM (Ctrl+/) will not be caught.
-	signal.Notify(c, os.Interrupt)
+	// We'll accept graceful shutdowns when quit via SIGINT (Ctrl+C) or SIGTERM,
+	// which orchestrators send before stopping a container.
+	// SIGKILL or SIGQUIT (Ctrl+/) will not be caught.
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
 
 	// Block until we receive our signal.
 	<-c
 
+	// Fail the readiness probe first so load balancers stop sending requests
+	// while the listener is still open.
+	atomic.StoreInt32(&draining, 1)
+	log.Printf("draining for %s", drainDelay)
+	time.Sleep(drainDelay)
+
 	// Create a deadline to wait for.
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -0,0 +1,346 @@
+package server_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"github.com/BatuhanSerin/postgresql/domain/auth"
+	"github.com/BatuhanSerin/postgresql/domain/author"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/memory"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+	"github.com/BatuhanSerin/postgresql/server"
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// fakeAuth accepts the role names as access tokens of user 1, every other token is left to the AuthStore it wraps
+type fakeAuth struct {
+	auth.AuthStore
+}
+
+func (f fakeAuth) Parse(token, tokenType string) (*auth.Claims, error) {
+	if user.ValidRole(token) && tokenType == auth.Access {
+		return &auth.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "1"}, Type: tokenType, Role: token}, nil
+	}
+	if f.AuthStore == nil {
+		return nil, auth.ErrInvalidToken
+	}
+	return f.AuthStore.Parse(token, tokenType)
+}
+
+//memoryServices serves the routes from memory stores, the catalog holds the books, authors and orders. A test that
+//needs a store to misbehave replaces it before starting the server
+func memoryServices(t *testing.T) (*server.Services, *memory.Catalog) {
+	t.Helper()
+	settings := config.Default().JWT
+	settings.Secret = "a test secret of at least 32 bytes"
+	tokens, err := auth.NewConfig(settings)
+	if err != nil {
+		t.Fatal(err)
+	}
+	catalog := memory.NewCatalog()
+	accounts := memory.NewAccounts(tokens)
+	return &server.Services{
+		Catalog:     server.NewCatalog(catalog.Books(), catalog.Authors()),
+		Orders:      catalog.Orders(),
+		Imports:     catalog.Imports(),
+		Exports:     catalog.Exports(),
+		Users:       accounts.Users(),
+		Auth:        fakeAuth{accounts.Auth()},
+		APIKeys:     accounts.APIKeys(),
+		Idempotency: memory.NewIdempotencyKeys(),
+		Health:      memory.Health{},
+	}, catalog
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, *memory.Catalog) {
+	t.Helper()
+	s, catalog := memoryServices(t)
+	cfg := config.Default()
+	return startServer(t, &cfg, s), catalog
+}
+
+//startServer serves the router of s with the read and write timeouts of cfg like Server does
+func startServer(t *testing.T, cfg *config.Config, s *server.Services) *httptest.Server {
+	t.Helper()
+	ts := httptest.NewUnstartedServer(server.NewRouter(cfg, s))
+	ts.Config.ReadTimeout = cfg.Server.ReadTimeout
+	ts.Config.WriteTimeout = cfg.Server.WriteTimeout
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func do(t *testing.T, method, url, token string, body interface{}) *http.Response {
+	t.Helper()
+	return send(t, method, url, token, nil, body)
+}
+
+//send sends body as JSON unless it is a string, which is sent as it is. The headers replace the JSON content type
+func send(t *testing.T, method, url, token string, header map[string]string, body interface{}) *http.Response {
+	t.Helper()
+	var payload bytes.Buffer
+	switch v := body.(type) {
+	case nil:
+	case string:
+		payload.WriteString(v)
+	default:
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	req, err := http.NewRequest(method, url, &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func decode(t *testing.T, resp *http.Response, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//routeTest is one request of a table driven route test and the status it has to answer
+type routeTest struct {
+	name   string
+	method string
+	path   string
+	token  string
+	header map[string]string
+	// body is sent like send does, a func() interface{} is called for the body when the request is sent
+	body interface{}
+	want int
+	// check looks at an answer with the wanted status
+	check func(t *testing.T, resp *http.Response)
+}
+
+//runRouteTests sends the requests of a table in order to one server, so that each sees what the ones before wrote
+func runRouteTests(t *testing.T, ts *httptest.Server, tests []routeTest) {
+	t.Helper()
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			body := tt.body
+			if f, ok := body.(func() interface{}); ok {
+				body = f()
+			}
+			resp := send(t, tt.method, ts.URL+tt.path, tt.token, tt.header, body)
+			if resp.StatusCode != tt.want {
+				answer, _ := io.ReadAll(resp.Body)
+				t.Fatalf("%s %s: got %d, want %d: %s", tt.method, tt.path, resp.StatusCode, tt.want, answer)
+			}
+			if tt.check != nil {
+				tt.check(t, resp)
+			}
+		})
+	}
+}
+
+//addBooks adds author 1 unless it exists and a book of it for each name with stock in stock, with ISBNs and stock
+//codes that follow the ids of the books
+func addBooks(t *testing.T, catalog *memory.Catalog, stock int, names ...string) []book.Book {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := catalog.Authors().GetByAuthorID(ctx, 1); err != nil {
+		if err := catalog.Authors().Create(ctx, &author.Author{AuthorID: 1, AuthorName: "Ursula Le Guin"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	books := make([]book.Book, len(names))
+	for i, name := range names {
+		b := book.Book{Name: name, Page: 200, Stock: stock, Cost: 1000, AuthorID: 1}
+		if err := catalog.Books().Create(ctx, &b); err != nil {
+			t.Fatal(err)
+		}
+		b.StockCode = fmt.Sprintf("SC-%d", b.ID)
+		b.ISBN = isbn13(b.ID)
+		if err := catalog.Books().Update(ctx, &b); err != nil {
+			t.Fatal(err)
+		}
+		books[i] = b
+	}
+	return books
+}
+
+//isbn13 returns an ISBN-13 with a valid check digit for n
+func isbn13(n uint) string {
+	digits := fmt.Sprintf("978%09d", n)
+	sum := 0
+	for i, d := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += int(d-'0') * weight
+	}
+	return fmt.Sprintf("%s%d", digits, (10-sum%10)%10)
+}
+
+func TestBookRoutesOverMemoryCatalog(t *testing.T) {
+	ts, catalog := newTestServer(t)
+	ctx := context.Background()
+	if err := catalog.Authors().Create(ctx, &author.Author{AuthorID: 7, AuthorName: "Ursula Le Guin"}); err != nil {
+		t.Fatal(err)
+	}
+
+	newBook := map[string]interface{}{
+		"Name": "The Dispossessed", "Page": 387, "Stock": 4, "Cost": "14.90",
+		"StockCode": "LG-1974", "ISBN": "9780061054884", "AuthorID": 7,
+	}
+	if resp := do(t, http.MethodPost, ts.URL+"/book", "", newBook); resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("POST /book without a token: got %d, want %d", resp.StatusCode, http.StatusUnauthorized)
+	}
+	if resp := do(t, http.MethodPost, ts.URL+"/book", user.Reader, newBook); resp.StatusCode != http.StatusForbidden {
+		t.Errorf("POST /book as reader: got %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+
+	for _, isbn := range []string{"9780061054885", "978006105488", "0-06-105488-X"} {
+		invalid := map[string]interface{}{}
+		for k, v := range newBook {
+			invalid[k] = v
+		}
+		invalid["ISBN"] = isbn
+		if resp := do(t, http.MethodPost, ts.URL+"/book", user.Editor, invalid); resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("POST /book with ISBN %s: got %d, want %d", isbn, resp.StatusCode, http.StatusBadRequest)
+		}
+	}
+
+	resp := do(t, http.MethodPost, ts.URL+"/book", user.Editor, newBook)
+	if resp.StatusCode != http.StatusCreated {
+		t.Fatalf("POST /book as editor: got %d, want %d", resp.StatusCode, http.StatusCreated)
+	}
+	var created book.Book
+	if err := json.NewDecoder(resp.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	etag := resp.Header.Get("ETag")
+
+	resp = do(t, http.MethodGet, fmt.Sprintf("%s/book/%d", ts.URL, created.ID), user.Reader, nil)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /book/%d: got %d, want %d", created.ID, resp.StatusCode, http.StatusOK)
+	}
+	if etag == "" || resp.Header.Get("ETag") != etag {
+		t.Errorf("POST /book answered ETag %q, GET /book/%d answers %q", etag, created.ID, resp.Header.Get("ETag"))
+	}
+	var got book.Book
+	if err := json.NewDecoder(resp.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != "The Dispossessed" || got.Stock != 4 {
+		t.Errorf("GET /book/%d: got %q with stock %d", created.ID, got.Name, got.Stock)
+	}
+	stored, err := catalog.Books().GetByID(ctx, created.ID)
+	if err != nil || stored.ISBN != "9780061054884" {
+		t.Errorf("book is not in the memory catalog: %v %v", stored, err)
+	}
+
+	resp = do(t, http.MethodPost, ts.URL+"/book", user.Editor, newBook)
+	if resp.StatusCode != http.StatusConflict {
+		t.Errorf("POST /book with a taken ISBN: got %d, want %d", resp.StatusCode, http.StatusConflict)
+	}
+	if ct := resp.Header.Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("POST /book with a taken ISBN: got content type %q", ct)
+	}
+
+	resp = do(t, http.MethodGet, ts.URL+"/book/999", user.Reader, nil)
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("GET /book/999: got %d, want %d", resp.StatusCode, http.StatusNotFound)
+	}
+}
+
+func TestAuthorRoutes(t *testing.T) {
+	ts, catalog := newTestServer(t)
+	addBooks(t, catalog, 1, "The Dispossessed")
+
+	runRouteTests(t, ts, []routeTest{
+		{name: "create as reader", method: http.MethodPost, path: "/author", token: user.Reader,
+			body: map[string]interface{}{"AuthorID": 2, "AuthorName": "Octavia Butler"}, want: http.StatusForbidden},
+		{name: "create", method: http.MethodPost, path: "/author", token: user.Editor,
+			body: map[string]interface{}{"AuthorID": 2, "AuthorName": "Octavia Butler"}, want: http.StatusCreated,
+			check: func(t *testing.T, resp *http.Response) {
+				if resp.Header.Get("ETag") == "" {
+					t.Error("no ETag")
+				}
+			}},
+		{name: "create a taken author id", method: http.MethodPost, path: "/author", token: user.Editor,
+			body: map[string]interface{}{"AuthorID": 2, "AuthorName": "Someone Else"}, want: http.StatusConflict},
+		{name: "create without a name", method: http.MethodPost, path: "/author", token: user.Editor,
+			body: map[string]interface{}{"AuthorID": 3}, want: http.StatusBadRequest},
+		{name: "create with an unknown field", method: http.MethodPost, path: "/author", token: user.Editor,
+			body: map[string]interface{}{"AuthorID": 3, "AuthorName": "N. K. Jemisin", "Born": 1972}, want: http.StatusBadRequest},
+		{name: "get", method: http.MethodGet, path: "/author/2", token: user.Reader, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var a author.Author
+				decode(t, resp, &a)
+				if a.AuthorName != "Octavia Butler" {
+					t.Errorf("got author %q", a.AuthorName)
+				}
+			}},
+		{name: "get unknown", method: http.MethodGet, path: "/author/99", token: user.Reader, want: http.StatusNotFound},
+		{name: "find by name", method: http.MethodGet, path: "/author/name?name=Octavia+Butler", token: user.Reader, want: http.StatusOK},
+		{name: "find without a name", method: http.MethodGet, path: "/author/name", token: user.Reader, want: http.StatusBadRequest},
+		{name: "list with books", method: http.MethodGet, path: "/author", token: user.Reader, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var authors []author.Author
+				decode(t, resp, &authors)
+				if len(authors) != 2 || len(authors[0].Books) != 1 {
+					t.Errorf("got %d authors, the first with %d books", len(authors), len(authors[0].Books))
+				}
+			}},
+		{name: "update without If-Match", method: http.MethodPut, path: "/author/2", token: user.Editor,
+			body: map[string]interface{}{"AuthorName": "Octavia E. Butler"}, want: http.StatusPreconditionRequired},
+		{name: "update with a stale If-Match", method: http.MethodPut, path: "/author/2", token: user.Editor,
+			header: map[string]string{"If-Match": `"0-0"`}, body: map[string]interface{}{"AuthorName": "Octavia E. Butler"},
+			want: http.StatusPreconditionFailed},
+		{name: "update", method: http.MethodPut, path: "/author/2", token: user.Editor,
+			header: map[string]string{"If-Match": "*"}, body: map[string]interface{}{"AuthorName": "Octavia E. Butler"},
+			want: http.StatusOK},
+		{name: "delete an author with books", method: http.MethodDelete, path: "/author/1", token: user.Editor, want: http.StatusConflict},
+		{name: "delete reassigning to itself", method: http.MethodDelete, path: "/author/1?reassign_to=1", token: user.Editor,
+			want: http.StatusBadRequest},
+		{name: "delete reassigning the books", method: http.MethodDelete, path: "/author/1?reassign_to=2", token: user.Editor,
+			want: http.StatusNoContent},
+		{name: "books follow the reassignment", method: http.MethodGet, path: "/author/2", token: user.Reader, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var a author.Author
+				decode(t, resp, &a)
+				if a.AuthorName != "Octavia E. Butler" || len(a.Books) != 1 {
+					t.Errorf("got author %q with %d books", a.AuthorName, len(a.Books))
+				}
+			}},
+		{name: "get deleted", method: http.MethodGet, path: "/author/1", token: user.Reader, want: http.StatusNotFound},
+		{name: "delete with cascade", method: http.MethodDelete, path: "/author/2?cascade=true", token: user.Editor,
+			want: http.StatusNoContent},
+		{name: "cascade trashed the books", method: http.MethodGet, path: "/book/trash", token: user.Editor, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var trash []book.Book
+				decode(t, resp, &trash)
+				if len(trash) != 1 {
+					t.Errorf("got %d books in the trash, want 1", len(trash))
+				}
+			}},
+	})
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+	"github.com/gorilla/mux"
+)
+
+//BookStockMovements returns the stock ledger of a book, newest first
+func (c *Catalog) BookStockMovements(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := c.Books.Movements(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//BookStockMovementCreate records a receipt, sale, adjustment, return or transfer of a book
+func (c *Catalog) BookStockMovementCreate(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	var m book.StockMovement
+	if err := decodeJSON(r, &m); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	m.ID = 0
+	m.BookID = id
+	if m.User, err = actingUser(r, m.User); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	if fields := m.Validate(); fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
+
+	if err := c.Books.RecordMovement(r.Context(), &m); err != nil {
+		respondWithError(w, r, stockError(err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusCreated, m)
+}
+
+//BookStockReserve holds stock of a book until the reservation is committed or released
+func (c *Catalog) BookStockReserve(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	var reservation book.StockReservation
+	if err := decodeJSON(r, &reservation); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	reservation.ID = 0
+	reservation.BookID = id
+	if reservation.User, err = actingUser(r, reservation.User); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	if fields := reservation.Validate(); fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
+
+	if err := c.Books.Reserve(r.Context(), &reservation); err != nil {
+		respondWithError(w, r, stockError(err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusCreated, reservation)
+}
+
+//StockReservationGet returns a reservation by its ID
+func (c *Catalog) StockReservationGet(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := c.Books.GetReservation(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//StockReservationCommit sells the stock of a held reservation in the name of the caller
+func (c *Catalog) StockReservationCommit(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := c.Books.CommitReservation(r.Context(), id, requestPrincipal(r).name())
+	if err != nil {
+		respondWithError(w, r, stockError(err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//StockReservationRelease gives the stock of a held reservation back
+func (c *Catalog) StockReservationRelease(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := c.Books.ReleaseReservation(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, stockError(err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//uintParam parses a positive integer route variable
+func uintParam(r *http.Request, name string) (uint, error) {
+	id, err := strconv.ParseUint(mux.Vars(r)[name], 10, 64)
+	if err != nil || id == 0 {
+		return 0, httpErrors.NewValidationError(map[string]string{name: "must be a positive integer"})
+	}
+	return uint(id), nil
+}
+
+//stockError maps stock ledger errors to their http errors
+func stockError(err error) error {
+	if errors.Is(err, book.ErrInsufficientStock) || errors.Is(err, book.ErrReservationNotHeld) {
+		return httpErrors.NewRestError(http.StatusConflict, err.Error(), err)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package server_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+)
+
+func TestStockRoutes(t *testing.T) {
+	ts, catalog := newTestServer(t)
+	addBooks(t, catalog, 5, "The Dispossessed")
+
+	receipt := map[string]interface{}{"Kind": book.Receipt, "Quantity": 3, "Reason": "delivery"}
+	runRouteTests(t, ts, []routeTest{
+		{name: "record as reader", method: http.MethodPost, path: "/book/1/stock/movements", token: user.Reader,
+			body: receipt, want: http.StatusForbidden},
+		{name: "record naming a user", method: http.MethodPost, path: "/book/1/stock/movements", token: user.Clerk,
+			body: map[string]interface{}{"Kind": book.Receipt, "Quantity": 3, "Reason": "delivery", "User": "someone else"},
+			want: http.StatusBadRequest},
+		{name: "record an unknown kind", method: http.MethodPost, path: "/book/1/stock/movements", token: user.Clerk,
+			body: map[string]interface{}{"Kind": "gift", "Quantity": 3, "Reason": "delivery"}, want: http.StatusBadRequest},
+		{name: "record a receipt", method: http.MethodPost, path: "/book/1/stock/movements", token: user.Clerk,
+			body: receipt, want: http.StatusCreated,
+			check: func(t *testing.T, resp *http.Response) {
+				var m book.StockMovement
+				decode(t, resp, &m)
+				if m.User != "user:1" || m.StockAfter != 8 {
+					t.Errorf("got movement by %q with stock %d after, want user:1 and 8", m.User, m.StockAfter)
+				}
+			}},
+		{name: "sell more than in stock", method: http.MethodPost, path: "/book/1/stock/movements", token: user.Clerk,
+			body: map[string]interface{}{"Kind": book.Sale, "Quantity": -20, "Reason": "sale"}, want: http.StatusConflict},
+		{name: "record for an unknown book", method: http.MethodPost, path: "/book/99/stock/movements", token: user.Clerk,
+			body: receipt, want: http.StatusNotFound},
+		{name: "list the ledger", method: http.MethodGet, path: "/book/1/stock/movements", token: user.Reader, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var ledger []book.StockMovement
+				decode(t, resp, &ledger)
+				if len(ledger) != 2 || ledger[0].Kind != book.Receipt {
+					t.Errorf("got %d movements, want the receipt and the initial stock, newest first", len(ledger))
+				}
+			}},
+		{name: "reserve naming a user", method: http.MethodPost, path: "/book/1/stock/reservations", token: user.Clerk,
+			body: map[string]interface{}{"Quantity": 6, "Reason": "cart", "User": "someone else"}, want: http.StatusBadRequest},
+		{name: "reserve", method: http.MethodPost, path: "/book/1/stock/reservations", token: user.Clerk,
+			body: map[string]interface{}{"Quantity": 6, "Reason": "cart"}, want: http.StatusCreated,
+			check: func(t *testing.T, resp *http.Response) {
+				var r book.StockReservation
+				decode(t, resp, &r)
+				if r.ID != 1 || r.Status != book.Held || r.User != "user:1" {
+					t.Errorf("got reservation %d %s by %q", r.ID, r.Status, r.User)
+				}
+			}},
+		{name: "reserve more than available", method: http.MethodPost, path: "/book/1/stock/reservations", token: user.Clerk,
+			body: map[string]interface{}{"Quantity": 3, "Reason": "cart"}, want: http.StatusConflict},
+		{name: "sell reserved stock", method: http.MethodPost, path: "/book/1/stock/movements", token: user.Clerk,
+			body: map[string]interface{}{"Kind": book.Sale, "Quantity": -3, "Reason": "sale"}, want: http.StatusConflict},
+		{name: "get the reservation", method: http.MethodGet, path: "/stock/reservations/1", token: user.Reader, want: http.StatusOK},
+		{name: "get an unknown reservation", method: http.MethodGet, path: "/stock/reservations/99", token: user.Reader,
+			want: http.StatusNotFound},
+		{name: "commit", method: http.MethodPost, path: "/stock/reservations/1/commit", token: user.Clerk, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var r book.StockReservation
+				decode(t, resp, &r)
+				if r.Status != book.Committed {
+					t.Errorf("got reservation %s", r.Status)
+				}
+			}},
+		{name: "commit again", method: http.MethodPost, path: "/stock/reservations/1/commit", token: user.Clerk,
+			want: http.StatusConflict},
+		{name: "reserve the rest", method: http.MethodPost, path: "/book/1/stock/reservations", token: user.Clerk,
+			body: map[string]interface{}{"Quantity": 2, "Reason": "cart"}, want: http.StatusCreated},
+		{name: "release", method: http.MethodPost, path: "/stock/reservations/2/release", token: user.Clerk, want: http.StatusOK},
+		{name: "release again", method: http.MethodPost, path: "/stock/reservations/2/release", token: user.Clerk,
+			want: http.StatusConflict},
+		{name: "the sale is recorded as the caller", method: http.MethodGet, path: "/book/1/stock/movements", token: user.Reader,
+			want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var ledger []book.StockMovement
+				decode(t, resp, &ledger)
+				if sale := ledger[0]; sale.Kind != book.Sale || sale.Quantity != -6 || sale.User != "user:1" {
+					t.Errorf("got %s of %d by %q, want the sale of 6 by user:1", sale.Kind, sale.Quantity, sale.User)
+				}
+			}},
+		{name: "stock after the sale", method: http.MethodGet, path: "/book/1", token: user.Reader, want: http.StatusOK,
+			check: func(t *testing.T, resp *http.Response) {
+				var b book.Book
+				decode(t, resp, &b)
+				if b.Stock != 2 || b.Reserved != 0 {
+					t.Errorf("got stock %d with %d reserved, want 2 and 0", b.Stock, b.Reserved)
+				}
+			}},
+	})
+}
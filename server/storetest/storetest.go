@@ -0,0 +1,660 @@
+//Package storetest checks that a BookStore and an AuthorStore behave like the postgres repositories: the same
+//results, the same errors and the same statuses for their errors. The tests of this package run it against the
+//memory catalog, sqlite and postgres
+package storetest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/money"
+	"github.com/BatuhanSerin/postgresql/domain/author"
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/server/http_errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+//Opener returns the stores a check runs on, they are only used by the test t
+type Opener func(t *testing.T) (book.BookStore, author.AuthorStore)
+
+//suite is the stores under check, every author, ISBN and stock code it creates is new so that the checks
+//can run on a database that has rows already
+type suite struct {
+	ctx     context.Context
+	books   book.BookStore
+	authors author.AuthorStore
+	next    uint
+}
+
+var checks = []struct {
+	name string
+	run  func(s *suite) error
+}{
+	{"create and get authors", checkAuthors},
+	{"create and get books", checkBooks},
+	{"unique isbn and stock code", checkUnique},
+	{"books of missing authors", checkMissingAuthor},
+	{"versions", checkVersions},
+	{"list pages", checkList},
+	{"list names in byte order", checkListByName},
+	{"find by name", checkFindByName},
+	{"stock ledger", checkStock},
+	{"reservations", checkReservations},
+	{"trash", checkTrash},
+	{"delete authors", checkDeleteAuthor},
+}
+
+//Run runs every check as a subtest of t on the stores open returns for it. The checks write to the stores,
+//open an empty store or use Transaction
+func Run(t *testing.T, open Opener) {
+	for _, c := range checks {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			books, authors := open(t)
+			s := &suite{
+				ctx:     context.Background(),
+				books:   books,
+				authors: authors,
+				next:    uint(time.Now().UnixNano()%1_000_000) * 1000,
+			}
+			if err := c.run(s); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+//Transaction opens the repositories of db in a transaction that is rolled back when the test ends, db must be
+//migrated to the latest version. Not found errors are expected by the checks and kept out of the log
+func Transaction(db *gorm.DB) Opener {
+	return func(t *testing.T) (book.BookStore, author.AuthorStore) {
+		tx := db.Session(&gorm.Session{Logger: logger.Discard}).Begin()
+		if tx.Error != nil {
+			t.Fatal(tx.Error)
+		}
+		t.Cleanup(func() { tx.Rollback() })
+		return book.NewBookRepository(tx).Store(), author.NewAuthorRepository(tx).Store()
+	}
+}
+
+func (s *suite) id() uint {
+	s.next++
+	return s.next
+}
+
+func (s *suite) newAuthor() (*author.Author, error) {
+	id := s.id()
+	a := &author.Author{AuthorID: id, AuthorName: fmt.Sprintf("Storetest Author %d", id)}
+	if err := s.authors.Create(s.ctx, a); err != nil {
+		return nil, fmt.Errorf("create author: %w", err)
+	}
+	return a, nil
+}
+
+func (s *suite) newBook(authorID uint, stock int) (*book.Book, error) {
+	b := s.bookOf(authorID, stock)
+	if err := s.books.Create(s.ctx, b); err != nil {
+		return nil, fmt.Errorf("create book: %w", err)
+	}
+	return b, nil
+}
+
+//bookOf returns a book that is not created yet with a new ISBN and stock code
+func (s *suite) bookOf(authorID uint, stock int) *book.Book {
+	id := s.id()
+	cost, _ := money.Parse("12.50")
+	return &book.Book{
+		Name:      fmt.Sprintf("Storetest Book %d", id),
+		Page:      100,
+		Stock:     stock,
+		Cost:      cost,
+		StockCode: fmt.Sprintf("ST-%d", id),
+		ISBN:      isbn13(fmt.Sprintf("978%09d", id)),
+		AuthorID:  authorID,
+	}
+}
+
+//isbn13 appends the check digit to the first 12 digits of an ISBN-13
+func isbn13(digits string) string {
+	sum := 0
+	for i, c := range digits {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(c-'0')
+	}
+	return digits + strconv.Itoa((10-sum%10)%10)
+}
+
+//wantErr fails unless err is target
+func wantErr(op string, err, target error) error {
+	if !errors.Is(err, target) {
+		return fmt.Errorf("%s: got error %v, want %v", op, err, target)
+	}
+	return nil
+}
+
+//wantStatus fails unless the api answers err with status
+func wantStatus(op string, err error, status int) error {
+	if err == nil {
+		return fmt.Errorf("%s: got no error, want status %d", op, status)
+	}
+	if got := http_errors.ParseErrors(err).Status(); got != status {
+		return fmt.Errorf("%s: got status %d for %v, want %d", op, got, err, status)
+	}
+	return nil
+}
+
+func checkAuthors(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	if a.ID == 0 || a.Version != 1 {
+		return fmt.Errorf("created author has id %d and version %d, want an id and version 1", a.ID, a.Version)
+	}
+
+	got, err := s.authors.GetByAuthorID(s.ctx, a.AuthorID)
+	if err != nil {
+		return fmt.Errorf("get author: %w", err)
+	}
+	if got.ID != a.ID || got.AuthorName != a.AuthorName || len(got.Books) != 0 {
+		return fmt.Errorf("got author %d %q with %d books, want %d %q without books",
+			got.ID, got.AuthorName, len(got.Books), a.ID, a.AuthorName)
+	}
+
+	byName, err := s.authors.GetAuthorWithName(s.ctx, strings.ToUpper(a.AuthorName))
+	if err != nil {
+		return fmt.Errorf("get author with name: %w", err)
+	}
+	if byName.AuthorID != a.AuthorID {
+		return fmt.Errorf("got author %d by name, want %d", byName.AuthorID, a.AuthorID)
+	}
+
+	err = s.authors.Create(s.ctx, &author.Author{AuthorID: a.AuthorID, AuthorName: "Duplicate"})
+	if err := wantErr("create author twice", err, author.ErrAuthorExists); err != nil {
+		return err
+	}
+	_, err = s.authors.GetByAuthorID(s.ctx, s.id())
+	return wantErr("get missing author", err, gorm.ErrRecordNotFound)
+}
+
+func checkBooks(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	b, err := s.newBook(a.AuthorID, 5)
+	if err != nil {
+		return err
+	}
+
+	got, err := s.books.GetByID(s.ctx, b.ID)
+	if err != nil {
+		return fmt.Errorf("get book: %w", err)
+	}
+	if got.Name != b.Name || got.Stock != 5 || got.Reserved != 0 || got.Cost.String() != "12.50" {
+		return fmt.Errorf("got book %q with stock %d, reserved %d, cost %s, want %q with stock 5, reserved 0, cost 12.50",
+			got.Name, got.Stock, got.Reserved, got.Cost, b.Name)
+	}
+	if got.Version != b.Version || b.Stock != 5 {
+		return fmt.Errorf("created book has version %d and stock %d, stored one %d and %d",
+			b.Version, b.Stock, got.Version, got.Stock)
+	}
+
+	movements, err := s.books.Movements(s.ctx, b.ID)
+	if err != nil {
+		return fmt.Errorf("movements: %w", err)
+	}
+	if len(movements) != 1 || movements[0].Kind != book.Receipt || movements[0].Quantity != 5 || movements[0].StockAfter != 5 {
+		return fmt.Errorf("got movements %+v, want a receipt of 5", movements)
+	}
+
+	of, err := s.books.FindByAuthorOrBookId(s.ctx, a.AuthorID)
+	if err != nil {
+		return fmt.Errorf("find by author: %w", err)
+	}
+	if len(of) != 1 || of[0].ID != b.ID {
+		return fmt.Errorf("got %d books of the author, want book %d", len(of), b.ID)
+	}
+	withBooks, err := s.authors.GetByAuthorID(s.ctx, a.AuthorID)
+	if err != nil {
+		return fmt.Errorf("get author: %w", err)
+	}
+	if len(withBooks.Books) != 1 || withBooks.Books[0].ID != b.ID {
+		return fmt.Errorf("got author with %d books, want book %d", len(withBooks.Books), b.ID)
+	}
+
+	_, err = s.books.GetByID(s.ctx, b.ID+1_000_000)
+	return wantErr("get missing book", err, gorm.ErrRecordNotFound)
+}
+
+func checkUnique(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	b, err := s.newBook(a.AuthorID, 0)
+	if err != nil {
+		return err
+	}
+
+	sameISBN := s.bookOf(a.AuthorID, 0)
+	sameISBN.ISBN = b.ISBN
+	if err := wantStatus("create book with a taken isbn", s.books.Create(s.ctx, sameISBN), http.StatusConflict); err != nil {
+		return err
+	}
+	sameCode := s.bookOf(a.AuthorID, 0)
+	sameCode.StockCode = b.StockCode
+	if err := wantStatus("create book with a taken stock code", s.books.Create(s.ctx, sameCode), http.StatusConflict); err != nil {
+		return err
+	}
+
+	other, err := s.newBook(a.AuthorID, 0)
+	if err != nil {
+		return err
+	}
+	other.ISBN = b.ISBN
+	return wantStatus("update book to a taken isbn", s.books.Update(s.ctx, other), http.StatusConflict)
+}
+
+func checkMissingAuthor(s *suite) error {
+	err := s.books.Create(s.ctx, s.bookOf(s.id(), 0))
+	return wantStatus("create book of a missing author", err, http.StatusUnprocessableEntity)
+}
+
+func checkVersions(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	b, err := s.newBook(a.AuthorID, 0)
+	if err != nil {
+		return err
+	}
+
+	stale := *b
+	b.Page = 200
+	version := b.Version
+	if err := s.books.Update(s.ctx, b); err != nil {
+		return fmt.Errorf("update book: %w", err)
+	}
+	if b.Version != version+1 {
+		return fmt.Errorf("updated book has version %d, want %d", b.Version, version+1)
+	}
+	got, err := s.books.GetByID(s.ctx, b.ID)
+	if err != nil {
+		return fmt.Errorf("get book: %w", err)
+	}
+	if got.Page != 200 || got.Version != b.Version {
+		return fmt.Errorf("got page %d and version %d, want 200 and %d", got.Page, got.Version, b.Version)
+	}
+	stale.Page = 300
+	if err := wantErr("update a stale book", s.books.Update(s.ctx, &stale), book.ErrVersionConflict); err != nil {
+		return err
+	}
+
+	staleAuthor := *a
+	a.AuthorName = a.AuthorName + " Updated"
+	if err := s.authors.Update(s.ctx, a); err != nil {
+		return fmt.Errorf("update author: %w", err)
+	}
+	if a.Version != 2 {
+		return fmt.Errorf("updated author has version %d, want 2", a.Version)
+	}
+	return wantErr("update a stale author", s.authors.Update(s.ctx, &staleAuthor), author.ErrVersionConflict)
+}
+
+func checkList(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	var ids []uint
+	for stock := 1; stock <= 3; stock++ {
+		b, err := s.newBook(a.AuthorID, stock)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, b.ID)
+	}
+
+	minStock := 2
+	q := book.ListQuery{
+		Filter: book.Filter{AuthorID: a.AuthorID},
+		Sort:   []book.SortField{{Column: "stock", Desc: true}},
+		Limit:  1,
+	}
+	var got []uint
+	for page := 0; page < 5; page++ {
+		result, err := s.books.List(s.ctx, q)
+		if err != nil {
+			return fmt.Errorf("list page %d: %w", page, err)
+		}
+		if result.Total != 3 {
+			return fmt.Errorf("list page %d has total %d, want 3", page, result.Total)
+		}
+		for _, b := range result.Books {
+			got = append(got, b.ID)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		q.Cursor = result.NextCursor
+	}
+	if fmt.Sprint(got) != fmt.Sprint([]uint{ids[2], ids[1], ids[0]}) {
+		return fmt.Errorf("listed books %v by stock descending, want %v", got, []uint{ids[2], ids[1], ids[0]})
+	}
+
+	result, err := s.books.List(s.ctx, book.ListQuery{
+		Filter: book.Filter{AuthorID: a.AuthorID, MinStock: &minStock},
+		Sort:   []book.SortField{{Column: "id"}},
+		Offset: 1,
+	})
+	if err != nil {
+		return fmt.Errorf("list with offset: %w", err)
+	}
+	if result.Total != 2 || len(result.Books) != 1 || result.Books[0].ID != ids[2] {
+		return fmt.Errorf("got %d of %d books after offset 1 with stock of 2 or more, want book %d of 2",
+			len(result.Books), result.Total, ids[2])
+	}
+	return nil
+}
+
+func checkListByName(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{"apple", "Zulu", "Ähre", "banana", "Banana"} {
+		b := s.bookOf(a.AuthorID, 0)
+		b.Name = name
+		if err := s.books.Create(s.ctx, b); err != nil {
+			return fmt.Errorf("create book %q: %w", name, err)
+		}
+	}
+
+	// upper case before lower case before non ascii letters, whatever the collation of the database
+	want := []string{"Banana", "Zulu", "apple", "banana", "Ähre"}
+	q := book.ListQuery{
+		Filter: book.Filter{AuthorID: a.AuthorID},
+		Sort:   []book.SortField{{Column: "name"}},
+		Limit:  2,
+	}
+	var got []string
+	for page := 0; page < 5; page++ {
+		result, err := s.books.List(s.ctx, q)
+		if err != nil {
+			return fmt.Errorf("list page %d: %w", page, err)
+		}
+		for _, b := range result.Books {
+			got = append(got, b.Name)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		q.Cursor = result.NextCursor
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		return fmt.Errorf("listed books %q by name, want %q", got, want)
+	}
+	return nil
+}
+
+func checkFindByName(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	b := s.bookOf(a.AuthorID, 0)
+	b.Name = "The Lord of the Rings " + b.StockCode
+	if err := s.books.Create(s.ctx, b); err != nil {
+		return fmt.Errorf("create book: %w", err)
+	}
+
+	found, err := s.books.FindByName(s.ctx, "the LORD of the rings "+strings.ToLower(b.StockCode))
+	if err != nil {
+		return fmt.Errorf("find by name: %w", err)
+	}
+	if len(found) != 1 || found[0].ID != b.ID {
+		return fmt.Errorf("found %d books by name, want book %d", len(found), b.ID)
+	}
+	return nil
+}
+
+func checkStock(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	b, err := s.newBook(a.AuthorID, 2)
+	if err != nil {
+		return err
+	}
+
+	sale := &book.StockMovement{BookID: b.ID, Kind: book.Sale, Quantity: -3, Reason: "storetest", User: "storetest"}
+	if err := wantErr("sell more than the stock", s.books.RecordMovement(s.ctx, sale), book.ErrInsufficientStock); err != nil {
+		return err
+	}
+	receipt := &book.StockMovement{BookID: b.ID, Kind: book.Receipt, Quantity: 4, Reason: "storetest", User: "storetest"}
+	if err := s.books.RecordMovement(s.ctx, receipt); err != nil {
+		return fmt.Errorf("record receipt: %w", err)
+	}
+	if receipt.ID == 0 || receipt.StockAfter != 6 {
+		return fmt.Errorf("recorded receipt has id %d and stock after %d, want an id and 6", receipt.ID, receipt.StockAfter)
+	}
+
+	movements, err := s.books.Movements(s.ctx, b.ID)
+	if err != nil {
+		return fmt.Errorf("movements: %w", err)
+	}
+	if len(movements) != 2 || movements[0].ID != receipt.ID {
+		return fmt.Errorf("got %d movements, want 2 with the receipt first", len(movements))
+	}
+	_, err = s.books.Movements(s.ctx, b.ID+1_000_000)
+	return wantErr("movements of a missing book", err, gorm.ErrRecordNotFound)
+}
+
+func checkReservations(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	b, err := s.newBook(a.AuthorID, 5)
+	if err != nil {
+		return err
+	}
+
+	held := &book.StockReservation{BookID: b.ID, Quantity: 3, Reason: "storetest", User: "storetest"}
+	if err := s.books.Reserve(s.ctx, held); err != nil {
+		return fmt.Errorf("reserve: %w", err)
+	}
+	if held.ID == 0 || held.Status != book.Held {
+		return fmt.Errorf("reservation has id %d and status %q, want an id and %q", held.ID, held.Status, book.Held)
+	}
+	more := &book.StockReservation{BookID: b.ID, Quantity: 3, Reason: "storetest", User: "storetest"}
+	if err := wantErr("reserve more than available", s.books.Reserve(s.ctx, more), book.ErrInsufficientStock); err != nil {
+		return err
+	}
+	sale := &book.StockMovement{BookID: b.ID, Kind: book.Sale, Quantity: -3, Reason: "storetest", User: "storetest"}
+	if err := wantErr("sell reserved stock", s.books.RecordMovement(s.ctx, sale), book.ErrInsufficientStock); err != nil {
+		return err
+	}
+
+	committed, err := s.books.CommitReservation(s.ctx, held.ID, "storetest")
+	if err != nil {
+		return fmt.Errorf("commit reservation: %w", err)
+	}
+	if committed.Status != book.Committed {
+		return fmt.Errorf("committed reservation has status %q", committed.Status)
+	}
+	_, err = s.books.CommitReservation(s.ctx, held.ID, "storetest")
+	if err := wantErr("commit twice", err, book.ErrReservationNotHeld); err != nil {
+		return err
+	}
+
+	released := &book.StockReservation{BookID: b.ID, Quantity: 2, Reason: "storetest", User: "storetest"}
+	if err := s.books.Reserve(s.ctx, released); err != nil {
+		return fmt.Errorf("reserve: %w", err)
+	}
+	if _, err := s.books.ReleaseReservation(s.ctx, released.ID); err != nil {
+		return fmt.Errorf("release reservation: %w", err)
+	}
+	got, err := s.books.GetReservation(s.ctx, released.ID)
+	if err != nil {
+		return fmt.Errorf("get reservation: %w", err)
+	}
+	if got.Status != book.Released {
+		return fmt.Errorf("released reservation has status %q", got.Status)
+	}
+
+	after, err := s.books.GetByID(s.ctx, b.ID)
+	if err != nil {
+		return fmt.Errorf("get book: %w", err)
+	}
+	if after.Stock != 2 || after.Reserved != 0 {
+		return fmt.Errorf("got stock %d and reserved %d, want 2 and 0", after.Stock, after.Reserved)
+	}
+	return nil
+}
+
+func checkTrash(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	b, err := s.newBook(a.AuthorID, 1)
+	if err != nil {
+		return err
+	}
+
+	if err := s.books.Delete(s.ctx, b.ID); err != nil {
+		return fmt.Errorf("delete book: %w", err)
+	}
+	_, err = s.books.GetByID(s.ctx, b.ID)
+	if err := wantErr("get deleted book", err, gorm.ErrRecordNotFound); err != nil {
+		return err
+	}
+	if err := wantErr("delete twice", s.books.Delete(s.ctx, b.ID), book.ErrAlreadyDeleted); err != nil {
+		return err
+	}
+	trash, err := s.books.Trash(s.ctx)
+	if err != nil {
+		return fmt.Errorf("trash: %w", err)
+	}
+	if !contains(trash, b.ID) {
+		return fmt.Errorf("deleted book %d is not in the trash", b.ID)
+	}
+
+	restored, err := s.books.Restore(s.ctx, b.ID)
+	if err != nil {
+		return fmt.Errorf("restore: %w", err)
+	}
+	if restored.DeletedAt.Valid {
+		return fmt.Errorf("restored book is still deleted")
+	}
+	_, err = s.books.Restore(s.ctx, b.ID)
+	if err := wantErr("restore a live book", err, book.ErrNotDeleted); err != nil {
+		return err
+	}
+
+	if err := s.books.Delete(s.ctx, b.ID); err != nil {
+		return fmt.Errorf("delete book: %w", err)
+	}
+	if err := s.books.HardDelete(s.ctx, b.ID); err != nil {
+		return fmt.Errorf("hard delete: %w", err)
+	}
+	_, err = s.books.Restore(s.ctx, b.ID)
+	if err := wantErr("restore a removed book", err, gorm.ErrRecordNotFound); err != nil {
+		return err
+	}
+
+	purged, err := s.newBook(a.AuthorID, 0)
+	if err != nil {
+		return err
+	}
+	if err := s.books.Delete(s.ctx, purged.ID); err != nil {
+		return fmt.Errorf("delete book: %w", err)
+	}
+	if n, err := s.books.Purge(s.ctx, time.Now().Add(time.Hour)); err != nil || n == 0 {
+		return fmt.Errorf("purge removed %d books with error %v, want the deleted book removed", n, err)
+	}
+	trash, err = s.books.Trash(s.ctx)
+	if err != nil {
+		return fmt.Errorf("trash: %w", err)
+	}
+	if contains(trash, purged.ID) {
+		return fmt.Errorf("purged book %d is still in the trash", purged.ID)
+	}
+	return nil
+}
+
+func checkDeleteAuthor(s *suite) error {
+	a, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	target, err := s.newAuthor()
+	if err != nil {
+		return err
+	}
+	b, err := s.newBook(a.AuthorID, 0)
+	if err != nil {
+		return err
+	}
+
+	err = s.authors.Delete(s.ctx, a.AuthorID, author.DeleteOptions{})
+	if err := wantErr("delete an author with books", err, author.ErrAuthorHasBooks); err != nil {
+		return err
+	}
+	err = s.authors.Delete(s.ctx, a.AuthorID, author.DeleteOptions{Cascade: true, ReassignTo: target.AuthorID})
+	if err := wantErr("delete with cascade and reassign", err, author.ErrConflictingOptions); err != nil {
+		return err
+	}
+	err = s.authors.Delete(s.ctx, a.AuthorID, author.DeleteOptions{ReassignTo: s.id()})
+	if err := wantErr("reassign to a missing author", err, author.ErrReassignTargetNotFound); err != nil {
+		return err
+	}
+
+	if err := s.authors.Delete(s.ctx, a.AuthorID, author.DeleteOptions{ReassignTo: target.AuthorID}); err != nil {
+		return fmt.Errorf("delete and reassign: %w", err)
+	}
+	moved, err := s.books.GetByID(s.ctx, b.ID)
+	if err != nil {
+		return fmt.Errorf("get book: %w", err)
+	}
+	if moved.AuthorID != target.AuthorID {
+		return fmt.Errorf("book has author %d after reassign, want %d", moved.AuthorID, target.AuthorID)
+	}
+	_, err = s.authors.GetByAuthorID(s.ctx, a.AuthorID)
+	if err := wantErr("get deleted author", err, gorm.ErrRecordNotFound); err != nil {
+		return err
+	}
+
+	if err := s.authors.Delete(s.ctx, target.AuthorID, author.DeleteOptions{Cascade: true}); err != nil {
+		return fmt.Errorf("delete with cascade: %w", err)
+	}
+	_, err = s.books.GetByID(s.ctx, b.ID)
+	if err := wantErr("get book of a cascade deleted author", err, gorm.ErrRecordNotFound); err != nil {
+		return err
+	}
+	_, err = s.books.Restore(s.ctx, b.ID)
+	return wantErr("restore book of a deleted author", err, book.ErrAuthorDeleted)
+}
+
+func contains(books []book.Book, id uint) bool {
+	for _, b := range books {
+		if b.ID == id {
+			return true
+		}
+	}
+	return false
+}
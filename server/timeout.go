@@ -0,0 +1,60 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"github.com/gorilla/mux"
+)
+
+//answerGrace is how long past its timeout a request may still write the response that reports it
+const answerGrace = time.Second
+
+//routeTimeout is the timeout of the routes under a path prefix
+type routeTimeout struct {
+	prefix  string
+	timeout time.Duration
+}
+
+//timeoutMiddleware gives every request a deadline, the timeout of its route group or the default one.
+//The repositories run their queries with the request context, so the database stops them at the deadline
+//or as soon as the client goes away. A route whose timeout is longer than the read or write timeout of the server
+//gets its connection deadlines moved past its own, so a long import can read its body and a long export can write
+func timeoutMiddleware(s config.Server, t config.Timeouts) mux.MiddlewareFunc {
+	routes := []routeTimeout{
+		{"/book", t.Or(t.Books)},
+		{"/stock", t.Or(t.Books)},
+		{"/author", t.Or(t.Authors)},
+		{"/orders", t.Or(t.Orders)},
+		{"/customers", t.Or(t.Orders)},
+		{"/search", t.Or(t.Search)},
+		{"/import", t.Or(t.Import)},
+		{"/export", t.Or(t.Export)},
+		{"/audit", t.Or(t.Audit)},
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			timeout := t.Default
+			for _, route := range routes {
+				if r.URL.Path == route.prefix || strings.HasPrefix(r.URL.Path, route.prefix+"/") {
+					timeout = route.timeout
+					break
+				}
+			}
+			// writers that cannot move their deadlines, such as test recorders, have none to move
+			rc := http.NewResponseController(w)
+			if timeout+answerGrace > s.WriteTimeout {
+				rc.SetWriteDeadline(time.Now().Add(timeout + answerGrace))
+			}
+			if timeout > s.ReadTimeout {
+				rc.SetReadDeadline(time.Now().Add(timeout))
+			}
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package server_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"github.com/BatuhanSerin/postgresql/domain/search"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+	"github.com/BatuhanSerin/postgresql/server"
+)
+
+// slowSearch answers every search after delay
+type slowSearch struct {
+	delay time.Duration
+}
+
+func (s slowSearch) Search(ctx context.Context, term string, limit int) (*search.Result, error) {
+	select {
+	case <-time.After(s.delay):
+		return &search.Result{Query: term}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func TestRouteTimeoutOutlivesWriteTimeout(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.WriteTimeout = 100 * time.Millisecond
+	tests := []struct {
+		name    string
+		timeout time.Duration
+		delay   time.Duration
+		want    int
+	}{
+		{"longer than the write timeout", 2 * time.Second, 300 * time.Millisecond, http.StatusOK},
+		{"runs out", 200 * time.Millisecond, time.Second, http.StatusRequestTimeout},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Timeouts.Search = tt.timeout
+			ts := startServer(t, &cfg, &server.Services{Auth: fakeAuth{}, Searcher: slowSearch{tt.delay}})
+
+			resp := do(t, http.MethodGet, ts.URL+"/search?q=dune", user.Reader, nil)
+			if resp.StatusCode != tt.want {
+				t.Fatalf("GET /search: got %d, want %d", resp.StatusCode, tt.want)
+			}
+			var body map[string]interface{}
+			if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+				t.Errorf("GET /search: body cut off: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+)
+
+//BookTrash returns the soft deleted books
+func (c *Catalog) BookTrash(w http.ResponseWriter, r *http.Request) {
+
+	d, err := c.Books.Trash(r.Context())
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//BookRestore undeletes a soft deleted book
+func (c *Catalog) BookRestore(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := c.Books.Restore(r.Context(), id)
+	if err != nil {
+		respondWithError(w, r, trashError(err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//BookDelete soft deletes a book, ?hard=true removes it and its stock ledger for good
+func (c *Catalog) BookDelete(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	hard := false
+	if v := r.URL.Query().Get("hard"); v != "" {
+		if hard, err = strconv.ParseBool(v); err != nil {
+			respondWithError(w, r, httpErrors.NewBadQueryParamsError(map[string]string{"hard": "must be true or false"}))
+			return
+		}
+	}
+
+	if hard {
+		err = c.Books.HardDelete(r.Context(), id)
+	} else {
+		err = c.Books.Delete(r.Context(), id)
+	}
+	if err != nil {
+		respondWithError(w, r, trashError(err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+//purgeTrash removes the books deleted longer than the retention ago every interval until the server stops
+func purgeTrash(books book.BookStore, retention, interval time.Duration) {
+	for {
+		purged, err := books.Purge(context.Background(), time.Now().Add(-retention))
+		if err != nil {
+			log.Println("Trash cannot be purged ", err)
+		} else if purged > 0 {
+			log.Printf("Purged %d books deleted before %s", purged, time.Now().Add(-retention).Format(time.RFC3339))
+		}
+		time.Sleep(interval)
+	}
+}
+
+//trashError maps trash errors to their http errors
+func trashError(err error) error {
+	switch {
+	case errors.Is(err, book.ErrAlreadyDeleted):
+		return httpErrors.NewRestError(http.StatusGone, err.Error(), err)
+	case errors.Is(err, book.ErrNotDeleted), errors.Is(err, book.ErrAuthorDeleted), errors.Is(err, book.ErrBookHasOrders):
+		return httpErrors.NewRestError(http.StatusConflict, err.Error(), err)
+	}
+	return err
+}
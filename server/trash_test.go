@@ -0,0 +1,59 @@
+package server_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/BatuhanSerin/postgresql/domain/book"
+	"github.com/BatuhanSerin/postgresql/domain/order"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+)
+
+func TestTrashRoutes(t *testing.T) {
+	ts, catalog := newTestServer(t)
+	addBooks(t, catalog, 5, "The Dispossessed", "The Lathe of Heaven", "Always Coming Home")
+	cart := order.Cart{CustomerID: "c-1", Items: []order.CartItem{{BookID: 3, Quantity: 1}}, User: "user:1"}
+	if _, err := catalog.Orders().Checkout(context.Background(), cart); err != nil {
+		t.Fatal(err)
+	}
+
+	trashHas := func(ids ...uint) func(t *testing.T, resp *http.Response) {
+		return func(t *testing.T, resp *http.Response) {
+			var trash []book.Book
+			decode(t, resp, &trash)
+			if len(trash) != len(ids) {
+				t.Fatalf("got %d books in the trash, want %v", len(trash), ids)
+			}
+			for i, b := range trash {
+				if b.ID != ids[i] {
+					t.Errorf("got book %d in the trash, want %v", b.ID, ids)
+				}
+			}
+		}
+	}
+	runRouteTests(t, ts, []routeTest{
+		{name: "delete as reader", method: http.MethodDelete, path: "/book/1", token: user.Reader, want: http.StatusForbidden},
+		{name: "delete", method: http.MethodDelete, path: "/book/1", token: user.Editor, want: http.StatusNoContent},
+		{name: "delete again", method: http.MethodDelete, path: "/book/1", token: user.Editor, want: http.StatusGone},
+		{name: "get deleted", method: http.MethodGet, path: "/book/1", token: user.Reader, want: http.StatusNotFound},
+		{name: "trash as reader", method: http.MethodGet, path: "/book/trash", token: user.Reader, want: http.StatusForbidden},
+		{name: "trash", method: http.MethodGet, path: "/book/trash", token: user.Editor, want: http.StatusOK, check: trashHas(1)},
+		{name: "restore a live book", method: http.MethodPost, path: "/book/2/restore", token: user.Editor, want: http.StatusConflict},
+		{name: "restore", method: http.MethodPost, path: "/book/1/restore", token: user.Editor, want: http.StatusOK},
+		{name: "restore again", method: http.MethodPost, path: "/book/1/restore", token: user.Editor, want: http.StatusConflict},
+		{name: "get restored", method: http.MethodGet, path: "/book/1", token: user.Reader, want: http.StatusOK},
+		{name: "hard delete with a bad flag", method: http.MethodDelete, path: "/book/2?hard=maybe", token: user.Editor,
+			want: http.StatusBadRequest},
+		{name: "hard delete", method: http.MethodDelete, path: "/book/2?hard=true", token: user.Editor, want: http.StatusNoContent},
+		{name: "hard delete again", method: http.MethodDelete, path: "/book/2?hard=true", token: user.Editor, want: http.StatusNotFound},
+		{name: "hard delete a book on an order", method: http.MethodDelete, path: "/book/3?hard=true", token: user.Editor,
+			want: http.StatusConflict},
+		{name: "delete the author with its books", method: http.MethodDelete, path: "/author/1?cascade=true", token: user.Editor,
+			want: http.StatusNoContent},
+		{name: "trash after the cascade", method: http.MethodGet, path: "/book/trash", token: user.Editor, want: http.StatusOK,
+			check: trashHas(1, 3)},
+		{name: "restore a book of a deleted author", method: http.MethodPost, path: "/book/1/restore", token: user.Editor,
+			want: http.StatusConflict},
+	})
+}
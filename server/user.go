@@ -0,0 +1,146 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/BatuhanSerin/postgresql/common/config"
+	"github.com/BatuhanSerin/postgresql/domain/user"
+	httpErrors "github.com/BatuhanSerin/postgresql/server/http_errors"
+	"gorm.io/gorm"
+)
+
+//UserRepo returns the user repository of db and creates the configured admin
+func UserRepo(cfg *config.Config, db *gorm.DB) *user.UserRepository {
+	userRepo := user.NewUserRepository(db)
+	if cfg.Admin.Username != "" {
+		if err := userRepo.EnsureAdmin(cfg.Admin.Username, cfg.Admin.Password); err != nil {
+			log.Fatal("Admin cannot be created ", err)
+		}
+	}
+	return userRepo
+}
+
+//Register creates a reader account
+func (s *Services) Register(w http.ResponseWriter, r *http.Request) {
+
+	var reg user.Registration
+	if err := decodeJSON(r, &reg); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+	reg.Role = ""
+
+	s.createUser(w, r, reg)
+}
+
+//UserCreate creates an account with any role
+func (s *Services) UserCreate(w http.ResponseWriter, r *http.Request) {
+
+	var reg user.Registration
+	if err := decodeJSON(r, &reg); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	s.createUser(w, r, reg)
+}
+
+func (s *Services) createUser(w http.ResponseWriter, r *http.Request, reg user.Registration) {
+	if fields := reg.Validate(); fields != nil {
+		respondWithError(w, r, httpErrors.NewValidationError(fields))
+		return
+	}
+
+	d, err := s.Users.Register(r.Context(), reg)
+	if err != nil {
+		respondWithError(w, r, userError(err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusCreated, d)
+}
+
+//UserList returns every user
+func (s *Services) UserList(w http.ResponseWriter, r *http.Request) {
+
+	d, err := s.Users.FindAll(r.Context())
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//UserMe returns the user of the access token
+func (s *Services) UserMe(w http.ResponseWriter, r *http.Request) {
+
+	claims := requestClaims(r)
+	if claims == nil {
+		respondWithError(w, r, httpErrors.NewRestError(http.StatusForbidden, httpErrors.PermissionDenied.Error(), "an api key has no user"))
+		return
+	}
+	id, err := strconv.ParseUint(claims.Subject, 10, 64)
+	if err != nil {
+		respondWithError(w, r, httpErrors.NewRestError(http.StatusUnauthorized, httpErrors.InvalidJWTClaims.Error(), err))
+		return
+	}
+
+	d, err := s.Users.GetByID(r.Context(), uint(id))
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//UserChangeRole gives a user another role, it applies to the tokens issued from the next login or refresh
+func (s *Services) UserChangeRole(w http.ResponseWriter, r *http.Request) {
+
+	id, err := uintParam(r, "id")
+	if err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	var change user.RoleChange
+	if err := decodeJSON(r, &change); err != nil {
+		respondWithError(w, r, err)
+		return
+	}
+
+	d, err := s.Users.ChangeRole(r.Context(), id, change.Role)
+	if err != nil {
+		respondWithError(w, r, userError(err))
+		return
+	}
+
+	respondWithJSON(w, r, http.StatusOK, d)
+}
+
+//requirePermission serves h only when the role of the access token or the scopes of the api key grant permission
+func requirePermission(permission string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := requestPrincipal(r)
+		if p == nil || !p.can(permission) {
+			respondWithError(w, r, httpErrors.NewRestError(http.StatusForbidden, httpErrors.PermissionDenied.Error(), permission))
+			return
+		}
+		h(w, r)
+	}
+}
+
+//userError maps user errors to their http errors
+func userError(err error) error {
+	switch {
+	case errors.Is(err, user.ErrUsernameTaken), errors.Is(err, user.ErrLastAdmin):
+		return httpErrors.NewRestError(http.StatusConflict, err.Error(), err)
+	case errors.Is(err, user.ErrUnknownRole):
+		return httpErrors.NewValidationError(map[string]string{"Role": "must be admin, editor, clerk or reader"})
+	}
+	return err
+}